@@ -0,0 +1,67 @@
+// Package auth implements OAuth 2.1 resource server authorization for MCP
+// servers: verifying bearer tokens, describing the protected resource to
+// clients and passing the verified claims to handlers.
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned by a TokenVerifier for tokens that are
+	// malformed, expired, revoked or not meant for this server.
+	ErrInvalidToken = errors.New("auth: invalid token")
+	// ErrInsufficientScope is returned when a token lacks a required scope.
+	ErrInsufficientScope = errors.New("auth: insufficient scope")
+)
+
+// Claims are the verified attributes of an access token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ClientID  string
+	Scopes    []string
+	ExpiresAt time.Time
+	// Raw holds every claim of the token.
+	Raw map[string]any
+}
+
+// HasScopes reports whether c grants every one of scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// TokenVerifier verifies bearer tokens. Implementations return an error
+// wrapping ErrInvalidToken for tokens that must be rejected.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, token string) (*Claims, error)
+}
+
+// TokenVerifierFunc adapts a function to the TokenVerifier interface.
+type TokenVerifierFunc func(ctx context.Context, token string) (*Claims, error)
+
+func (f TokenVerifierFunc) VerifyToken(ctx context.Context, token string) (*Claims, error) {
+	return f(ctx, token)
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying c.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFrom returns the claims of the authenticated caller, if any.
+func ClaimsFrom(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok && c != nil
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound is returned by a KeySet that has no key with the requested
+// ID.
+var ErrKeyNotFound = errors.New("auth: signing key not found")
+
+// KeySet provides the public keys that tokens are signed with.
+type KeySet interface {
+	// Key returns the key with the given ID. An empty kid selects the only
+	// key of a single-key set.
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// jwk is a JSON Web Key, RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS parses a JSON Web Key Set into public keys by key ID. Keys of
+// unsupported types and encryption keys are skipped.
+func ParseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: parsing JWKS: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("auth: JWKS key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// StaticKeySet is a fixed set of keys by key ID.
+type StaticKeySet map[string]crypto.PublicKey
+
+// NewStaticKeySet parses a JSON Web Key Set document.
+func NewStaticKeySet(jwks []byte) (StaticKeySet, error) {
+	keys, err := ParseJWKS(jwks)
+	if err != nil {
+		return nil, err
+	}
+	return StaticKeySet(keys), nil
+}
+
+func (s StaticKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	return lookupKey(s, kid)
+}
+
+func lookupKey(keys map[string]crypto.PublicKey, kid string) (crypto.PublicKey, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+const (
+	// jwksMaxAge is how long fetched keys are used before refreshing them.
+	jwksMaxAge = time.Hour
+	// jwksMinRefresh limits refetches caused by unknown key IDs.
+	jwksMinRefresh = time.Minute
+	// jwksMaxSize bounds the size of a fetched key set.
+	jwksMaxSize = 1 << 20
+	// jwksFetchTimeout bounds a fetch, which outlives the request that
+	// started it when other requests wait for it too.
+	jwksFetchTimeout = 30 * time.Second
+)
+
+// RemoteKeySet fetches keys from a JWKS URL, such as the jwks_uri of an
+// authorization server, and caches them. Keys are refreshed hourly, and
+// when a token names an unknown key, at most once a minute. Concurrent
+// refreshes share one fetch, and cached keys stay readable while it runs.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	mu       sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	fetched  time.Time
+	fetching *jwksFetch
+}
+
+// jwksFetch is a fetch of the key set that callers can wait for.
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRemoteKeySet returns a key set fetched from url with client, or
+// http.DefaultClient if client is nil.
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteKeySet{url: url, client: client}
+}
+
+func (r *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	keys, fetched := r.cached()
+	age := time.Since(fetched)
+	if keys == nil || age > jwksMaxAge {
+		// stale keys are better than none while the server is unreachable
+		err := r.refresh(ctx)
+		if keys, _ = r.cached(); keys == nil {
+			return nil, err
+		}
+		return lookupKey(keys, kid)
+	}
+	key, err := lookupKey(keys, kid)
+	if errors.Is(err, ErrKeyNotFound) && age > jwksMinRefresh {
+		// the authorization server may have rotated its keys
+		if err := r.refresh(ctx); err != nil {
+			return nil, err
+		}
+		keys, _ = r.cached()
+		return lookupKey(keys, kid)
+	}
+	return key, err
+}
+
+func (r *RemoteKeySet) cached() (map[string]crypto.PublicKey, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys, r.fetched
+}
+
+// refresh fetches the keys, or waits for the fetch already in progress.
+func (r *RemoteKeySet) refresh(ctx context.Context) error {
+	r.mu.Lock()
+	f := r.fetching
+	if f == nil {
+		f = &jwksFetch{done: make(chan struct{})}
+		r.fetching = f
+		go r.fetch(context.WithoutCancel(ctx), f)
+	}
+	r.mu.Unlock()
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *RemoteKeySet) fetch(ctx context.Context, f *jwksFetch) {
+	ctx, cancel := context.WithTimeout(ctx, jwksFetchTimeout)
+	defer cancel()
+	keys, err := r.get(ctx)
+	r.mu.Lock()
+	if err == nil {
+		r.keys, r.fetched = keys, time.Now()
+	}
+	r.fetching = nil
+	r.mu.Unlock()
+	f.err = err
+	close(f.done)
+}
+
+func (r *RemoteKeySet) get(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetching JWKS: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("auth: fetching JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
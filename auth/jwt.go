@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultLeeway is the clock skew tolerated when checking token lifetimes.
+const DefaultLeeway = time.Minute
+
+// JWTVerifier verifies JSON Web Tokens signed with a key from a KeySet.
+// Tokens must be signed with RS256, RS384, RS512, ES256, ES384, ES512 or
+// EdDSA, must carry an exp claim and must be issued for the audience given
+// with WithAudience.
+type JWTVerifier struct {
+	keys        KeySet
+	issuer      string
+	audience    string
+	anyAudience bool
+	leeway      time.Duration
+	now         func() time.Time
+}
+
+var errNoAudience = errors.New("auth: JWTVerifier needs WithAudience or WithAnyAudience")
+
+// JWTOption configures a JWTVerifier.
+type JWTOption func(*JWTVerifier)
+
+// WithIssuer requires the iss claim to equal issuer.
+func WithIssuer(issuer string) JWTOption {
+	return func(v *JWTVerifier) { v.issuer = issuer }
+}
+
+// WithAudience requires the aud claim to contain audience, normally the
+// canonical URL of the MCP server, so that tokens issued for other
+// resources are rejected.
+func WithAudience(audience string) JWTOption {
+	return func(v *JWTVerifier) { v.audience = audience }
+}
+
+// WithAnyAudience accepts tokens regardless of their aud claim. It is only
+// safe when the audience is checked elsewhere, as WithBearerAuth in package
+// transport does; without it or WithAudience every token is rejected.
+func WithAnyAudience() JWTOption {
+	return func(v *JWTVerifier) { v.anyAudience = true }
+}
+
+// WithLeeway sets the tolerated clock skew. The default is DefaultLeeway.
+func WithLeeway(d time.Duration) JWTOption {
+	return func(v *JWTVerifier) { v.leeway = d }
+}
+
+// NewJWTVerifier returns a verifier for tokens signed by keys.
+func NewJWTVerifier(keys KeySet, opts ...JWTOption) *JWTVerifier {
+	v := &JWTVerifier{keys: keys, leeway: DefaultLeeway, now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*a = audience{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  audience        `json:"aud"`
+	Expiry    *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+	ClientID  string          `json:"client_id"`
+	AZP       string          `json:"azp"`
+}
+
+func (v *JWTVerifier) VerifyToken(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed JWT", ErrInvalidToken)
+	}
+	var hdr jwtHeader
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidToken, err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidToken, err)
+	}
+	key, err := v.keys.Key(ctx, hdr.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if err := verifySignature(hdr.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	var raw map[string]any
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	var jc jwtClaims
+	if err := decodeSegment(parts[1], &jc); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidToken, err)
+	}
+	now := v.now()
+	if jc.Expiry == nil {
+		return nil, fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	exp, err := numericDate(*jc.Expiry)
+	if err != nil {
+		return nil, fmt.Errorf("%w: exp: %v", ErrInvalidToken, err)
+	}
+	if now.After(exp.Add(v.leeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if jc.NotBefore != nil {
+		nbf, err := numericDate(*jc.NotBefore)
+		if err != nil {
+			return nil, fmt.Errorf("%w: nbf: %v", ErrInvalidToken, err)
+		}
+		if now.Add(v.leeway).Before(nbf) {
+			return nil, fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+		}
+	}
+	if v.issuer != "" && jc.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrInvalidToken, jc.Issuer)
+	}
+	switch {
+	case v.anyAudience:
+	case v.audience == "":
+		return nil, errNoAudience
+	case !slices.Contains(jc.Audience, v.audience):
+		return nil, fmt.Errorf("%w: token not issued for %s", ErrInvalidToken, v.audience)
+	}
+
+	c := &Claims{
+		Subject:   jc.Subject,
+		Issuer:    jc.Issuer,
+		Audience:  jc.Audience,
+		ClientID:  jc.ClientID,
+		Scopes:    strings.Fields(jc.Scope),
+		ExpiresAt: exp,
+		Raw:       raw,
+	}
+	if c.ClientID == "" {
+		c.ClientID = jc.AZP
+	}
+	if len(c.Scopes) == 0 && len(jc.Scp) > 0 {
+		// some providers use an scp array or string instead of scope
+		var scp audience
+		if err := json.Unmarshal(jc.Scp, &scp); err == nil {
+			for _, s := range scp {
+				c.Scopes = append(c.Scopes, strings.Fields(s)...)
+			}
+		}
+	}
+	return c, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func numericDate(n json.Number) (time.Time, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec := int64(f)
+	return time.Unix(sec, int64((f-float64(sec))*1e9)), nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed, sig []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		k, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		if !ed25519.Verify(k, signed, sig) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, sig); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[0] != 'E' || k.Curve.Params().BitSize != ecdsaBits[alg] || len(sig) != 2*size {
+			return fmt.Errorf("key does not match algorithm %s", alg)
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("key does not match algorithm %s", alg)
+	}
+}
+
+var ecdsaBits = map[string]int{"ES256": 256, "ES384": 384, "ES512": 521}
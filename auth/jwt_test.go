@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var b64 = base64.RawURLEncoding
+
+// signJWT signs claims with key, which is an *rsa.PrivateKey,
+// *ecdsa.PrivateKey or ed25519.PrivateKey.
+func signJWT(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	hdr, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	body, _ := json.Marshal(claims)
+	signed := b64.EncodeToString(hdr) + "." + b64.EncodeToString(body)
+	var sig []byte
+	var err error
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		digest := sha256.Sum256([]byte(signed))
+		sig, err = key.Sign(rand.Reader, digest[:], crypto.SHA256)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+	}
+	return signed + "." + b64.EncodeToString(sig)
+}
+
+// jwksFor returns a JWKS document for the public halves of keys by kid.
+func jwksFor(keys map[string]crypto.Signer) []byte {
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	for kid, key := range keys {
+		switch pub := key.Public().(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{"kty": "RSA", "kid": kid, "use": "sig",
+				"n": b64.EncodeToString(pub.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{"kty": "EC", "kid": kid, "crv": "P-256",
+				"x": b64.EncodeToString(pub.X.FillBytes(make([]byte, 32))), "y": b64.EncodeToString(pub.Y.FillBytes(make([]byte, 32)))})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, map[string]string{"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64.EncodeToString(pub)})
+		}
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func testKeys(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519: %v", err)
+	}
+	return map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey, "ed": edKey}
+}
+
+func TestJWTVerifier(t *testing.T) {
+	keys := testKeys(t)
+	set, err := NewStaticKeySet(jwksFor(keys))
+	if err != nil {
+		t.Fatalf("jwks: %v", err)
+	}
+	v := NewJWTVerifier(set, WithIssuer("https://idp.example.com"), WithAudience("https://mcp.example.com"))
+	ctx := context.Background()
+	valid := func() map[string]any {
+		return map[string]any{
+			"iss":   "https://idp.example.com",
+			"aud":   []string{"https://mcp.example.com"},
+			"sub":   "alice",
+			"scope": "tools:read tools:call",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	for kid, alg := range map[string]string{"rsa": "RS256", "ec": "ES256", "ed": "EdDSA"} {
+		claims, err := v.VerifyToken(ctx, signJWT(t, keys[kid], alg, kid, valid()))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if claims.Subject != "alice" || !claims.HasScopes("tools:call") || claims.HasScopes("admin") {
+			t.Fatalf("%s: unexpected claims %+v", alg, claims)
+		}
+	}
+
+	for name, mutate := range map[string]func(map[string]any){
+		"expired":        func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"missing exp":    func(c map[string]any) { delete(c, "exp") },
+		"not yet valid":  func(c map[string]any) { c["nbf"] = time.Now().Add(time.Hour).Unix() },
+		"wrong issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"wrong audience": func(c map[string]any) { c["aud"] = "https://other.example.com" },
+		"no audience":    func(c map[string]any) { delete(c, "aud") },
+	} {
+		c := valid()
+		mutate(c)
+		if _, err := v.VerifyToken(ctx, signJWT(t, keys["rsa"], "RS256", "rsa", c)); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+
+	token := signJWT(t, keys["rsa"], "RS256", "rsa", valid())
+	if _, err := NewJWTVerifier(set).VerifyToken(ctx, token); err == nil {
+		t.Fatalf("expected a verifier without an audience to reject tokens")
+	}
+	parts := strings.Split(token, ".")
+	forged, _ := json.Marshal(map[string]any{"sub": "mallory", "exp": time.Now().Add(time.Hour).Unix()})
+	for name, tok := range map[string]string{
+		"tampered":    parts[0] + "." + b64.EncodeToString(forged) + "." + parts[2],
+		"alg none":    b64.EncodeToString([]byte(`{"alg":"none","kid":"rsa"}`)) + "." + parts[1] + ".",
+		"wrong key":   signJWT(t, keys["ec"], "ES256", "rsa", valid()),
+		"unknown kid": signJWT(t, keys["rsa"], "RS256", "nope", valid()),
+		"garbage":     "not-a-jwt",
+	} {
+		if _, err := v.VerifyToken(ctx, tok); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("%s: expected ErrInvalidToken, got %v", name, err)
+		}
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	keys := testKeys(t)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(jwksFor(map[string]crypto.Signer{"rsa": keys["rsa"]}))
+	}))
+	defer srv.Close()
+
+	v := NewJWTVerifier(NewRemoteKeySet(srv.URL, srv.Client()), WithAnyAudience())
+	claims := map[string]any{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+	for i := 0; i < 3; i++ {
+		if _, err := v.VerifyToken(context.Background(), signJWT(t, keys["rsa"], "RS256", "rsa", claims)); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	}
+	// unknown keys do not trigger a refetch within a minute of the last one
+	if _, err := v.VerifyToken(context.Background(), signJWT(t, keys["ec"], "ES256", "ec", claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("expected keys to be fetched once, got %d", n)
+	}
+}
+
+func TestRemoteKeySetConcurrentRefresh(t *testing.T) {
+	keys := testKeys(t)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Write(jwksFor(map[string]crypto.Signer{"rsa": keys["rsa"]}))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	set := NewRemoteKeySet(srv.URL, srv.Client())
+	ctx := context.Background()
+	if _, err := set.Key(ctx, "rsa"); err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	set.mu.Lock()
+	set.fetched = time.Now().Add(-2 * jwksMinRefresh)
+	set.mu.Unlock()
+
+	// unknown keys refetch the set, once for all callers
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = set.Key(ctx, "rotated")
+		}()
+	}
+	for fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// known keys are served while the fetch is in progress
+	done := make(chan error, 1)
+	go func() {
+		_, err := set.Key(ctx, "rsa")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("key during refresh: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("cached key blocked by refresh")
+	}
+	// a caller can give up waiting
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := set.Key(cctx, "rotated"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	release <- struct{}{}
+	wg.Wait()
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("expected 2 fetches, got %d", n)
+	}
+}
+
+func TestMetadataURL(t *testing.T) {
+	for resource, want := range map[string]string{
+		"https://mcp.example.com":          "https://mcp.example.com/.well-known/oauth-protected-resource",
+		"https://mcp.example.com/mcp":      "https://mcp.example.com/.well-known/oauth-protected-resource/mcp",
+		"https://mcp.example.com:8443/a/b": "https://mcp.example.com:8443/.well-known/oauth-protected-resource/a/b",
+	} {
+		if got := (ProtectedResourceMetadata{Resource: resource}).MetadataURL(); got != want {
+			t.Fatalf("%s: got %s, want %s", resource, got, want)
+		}
+	}
+}
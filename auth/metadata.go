@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WellKnownPath is where protected resource metadata is served, RFC 9728.
+const WellKnownPath = "/.well-known/oauth-protected-resource"
+
+// ProtectedResourceMetadata describes an MCP server to OAuth clients,
+// telling them which authorization servers issue its tokens.
+type ProtectedResourceMetadata struct {
+	// Resource is the canonical URL of the MCP server, for example
+	// "https://mcp.example.com/mcp".
+	Resource               string   `json:"resource"`
+	AuthorizationServers   []string `json:"authorization_servers"`
+	ScopesSupported        []string `json:"scopes_supported,omitempty"`
+	BearerMethodsSupported []string `json:"bearer_methods_supported,omitempty"`
+	ResourceName           string   `json:"resource_name,omitempty"`
+	ResourceDocumentation  string   `json:"resource_documentation,omitempty"`
+}
+
+// MetadataURL returns the URL the metadata of the resource is served at:
+// the well-known path is inserted between the host and the path of
+// Resource.
+func (m ProtectedResourceMetadata) MetadataURL() string {
+	u, err := url.Parse(m.Resource)
+	if err != nil || u.Host == "" {
+		return WellKnownPath
+	}
+	u.Path = WellKnownPath + strings.TrimSuffix(u.Path, "/")
+	u.RawPath, u.RawQuery, u.Fragment = "", "", ""
+	return u.String()
+}
+
+// MetadataHandler serves m as JSON. Servers that mount the MCP endpoint on
+// their own mux should register it at WellKnownPath, and at the path of
+// MetadataURL when the resource has a path.
+func MetadataHandler(m ProtectedResourceMetadata) http.Handler {
+	if len(m.BearerMethodsSupported) == 0 {
+		m.BearerMethodsSupported = []string{"header"}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "max-age=3600")
+		_ = json.NewEncoder(w).Encode(m)
+	})
+}
+
+// Challenge returns the WWW-Authenticate header value for a request that
+// failed authorization with err, which may be nil when no token was sent.
+// scopes are the scopes the request needs, if known.
+func Challenge(metadataURL string, err error, scopes ...string) string {
+	params := []string{`resource_metadata="` + metadataURL + `"`}
+	switch {
+	case errors.Is(err, ErrInsufficientScope):
+		params = append(params, `error="insufficient_scope"`)
+	case err != nil:
+		params = append(params, `error="invalid_token"`)
+	}
+	if len(scopes) > 0 {
+		params = append(params, `scope="`+strings.Join(scopes, " ")+`"`)
+	}
+	return "Bearer " + strings.Join(params, ", ")
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
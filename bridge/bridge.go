@@ -0,0 +1,462 @@
+// Package bridge relays MCP traffic between two transports without
+// interpreting it, for example to offer a remote Streamable HTTP server to
+// a client that only speaks stdio, or the reverse.
+//
+// The downstream side is a server transport, such as
+// transport.StdioTransport or transport.ListenHTTP. Each downstream session
+// gets its own upstream connection from a Dialer, so a stdio server behind
+// an HTTP endpoint is started once per client. Requests, responses and
+// notifications are passed through in both directions, including requests
+// the upstream server sends to the client.
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// Dialer connects to the upstream server. The returned transport must
+// implement transport.Notifier, which is used to send it messages.
+type Dialer func(ctx context.Context) (transport.Transport, error)
+
+// CodeUpstreamClosed is the JSON-RPC error code returned for requests the
+// upstream can no longer answer.
+const CodeUpstreamClosed = -32001
+
+// Bridge relays messages between a downstream transport and upstream
+// connections.
+type Bridge struct {
+	down   transport.Transport
+	dial   Dialer
+	logger *log.Logger
+
+	mu    sync.Mutex
+	links map[string]*link
+	err   chan error
+}
+
+type Option func(*Bridge)
+
+// WithLogger sets the logger for upstream failures. The default is
+// log.Default().
+func WithLogger(l *log.Logger) Option {
+	return func(b *Bridge) { b.logger = l }
+}
+
+// New returns a bridge serving down and relaying to connections from dial.
+func New(down transport.Transport, dial Dialer, opts ...Option) *Bridge {
+	b := &Bridge{
+		down:   down,
+		dial:   dial,
+		logger: log.Default(),
+		links:  make(map[string]*link),
+		err:    make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Run relays messages until ctx is cancelled or the downstream transport
+// ends, in which case it returns nil. When the downstream transport has no
+// sessions, as with stdio, Run also returns once the upstream fails.
+func (b *Bridge) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer b.closeLinks()
+
+	type next struct {
+		conn transport.Conn
+		msg  json.RawMessage
+		err  error
+	}
+	msgs := make(chan next)
+	go func() {
+		for {
+			conn, msg, err := b.down.Next(ctx)
+			select {
+			case msgs <- next{conn, msg, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		var n next
+		select {
+		case n = <-msgs:
+		case err := <-b.err:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if errors.Is(n.err, io.EOF) {
+			return nil
+		}
+		if n.err != nil {
+			return n.err
+		}
+		for _, msg := range split(n.msg) {
+			b.relay(ctx, n.conn, msg)
+		}
+	}
+}
+
+// relay passes a downstream message to the session's upstream, connecting
+// it first if needed.
+func (b *Bridge) relay(ctx context.Context, conn transport.Conn, msg json.RawMessage) {
+	var sess *transport.Session
+	if sc, ok := conn.(transport.SessionConn); ok {
+		sess = sc.Session()
+	}
+	key := ""
+	if sess != nil {
+		key = sess.ID
+	}
+	b.mu.Lock()
+	l := b.links[key]
+	b.mu.Unlock()
+	if l == nil {
+		var err error
+		if l, err = b.connect(ctx, key, sess); err != nil {
+			b.logger.Printf("bridge: connecting upstream: %v", err)
+			if m := parse(msg); m.isRequest() {
+				_ = conn.Send(ctx, errorResponse(m.ID, err))
+			}
+			return
+		}
+	}
+	l.forward(ctx, conn, msg)
+}
+
+func (b *Bridge) connect(ctx context.Context, key string, sess *transport.Session) (*link, error) {
+	up, err := b.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := up.(transport.Notifier)
+	if !ok {
+		up.Close()
+		return nil, errors.New("bridge: upstream transport cannot send messages")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	l := &link{
+		b:        b,
+		key:      key,
+		sess:     sess,
+		up:       up,
+		notifier: n,
+		cancel:   cancel,
+		pending:  make(map[string]*pending),
+		replies:  make(map[string]transport.Conn),
+	}
+	b.mu.Lock()
+	b.links[key] = l
+	b.mu.Unlock()
+	go l.pump(ctx)
+	if sess != nil {
+		go func() {
+			select {
+			case <-sess.Done():
+				l.close(nil)
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return l, nil
+}
+
+func (b *Bridge) closeLinks() {
+	b.mu.Lock()
+	links := make([]*link, 0, len(b.links))
+	for _, l := range b.links {
+		links = append(links, l)
+	}
+	b.mu.Unlock()
+	for _, l := range links {
+		l.close(nil)
+	}
+}
+
+// link is the upstream connection of one downstream session.
+type link struct {
+	b        *Bridge
+	key      string
+	sess     *transport.Session
+	up       transport.Transport
+	notifier transport.Notifier
+	cancel   context.CancelFunc
+
+	mu sync.Mutex
+	// pending are the downstream requests awaiting an upstream response,
+	// by ID
+	pending map[string]*pending
+	// last is the most recently forwarded pending request
+	last *pending
+	// replies are the connections for answering upstream requests, by ID
+	replies map[string]transport.Conn
+	closed  bool
+}
+
+type pending struct {
+	conn          transport.Conn
+	method        string
+	progressToken string
+}
+
+func (l *link) forward(ctx context.Context, conn transport.Conn, msg json.RawMessage) {
+	m := parse(msg)
+	switch {
+	case m.isRequest():
+		p := &pending{conn: conn, method: m.Method, progressToken: idKey(m.Params.Meta.ProgressToken)}
+		l.mu.Lock()
+		if l.closed {
+			l.mu.Unlock()
+			_ = conn.Send(ctx, errorResponse(m.ID, errors.New("connection closed")))
+			return
+		}
+		l.pending[idKey(m.ID)] = p
+		l.last = p
+		l.mu.Unlock()
+		// an HTTP upstream may only return from Notify with the response,
+		// which must not hold up other messages such as cancellations
+		go func() {
+			if err := l.send(ctx, msg); err != nil {
+				l.mu.Lock()
+				p := l.pending[idKey(m.ID)]
+				delete(l.pending, idKey(m.ID))
+				l.mu.Unlock()
+				if p != nil {
+					_ = conn.Send(ctx, errorResponse(m.ID, err))
+				}
+			}
+		}()
+		return
+	case m.isResponse():
+		// the client answering a request from the upstream server
+		l.mu.Lock()
+		reply := l.replies[idKey(m.ID)]
+		delete(l.replies, idKey(m.ID))
+		l.mu.Unlock()
+		if reply != nil {
+			if err := reply.Send(ctx, msg); err != nil {
+				l.b.logger.Printf("bridge: relaying response: %v", err)
+			}
+			return
+		}
+	}
+	if err := l.send(ctx, msg); err != nil {
+		l.b.logger.Printf("bridge: relaying message: %v", err)
+	}
+}
+
+// send passes msg to the upstream. A failure to deliver it only affects
+// msg, unless the upstream session expired; a dead stdio server is noticed
+// by pump.
+func (l *link) send(ctx context.Context, msg json.RawMessage) error {
+	err := l.notifier.Notify(ctx, msg)
+	if errors.Is(err, client.ErrSessionExpired) {
+		l.close(err)
+	}
+	return err
+}
+
+// pump relays upstream messages downstream until the upstream fails.
+func (l *link) pump(ctx context.Context) {
+	for {
+		conn, raw, err := l.up.Next(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				l.close(err)
+			}
+			return
+		}
+		for _, msg := range split(raw) {
+			l.deliver(ctx, conn, msg)
+		}
+	}
+}
+
+func (l *link) deliver(ctx context.Context, conn transport.Conn, msg json.RawMessage) {
+	m := parse(msg)
+	if m.isResponse() {
+		l.mu.Lock()
+		p := l.pending[idKey(m.ID)]
+		delete(l.pending, idKey(m.ID))
+		if l.last == p {
+			l.last = nil
+		}
+		l.mu.Unlock()
+		if p == nil {
+			return
+		}
+		if p.method == "initialize" {
+			l.negotiated(msg)
+		}
+		if err := p.conn.Send(ctx, msg); err != nil {
+			l.b.logger.Printf("bridge: relaying response: %v", err)
+		}
+		return
+	}
+
+	// a notification or a request from the upstream server
+	l.mu.Lock()
+	if m.isRequest() {
+		l.replies[idKey(m.ID)] = conn
+	}
+	target := l.last
+	if m.Method == "notifications/progress" {
+		token := idKey(m.Params.ProgressToken)
+		for _, p := range l.pending {
+			if p.progressToken != "" && p.progressToken == token {
+				target = p
+				break
+			}
+		}
+	}
+	l.mu.Unlock()
+	if l.sess == nil {
+		// a single connection, such as stdio, carries everything
+		if n, ok := l.b.down.(transport.Notifier); ok {
+			_ = n.Notify(ctx, msg)
+			return
+		}
+	}
+	// HTTP delivers messages on the stream of a request in progress, or
+	// else on the session's GET stream
+	if target != nil && target.conn.Send(ctx, msg) == nil {
+		return
+	}
+	if l.sess != nil {
+		_ = l.sess.Notify(ctx, msg)
+	}
+}
+
+// negotiated tells an HTTP upstream the protocol version it agreed on, which
+// later requests must carry in a header.
+func (l *link) negotiated(msg json.RawMessage) {
+	spv, ok := l.up.(interface{ SetProtocolVersion(string) })
+	if !ok {
+		return
+	}
+	var resp struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+	}
+	if json.Unmarshal(msg, &resp) == nil && resp.Result.ProtocolVersion != "" {
+		spv.SetProtocolVersion(resp.Result.ProtocolVersion)
+	}
+}
+
+// close disconnects the upstream, failing the requests it did not answer.
+// A non-nil err reports an upstream failure.
+func (l *link) close(err error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	l.closed = true
+	pending := l.pending
+	l.pending = nil
+	l.mu.Unlock()
+
+	l.b.mu.Lock()
+	if l.b.links[l.key] == l {
+		delete(l.b.links, l.key)
+	}
+	l.b.mu.Unlock()
+	l.cancel()
+	l.up.Close()
+
+	if err == nil {
+		return
+	}
+	l.b.logger.Printf("bridge: upstream closed: %v", err)
+	for id, p := range pending {
+		_ = p.conn.Send(context.Background(), errorResponse(json.RawMessage(id), err))
+	}
+	if l.sess == nil {
+		select {
+		case l.b.err <- fmt.Errorf("bridge: upstream closed: %w", err):
+		default:
+		}
+	}
+}
+
+// message is the part of a JSON-RPC message the bridge routes by.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+		Meta          struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	} `json:"params"`
+}
+
+func parse(msg json.RawMessage) message {
+	var m message
+	_ = json.Unmarshal(msg, &m)
+	return m
+}
+
+func (m message) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+func (m message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// idKey returns a canonical form of a JSON value such as a request ID.
+func idKey(id json.RawMessage) string {
+	if len(id) == 0 {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// split returns the messages of a batch, or msg itself.
+func split(msg json.RawMessage) []json.RawMessage {
+	trimmed := bytes.TrimSpace(msg)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return []json.RawMessage{trimmed}
+	}
+	var msgs []json.RawMessage
+	if json.Unmarshal(trimmed, &msgs) != nil {
+		return []json.RawMessage{trimmed}
+	}
+	return msgs
+}
+
+func errorResponse(id json.RawMessage, err error) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"error": map[string]any{
+			"code":    CodeUpstreamClosed,
+			"message": "upstream unavailable: " + err.Error(),
+		},
+	})
+	return data
+}
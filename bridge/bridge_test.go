@@ -0,0 +1,232 @@
+package bridge_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/bridge"
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// pipeTransport is one end of an in-process stdio connection; closing it
+// closes the pipes.
+type pipeTransport struct {
+	transport.Transport
+	closers []io.Closer
+}
+
+func (p *pipeTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	return p.Transport.(transport.Notifier).Notify(ctx, msg)
+}
+
+func (p *pipeTransport) Close() error {
+	for _, c := range p.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// pipes returns the two ends of a stdio connection.
+func pipes() (*pipeTransport, *pipeTransport) {
+	aIn, bOut := io.Pipe()
+	bIn, aOut := io.Pipe()
+	closers := []io.Closer{aOut, bOut}
+	return &pipeTransport{transport.NewStdioTransport(aIn, aOut), closers},
+		&pipeTransport{transport.NewStdioTransport(bIn, bOut), closers}
+}
+
+type echoIn struct {
+	Text string `json:"text"`
+}
+
+func testRegistry() *registry.Registry {
+	reg := registry.New()
+	registry.RegisterTool(reg, "Echo", func(ctx context.Context, in echoIn) (string, error) {
+		_ = rpc.NotifyProgress(ctx, 1, 1, "echoing")
+		return in.Text, nil
+	})
+	return reg
+}
+
+var quiet = bridge.WithLogger(log.New(io.Discard, "", 0))
+
+func TestStdioToHTTP(t *testing.T) {
+	reg := testRegistry()
+	tr := transport.HTTPHandler()
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(reg, tr, rpc.WithListChanged()).Run(ctx) }()
+	defer tr.Close()
+
+	down, local := pipes()
+	b := bridge.New(down, func(ctx context.Context) (transport.Transport, error) {
+		return client.NewHTTPTransport(ts.URL), nil
+	}, quiet)
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	notes := make(chan string, 10)
+	c, err := client.Connect(ctx, local, client.WithNotificationHandler(func(ctx context.Context, method string, params json.RawMessage) {
+		notes <- method + " " + string(params)
+	}))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	var out string
+	if err := c.CallToolInto(ctx, "Echo", echoIn{Text: "hi"}, &out); err != nil || out != "hi" {
+		t.Fatalf("call: %q, %v", out, err)
+	}
+	if err := c.Call(ctx, "tools/call", map[string]any{
+		"name":      "Echo",
+		"arguments": echoIn{Text: "again"},
+		"_meta":     map[string]any{"progressToken": 7},
+	}, nil); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if n := <-notes; !strings.HasPrefix(n, "notifications/progress") || !strings.Contains(n, `"progressToken":7`) {
+		t.Fatalf("unexpected notification %s", n)
+	}
+
+	// messages outside of requests arrive over the GET stream
+	registry.RegisterTool(reg, "Other", func(ctx context.Context, in echoIn) (string, error) { return "", nil })
+	select {
+	case n := <-notes:
+		if !strings.HasPrefix(n, "notifications/tools/list_changed") {
+			t.Fatalf("unexpected notification %s", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("list_changed was not relayed")
+	}
+
+	local.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+}
+
+func TestHTTPToStdio(t *testing.T) {
+	reg := testRegistry()
+	var dials, running atomic.Int32
+	down := transport.HTTPHandler()
+	ts := httptest.NewServer(down)
+	defer ts.Close()
+	b := bridge.New(down, func(ctx context.Context) (transport.Transport, error) {
+		dials.Add(1)
+		running.Add(1)
+		srvEnd, bridgeEnd := pipes()
+		go func() {
+			defer running.Add(-1)
+			_ = rpc.NewServer(reg, srvEnd).Run(context.Background())
+		}()
+		return bridgeEnd, nil
+	}, quiet)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = b.Run(ctx) }()
+
+	var clients []*client.Client
+	for i := 0; i < 2; i++ {
+		c, err := client.Connect(ctx, client.NewHTTPTransport(ts.URL))
+		if err != nil {
+			t.Fatalf("connect: %v", err)
+		}
+		clients = append(clients, c)
+		var out string
+		if err := c.CallToolInto(ctx, "Echo", echoIn{Text: "hi"}, &out); err != nil || out != "hi" {
+			t.Fatalf("call: %q, %v", out, err)
+		}
+	}
+	if n := dials.Load(); n != 2 {
+		t.Fatalf("expected a server per session, got %d", n)
+	}
+	// ending a session stops its server
+	clients[0].Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for running.Load() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d servers running after the session ended", running.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := clients[1].Ping(ctx); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	clients[1].Close()
+}
+
+func TestServerRequests(t *testing.T) {
+	down, local := pipes()
+	srvEnd, bridgeEnd := pipes()
+	b := bridge.New(down, func(ctx context.Context) (transport.Transport, error) {
+		return bridgeEnd, nil
+	}, quiet)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	// a server asking the client for its roots while handling a call
+	go func() {
+		read := func() map[string]any {
+			_, raw, err := srvEnd.Next(ctx)
+			if err != nil {
+				return nil
+			}
+			var m map[string]any
+			_ = json.Unmarshal(raw, &m)
+			return m
+		}
+		send := func(m map[string]any) {
+			m["jsonrpc"] = "2.0"
+			data, _ := json.Marshal(m)
+			_ = srvEnd.Notify(ctx, data)
+		}
+		init := read()
+		send(map[string]any{"id": init["id"], "result": map[string]any{
+			"protocolVersion": "2025-03-26",
+			"capabilities":    map[string]any{},
+			"serverInfo":      map[string]any{"name": "scripted", "version": "1"},
+		}})
+		read() // notifications/initialized
+		call := read()
+		send(map[string]any{"id": "s1", "method": "roots/list"})
+		roots, _ := json.Marshal(read()["result"])
+		send(map[string]any{"id": call["id"], "result": map[string]any{
+			"content": []any{map[string]any{"type": "text", "text": string(roots)}},
+		}})
+	}()
+
+	c, err := client.Connect(ctx, local, client.WithRootsHandler(func(ctx context.Context) ([]client.Root, error) {
+		return []client.Root{{URI: "file:///work"}}, nil
+	}))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	res, err := c.CallTool(ctx, "Roots", nil)
+	if err != nil || len(res.Content) != 1 || !strings.Contains(res.Content[0].Data["text"].(string), "file:///work") {
+		t.Fatalf("call: %+v, %v", res, err)
+	}
+
+	// the bridge gives up when its only upstream goes away
+	srvEnd.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an upstream error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge kept running without its upstream")
+	}
+}
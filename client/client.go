@@ -0,0 +1,388 @@
+// Package client implements the client side of the Model Context Protocol.
+//
+// A Client speaks to a server over any transport.Transport that can also
+// send messages of its own (transport.Notifier): the stdio transport wired
+// to a subprocess' pipes, or the Streamable HTTP transport returned by
+// NewHTTPTransport.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// ProtocolVersion is the protocol revision requested during initialization.
+const ProtocolVersion = "2025-06-18"
+
+// SupportedProtocolVersions lists the revisions the client accepts from a
+// server, newest first.
+var SupportedProtocolVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ErrClosed is returned for calls made after the client was closed or the
+// connection to the server ended.
+var ErrClosed = errors.New("client: closed")
+
+// SamplingHandler answers "sampling/createMessage" requests from the server.
+type SamplingHandler func(ctx context.Context, req *CreateMessageRequest) (*CreateMessageResult, error)
+
+// RootsHandler answers "roots/list" requests from the server.
+type RootsHandler func(ctx context.Context) ([]Root, error)
+
+// ElicitationHandler answers "elicitation/create" requests from the server.
+type ElicitationHandler func(ctx context.Context, req *ElicitRequest) (*ElicitResult, error)
+
+// NotificationHandler is called for every notification sent by the server.
+type NotificationHandler func(ctx context.Context, method string, params json.RawMessage)
+
+type Option func(*Client)
+
+// WithClientInfo sets the name and version sent to the server.
+func WithClientInfo(name, version string) Option {
+	return func(c *Client) { c.info = Implementation{Name: name, Version: version} }
+}
+
+// WithSamplingHandler lets the server request completions from the client's
+// language model and advertises the sampling capability.
+func WithSamplingHandler(h SamplingHandler) Option {
+	return func(c *Client) { c.sampling = h }
+}
+
+// WithRootsHandler exposes client roots to the server and advertises the
+// roots capability.
+func WithRootsHandler(h RootsHandler) Option {
+	return func(c *Client) { c.roots = h }
+}
+
+// WithElicitationHandler lets the server ask the user for input and
+// advertises the elicitation capability.
+func WithElicitationHandler(h ElicitationHandler) Option {
+	return func(c *Client) { c.elicitation = h }
+}
+
+// WithNotificationHandler sets the handler for server notifications.
+// Notifications are delivered in order, off the client's read loop, so the
+// handler may call the client.
+func WithNotificationHandler(h NotificationHandler) Option {
+	return func(c *Client) { c.notify = h }
+}
+
+// Client is a connection to a single MCP server.
+type Client struct {
+	tr  transport.Transport
+	out transport.Notifier
+
+	info        Implementation
+	sampling    SamplingHandler
+	roots       RootsHandler
+	elicitation ElicitationHandler
+	notify      NotificationHandler
+
+	nextID  atomic.Int64
+	mu      sync.Mutex
+	pending map[string]chan *response
+	err     error
+
+	// notes holds the notifications waiting for the handler; notifying
+	// is set while a goroutine is delivering them.
+	notes     []*response
+	notifying bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	init   InitializeResult
+}
+
+// response is a JSON-RPC message received from the server. Requests and
+// notifications have a Method; responses have a Result or an Error.
+type response struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *rpc.Error      `json:"error,omitempty"`
+}
+
+// Connect starts reading from tr and performs the initialization handshake,
+// negotiating the protocol version with the server. The returned client
+// owns tr and closes it on Close.
+func Connect(ctx context.Context, tr transport.Transport, opts ...Option) (*Client, error) {
+	out, ok := tr.(transport.Notifier)
+	if !ok {
+		return nil, fmt.Errorf("client: transport %T cannot send messages", tr)
+	}
+	c := &Client{
+		tr:      tr,
+		out:     out,
+		info:    Implementation{Name: "cyrusaf/mcp", Version: "0.1.0"},
+		pending: make(map[string]chan *response),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.read()
+
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	caps := map[string]any{}
+	if c.sampling != nil {
+		caps["sampling"] = struct{}{}
+	}
+	if c.roots != nil {
+		caps["roots"] = map[string]bool{"listChanged": true}
+	}
+	if c.elicitation != nil {
+		caps["elicitation"] = struct{}{}
+	}
+	params := map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    caps,
+		"clientInfo":      c.info,
+	}
+	if err := c.Call(ctx, "initialize", params, &c.init); err != nil {
+		return fmt.Errorf("client: initialize: %w", err)
+	}
+	if !slices.Contains(SupportedProtocolVersions, c.init.ProtocolVersion) {
+		return fmt.Errorf("client: unsupported protocol version %q", c.init.ProtocolVersion)
+	}
+	if v, ok := c.tr.(interface{ SetProtocolVersion(string) }); ok {
+		v.SetProtocolVersion(c.init.ProtocolVersion)
+	}
+	return c.Notify(ctx, "notifications/initialized", nil)
+}
+
+// InitializeResult returns the server's answer to the handshake.
+func (c *Client) InitializeResult() InitializeResult { return c.init }
+
+// ProtocolVersion returns the negotiated protocol version.
+func (c *Client) ProtocolVersion() string { return c.init.ProtocolVersion }
+
+// Call sends a request and decodes its result into result, which may be
+// nil. Errors returned by the server are of type *rpc.Error. If ctx ends
+// first the server is told to cancel the request.
+func (c *Client) Call(ctx context.Context, method string, params, result any) error {
+	id := c.nextID.Add(1)
+	key := strconv.FormatInt(id, 10)
+	ch := make(chan *response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.pending[key] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+	}()
+
+	if err := c.send(ctx, map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		_ = c.Notify(context.WithoutCancel(ctx), "notifications/cancelled", map[string]any{
+			"requestId": id,
+			"reason":    ctx.Err().Error(),
+		})
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification to the server.
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	return c.send(ctx, map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *Client) send(ctx context.Context, msg map[string]any) error {
+	if msg["params"] == nil {
+		delete(msg, "params")
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.out.Notify(ctx, data)
+}
+
+// Done is closed once the connection to the server has ended.
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Err returns why the connection ended, or nil while it is open.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close closes the transport and fails all pending calls with ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	c.cancel()
+	return c.tr.Close()
+}
+
+// fail ends the connection with err unless it already ended.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+func (c *Client) read() {
+	for {
+		conn, raw, err := c.tr.Next(c.ctx)
+		if err != nil {
+			c.fail(fmt.Errorf("%w: %w", ErrClosed, err))
+			return
+		}
+		var msgs []json.RawMessage
+		if len(raw) > 0 && raw[0] == '[' {
+			if json.Unmarshal(raw, &msgs) != nil {
+				continue
+			}
+		} else {
+			msgs = []json.RawMessage{raw}
+		}
+		for _, m := range msgs {
+			c.dispatch(conn, m)
+		}
+	}
+}
+
+func (c *Client) dispatch(conn transport.Conn, raw json.RawMessage) {
+	var msg response
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+	hasID := len(msg.ID) > 0 && string(msg.ID) != "null"
+	switch {
+	case msg.Method == "" && hasID:
+		c.mu.Lock()
+		ch := c.pending[idKey(msg.ID)]
+		c.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	case msg.Method != "" && hasID:
+		go c.handleRequest(conn, &msg)
+	case msg.Method != "" && c.notify != nil:
+		c.mu.Lock()
+		c.notes = append(c.notes, &msg)
+		if !c.notifying {
+			c.notifying = true
+			go c.deliver()
+		}
+		c.mu.Unlock()
+	}
+}
+
+// deliver passes queued notifications to the handler one at a time until
+// the queue is empty.
+func (c *Client) deliver() {
+	for {
+		c.mu.Lock()
+		if len(c.notes) == 0 {
+			c.notifying = false
+			c.mu.Unlock()
+			return
+		}
+		msg := c.notes[0]
+		c.notes = c.notes[1:]
+		c.mu.Unlock()
+		c.notify(c.ctx, msg.Method, msg.Params)
+	}
+}
+
+// handleRequest answers a request sent by the server.
+func (c *Client) handleRequest(conn transport.Conn, req *response) {
+	var result any
+	var err error
+	switch {
+	case req.Method == "ping":
+		result = struct{}{}
+	case req.Method == "sampling/createMessage" && c.sampling != nil:
+		var p CreateMessageRequest
+		if err = json.Unmarshal(req.Params, &p); err == nil {
+			result, err = c.sampling(c.ctx, &p)
+		} else {
+			err = rpc.ErrorInvalidParams(err)
+		}
+	case req.Method == "roots/list" && c.roots != nil:
+		var roots []Root
+		if roots, err = c.roots(c.ctx); err == nil {
+			if roots == nil {
+				roots = []Root{}
+			}
+			result = map[string]any{"roots": roots}
+		}
+	case req.Method == "elicitation/create" && c.elicitation != nil:
+		var p ElicitRequest
+		if err = json.Unmarshal(req.Params, &p); err == nil {
+			result, err = c.elicitation(c.ctx, &p)
+		} else {
+			err = rpc.ErrorInvalidParams(err)
+		}
+	default:
+		err = rpc.ErrorMethodNotFound(req.Method)
+	}
+
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	var rerr *rpc.Error
+	switch {
+	case errors.As(err, &rerr):
+		resp["error"] = rerr
+	case err != nil:
+		resp["error"] = &rpc.Error{Code: -32603, Message: err.Error()}
+	default:
+		resp["result"] = result
+	}
+	data, _ := json.Marshal(resp)
+	if conn == nil {
+		_ = c.out.Notify(c.ctx, data)
+		return
+	}
+	_ = conn.Send(c.ctx, data)
+}
+
+// idKey returns a canonical form of a JSON-RPC id.
+func idKey(id json.RawMessage) string {
+	var n json.Number
+	if err := json.Unmarshal(id, &n); err == nil {
+		return n.String()
+	}
+	return string(id)
+}
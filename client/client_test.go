@@ -0,0 +1,343 @@
+package client_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type addIn struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addOut struct {
+	Sum int `json:"sum"`
+}
+
+func testRegistry() *registry.Registry {
+	reg := registry.New()
+	registry.RegisterTool(reg, "Add", func(ctx context.Context, in addIn) (addOut, error) {
+		return addOut{Sum: in.A + in.B}, nil
+	})
+	registry.RegisterResource(reg, "Greeting", "mem://greeting", func(ctx context.Context, uri string) (string, error) {
+		return "hello", nil
+	})
+	return reg
+}
+
+// exercise runs the same calls against a server offering testRegistry.
+func exercise(t *testing.T, c *client.Client) {
+	t.Helper()
+	ctx := context.Background()
+	if v := c.ProtocolVersion(); v != "2025-03-26" {
+		t.Fatalf("negotiated version %q", v)
+	}
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "Add" || tools[0].InputSchema == nil {
+		t.Fatalf("ListTools: %+v, %v", tools, err)
+	}
+	res, err := c.CallTool(ctx, "Add", addIn{A: 2, B: 3})
+	if err != nil || res.IsError || !strings.Contains(string(res.StructuredContent), `"sum":5`) {
+		t.Fatalf("CallTool: %+v, %v", res, err)
+	}
+	read, err := c.ReadResource(ctx, "mem://greeting")
+	if err != nil || len(read.Contents) != 1 || read.Contents[0].Text != `"hello"` {
+		t.Fatalf("ReadResource: %+v, %v", read, err)
+	}
+	var sum addOut
+	if err := c.CallToolInto(ctx, "Add", addIn{A: 4, B: 5}, &sum); err != nil || sum.Sum != 9 {
+		t.Fatalf("CallToolInto: %+v, %v", sum, err)
+	}
+	var greeting string
+	if err := c.ReadResourceInto(ctx, "mem://greeting", &greeting); err != nil || greeting != "hello" {
+		t.Fatalf("ReadResourceInto: %q, %v", greeting, err)
+	}
+	var rerr *rpc.Error
+	if _, err := c.CallTool(ctx, "Missing", nil); !errors.As(err, &rerr) || rerr.Code != -32601 {
+		t.Fatalf("expected method not found, got %v", err)
+	}
+}
+
+func TestClientStdio(t *testing.T) {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	srv := rpc.NewServer(testRegistry(), transport.NewStdioTransport(toServer, fromServer))
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(context.Background()) }()
+
+	c, err := client.Connect(context.Background(), transport.NewStdioTransport(toClient, fromClient))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	exercise(t, c)
+
+	fromClient.Close()
+	if err := <-done; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	fromServer.Close()
+	<-c.Done()
+	if _, err := c.ListTools(context.Background()); !errors.Is(err, client.ErrClosed) {
+		t.Fatalf("expected ErrClosed after the server exited, got %v", err)
+	}
+}
+
+func TestClientHTTP(t *testing.T) {
+	tr := transport.HTTPHandler()
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(testRegistry(), tr).Run(ctx) }()
+	defer tr.Close()
+
+	c, err := client.Connect(context.Background(), client.NewHTTPTransport(ts.URL))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+	exercise(t, c)
+}
+
+// scriptedServer answers the handshake, checking that the client advertised
+// caps, and returns functions to exchange raw messages with the client.
+func scriptedServer(t *testing.T, caps ...string) (transport.Transport, func(string), func() map[string]any) {
+	t.Helper()
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	t.Cleanup(func() { fromServer.Close(); fromClient.Close() })
+	in := bufio.NewScanner(toServer)
+	send := func(msg string) {
+		if _, err := io.WriteString(fromServer, msg+"\n"); err != nil {
+			t.Errorf("write: %v", err)
+		}
+	}
+	read := func() map[string]any {
+		var m map[string]any
+		if !in.Scan() {
+			t.Errorf("client closed the connection")
+		} else if err := json.Unmarshal(in.Bytes(), &m); err != nil {
+			t.Errorf("invalid message %s: %v", in.Bytes(), err)
+		}
+		return m
+	}
+	ready := make(chan struct{})
+	go func() {
+		defer close(ready)
+		init := read()
+		advertised, _ := init["params"].(map[string]any)["capabilities"].(map[string]any)
+		for _, c := range caps {
+			if _, ok := advertised[c]; !ok {
+				t.Errorf("%s capability not advertised: %v", c, init)
+			}
+		}
+		send(`{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-06-18","capabilities":{"prompts":{}},"serverInfo":{"name":"fake","version":"1"}}}`)
+		if m := read(); m["method"] != "notifications/initialized" {
+			t.Errorf("expected initialized notification, got %v", m)
+		}
+	}()
+	recv := func() map[string]any {
+		<-ready
+		return read()
+	}
+	return transport.NewStdioTransport(toClient, fromClient), send, recv
+}
+
+func TestClientServerRequests(t *testing.T) {
+	tr, send, recv := scriptedServer(t, "sampling", "roots", "elicitation")
+	notified := make(chan string, 1)
+	c, err := client.Connect(context.Background(), tr,
+		client.WithSamplingHandler(func(ctx context.Context, req *client.CreateMessageRequest) (*client.CreateMessageResult, error) {
+			return &client.CreateMessageResult{Role: "assistant", Content: rpc.NewTextContent("echo: " + req.Messages[0].Content.Data["text"].(string)), Model: "test"}, nil
+		}),
+		client.WithRootsHandler(func(ctx context.Context) ([]client.Root, error) {
+			return []client.Root{{URI: "file:///src", Name: "src"}}, nil
+		}),
+		client.WithElicitationHandler(func(ctx context.Context, req *client.ElicitRequest) (*client.ElicitResult, error) {
+			return nil, errors.New("user went away")
+		}),
+		client.WithNotificationHandler(func(ctx context.Context, method string, params json.RawMessage) {
+			notified <- method
+		}),
+	)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+	if c.InitializeResult().Capabilities.Prompts == nil || c.InitializeResult().ServerInfo.Name != "fake" {
+		t.Fatalf("unexpected initialize result %+v", c.InitializeResult())
+	}
+
+	send(`{"jsonrpc":"2.0","id":"s1","method":"sampling/createMessage","params":{"messages":[{"role":"user","content":{"type":"text","text":"hi"}}],"maxTokens":10}}`)
+	m := recv()
+	content, _ := m["result"].(map[string]any)["content"].(map[string]any)
+	if m["id"] != "s1" || content["text"] != "echo: hi" {
+		t.Fatalf("sampling response: %v", m)
+	}
+	send(`{"jsonrpc":"2.0","id":"s2","method":"roots/list"}`)
+	if m := recv(); !strings.Contains(toJSON(m), `"roots":[{"name":"src","uri":"file:///src"}]`) {
+		t.Fatalf("roots response: %v", m)
+	}
+	send(`{"jsonrpc":"2.0","id":"s3","method":"elicitation/create","params":{"message":"name?","requestedSchema":{"type":"object"}}}`)
+	if m := recv(); !strings.Contains(toJSON(m), `"message":"user went away"`) {
+		t.Fatalf("elicitation response: %v", m)
+	}
+	send(`{"jsonrpc":"2.0","id":"s4","method":"unknown/method"}`)
+	if m := recv(); !strings.Contains(toJSON(m), `"code":-32601`) {
+		t.Fatalf("unknown method response: %v", m)
+	}
+	send(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+	select {
+	case method := <-notified:
+		if method != "notifications/tools/list_changed" {
+			t.Fatalf("unexpected notification %s", method)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("notification not delivered")
+	}
+
+	go func() {
+		m := recv()
+		if m["method"] != "prompts/get" {
+			t.Errorf("unexpected request %v", m)
+		}
+		send(`{"jsonrpc":"2.0","id":` + toJSON(m["id"]) + `,"result":{"messages":[{"role":"user","content":{"type":"text","text":"review main.go"}}]}}`)
+	}()
+	prompt, err := c.GetPrompt(context.Background(), "review", map[string]string{"file": "main.go"})
+	if err != nil || len(prompt.Messages) != 1 || prompt.Messages[0].Content.Data["text"] != "review main.go" {
+		t.Fatalf("GetPrompt: %+v, %v", prompt, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		recv() // the request
+		cancel()
+		if m := recv(); m["method"] != "notifications/cancelled" {
+			t.Errorf("expected cancellation, got %v", m)
+		}
+	}()
+	if _, err := c.ListPrompts(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestClientRejectsUnknownVersion(t *testing.T) {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	defer fromServer.Close()
+	go func() {
+		in := bufio.NewScanner(toServer)
+		in.Scan()
+		io.WriteString(fromServer, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"1999-01-01","capabilities":{},"serverInfo":{"name":"old","version":"1"}}}`+"\n")
+		for in.Scan() {
+		}
+	}()
+	_, err := client.Connect(context.Background(), transport.NewStdioTransport(toClient, fromClient))
+	if err == nil || !strings.Contains(err.Error(), "unsupported protocol version") {
+		t.Fatalf("expected version error, got %v", err)
+	}
+}
+
+func toJSON(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func TestListPagination(t *testing.T) {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	defer fromServer.Close()
+	requests := make(chan string, 2)
+	go func() {
+		in := bufio.NewScanner(toServer)
+		in.Scan()
+		io.WriteString(fromServer, `{"jsonrpc":"2.0","id":1,"result":{"protocolVersion":"2025-03-26","capabilities":{},"serverInfo":{"name":"paged","version":"1"}}}`+"\n")
+		in.Scan() // notifications/initialized
+		for page := 0; in.Scan(); page++ {
+			requests <- in.Text()
+			var req struct{ ID json.RawMessage }
+			_ = json.Unmarshal(in.Bytes(), &req)
+			tools := `[{"name":"A","inputSchema":{"type":"object"}}],"nextCursor":"p2"`
+			if page > 0 {
+				tools = `[{"name":"B","inputSchema":{"type":"object"}}]`
+			}
+			io.WriteString(fromServer, `{"jsonrpc":"2.0","id":`+string(req.ID)+`,"result":{"tools":`+tools+`}}`+"\n")
+		}
+	}()
+	c, err := client.Connect(context.Background(), transport.NewStdioTransport(toClient, fromClient))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+	tools, err := c.ListTools(context.Background())
+	if err != nil || len(tools) != 2 || tools[0].Name != "A" || tools[1].Name != "B" {
+		t.Fatalf("ListTools: %+v, %v", tools, err)
+	}
+	// the first page is requested without params rather than "params":null
+	if first := <-requests; strings.Contains(first, `"params"`) {
+		t.Fatalf("unexpected first request %s", first)
+	}
+	if second := <-requests; !strings.Contains(second, `"params":{"cursor":"p2"}`) {
+		t.Fatalf("unexpected second request %s", second)
+	}
+}
+
+func TestClientNotificationHandlerCalls(t *testing.T) {
+	tr, send, recv := scriptedServer(t)
+	var c *client.Client
+	got := make(chan string, 2)
+	ready := make(chan struct{})
+	c, err := client.Connect(context.Background(), tr,
+		client.WithNotificationHandler(func(ctx context.Context, method string, params json.RawMessage) {
+			<-ready
+			if method != "notifications/tools/list_changed" {
+				got <- method
+				return
+			}
+			ctx, cancel := context.WithTimeout(ctx, time.Second)
+			defer cancel()
+			tools, err := c.ListTools(ctx)
+			if err != nil || len(tools) != 1 {
+				got <- fmt.Sprintf("ListTools: %+v, %v", tools, err)
+				return
+			}
+			got <- tools[0].Name
+		}),
+	)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+	close(ready)
+
+	send(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+	send(`{"jsonrpc":"2.0","method":"notifications/message"}`)
+	m := recv()
+	if m["method"] != "tools/list" {
+		t.Fatalf("unexpected request %v", m)
+	}
+	send(`{"jsonrpc":"2.0","id":` + toJSON(m["id"]) + `,"result":{"tools":[{"name":"Add","inputSchema":{"type":"object"}}]}}`)
+	for _, want := range []string{"Add", "notifications/message"} {
+		select {
+		case s := <-got:
+			if s != want {
+				t.Fatalf("got %q, want %q", s, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("handler blocked waiting for %q", want)
+		}
+	}
+}
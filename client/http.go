@@ -0,0 +1,243 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// ErrSessionExpired is returned when the server no longer knows the
+// session negotiated during initialization.
+var ErrSessionExpired = errors.New("client: session expired")
+
+type httpTransport struct {
+	endpoint string
+	client   *http.Client
+	header   http.Header
+
+	mu      sync.Mutex
+	session string
+	version string
+
+	in        chan json.RawMessage
+	ctx       context.Context
+	cancel    context.CancelFunc
+	listen    sync.Once
+	closeOnce sync.Once
+}
+
+// HTTPOption configures the transport returned by NewHTTPTransport.
+type HTTPOption func(*httpTransport)
+
+// WithHTTPClient sets the client used for requests. It defaults to
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) HTTPOption {
+	return func(t *httpTransport) { t.client = c }
+}
+
+// WithHeader adds a header to every request.
+func WithHeader(key, value string) HTTPOption {
+	return func(t *httpTransport) { t.header.Add(key, value) }
+}
+
+// WithBearerToken authorizes every request with an OAuth bearer token.
+func WithBearerToken(token string) HTTPOption {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// NewHTTPTransport returns the client side of the Streamable HTTP transport
+// for the server at endpoint. Every outgoing message is POSTed; responses
+// arrive as JSON or as an event stream and are returned by Next. Once a
+// session is established a GET stream is opened for messages the server
+// sends on its own, if the server offers one.
+func NewHTTPTransport(endpoint string, opts ...HTTPOption) transport.Transport {
+	t := &httpTransport{
+		endpoint: endpoint,
+		client:   http.DefaultClient,
+		header:   make(http.Header),
+		in:       make(chan json.RawMessage),
+	}
+	t.ctx, t.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// SetProtocolVersion sets the negotiated version sent with later requests.
+func (t *httpTransport) SetProtocolVersion(v string) {
+	t.mu.Lock()
+	t.version = v
+	t.mu.Unlock()
+}
+
+// SessionID returns the session assigned by the server, if any.
+func (t *httpTransport) SessionID() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.header {
+		req.Header[k] = v
+	}
+	t.mu.Lock()
+	if t.session != "" {
+		req.Header.Set(transport.SessionHeader, t.session)
+	}
+	if t.version != "" {
+		req.Header.Set("Mcp-Protocol-Version", t.version)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+// Notify POSTs msg to the server and queues any messages it answers with.
+func (t *httpTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	select {
+	case <-t.ctx.Done():
+		return ErrClosed
+	default:
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	if id := resp.Header.Get(transport.SessionHeader); id != "" {
+		t.mu.Lock()
+		t.session = id
+		t.mu.Unlock()
+		t.listen.Do(func() { go t.listenGet() })
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusNoContent:
+		resp.Body.Close()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && t.SessionID() != "":
+		resp.Body.Close()
+		return ErrSessionExpired
+	case resp.StatusCode != http.StatusOK:
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("client: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	mt, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mt == "text/event-stream" {
+		go func() {
+			defer resp.Body.Close()
+			t.readEvents(resp.Body)
+		}()
+		return nil
+	}
+	defer resp.Body.Close()
+	var body json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("client: decoding response: %w", err)
+	}
+	go t.push(body)
+	return nil
+}
+
+// listenGet opens the stream for messages the server sends outside of a
+// request. Servers that do not offer one answer 405.
+func (t *httpTransport) listenGet() {
+	req, err := t.newRequest(t.ctx, http.MethodGet, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	t.readEvents(resp.Body)
+}
+
+// readEvents queues the data of every message event in an event stream.
+func (t *httpTransport) readEvents(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), transport.DefaultMaxLineSize)
+	var event string
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if len(data) > 0 && (event == "" || event == "message") {
+				t.push(json.RawMessage(strings.Join(data, "\n")))
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		}
+	}
+}
+
+func (t *httpTransport) push(msg json.RawMessage) {
+	select {
+	case t.in <- msg:
+	case <-t.ctx.Done():
+	}
+}
+
+type httpClientConn struct{ t *httpTransport }
+
+// Send POSTs a reply to a request made by the server.
+func (c httpClientConn) Send(ctx context.Context, msg json.RawMessage) error {
+	return c.t.Notify(ctx, msg)
+}
+
+func (t *httpTransport) Next(ctx context.Context) (transport.Conn, json.RawMessage, error) {
+	select {
+	case msg := <-t.in:
+		return httpClientConn{t}, msg, nil
+	case <-t.ctx.Done():
+		return nil, nil, io.EOF
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Close ends the session on the server and stops all streams.
+func (t *httpTransport) Close() error {
+	t.closeOnce.Do(func() {
+		if t.SessionID() != "" {
+			if req, err := t.newRequest(context.Background(), http.MethodDelete, nil); err == nil {
+				if resp, err := t.client.Do(req); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}
+		t.cancel()
+	})
+	return nil
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cyrusaf/mcp/rpc"
+)
+
+// list collects every page of a paginated list method, whose results are
+// found under key.
+func list[T any](ctx context.Context, c *Client, method, key string) ([]T, error) {
+	all := []T{}
+	cursor := ""
+	for {
+		var params any
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+		var page map[string]json.RawMessage
+		if err := c.Call(ctx, method, params, &page); err != nil {
+			return nil, err
+		}
+		var items []T
+		if raw, ok := page[key]; ok {
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, err
+			}
+		}
+		all = append(all, items...)
+		var next string
+		if raw, ok := page["nextCursor"]; ok {
+			_ = json.Unmarshal(raw, &next)
+		}
+		if next == "" || next == cursor {
+			return all, nil
+		}
+		cursor = next
+	}
+}
+
+// Ping checks that the server is responsive.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.Call(ctx, "ping", nil, nil)
+}
+
+// ListTools returns every tool offered by the server.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	return list[Tool](ctx, c, "tools/list", "tools")
+}
+
+// CallTool calls the named tool with args, which must marshal to a JSON
+// object. A failing tool is reported through CallToolResult.IsError rather
+// than an error.
+func (c *Client) CallTool(ctx context.Context, name string, args any) (*CallToolResult, error) {
+	params := map[string]any{"name": name}
+	if args != nil {
+		params["arguments"] = args
+	}
+	var res CallToolResult
+	if err := c.Call(ctx, "tools/call", params, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListResources returns every concrete resource offered by the server.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	return list[Resource](ctx, c, "resources/list", "resources")
+}
+
+// ListResourceTemplates returns every resource template offered by the
+// server.
+func (c *Client) ListResourceTemplates(ctx context.Context) ([]ResourceTemplate, error) {
+	return list[ResourceTemplate](ctx, c, "resources/templates/list", "resourceTemplates")
+}
+
+// ReadResource reads the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) (*rpc.ResourceReadResult, error) {
+	var res rpc.ResourceReadResult
+	if err := c.Call(ctx, "resources/read", map[string]any{"uri": uri}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListPrompts returns every prompt offered by the server.
+func (c *Client) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	return list[Prompt](ctx, c, "prompts/list", "prompts")
+}
+
+// GetPrompt renders the named prompt with args.
+func (c *Client) GetPrompt(ctx context.Context, name string, args map[string]string) (*GetPromptResult, error) {
+	params := map[string]any{"name": name}
+	if args != nil {
+		params["arguments"] = args
+	}
+	var res GetPromptResult
+	if err := c.Call(ctx, "prompts/get", params, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ToolError is returned by CallToolInto when the tool reported a failure.
+type ToolError struct {
+	Name    string
+	Content []rpc.ContentItem
+}
+
+func (e *ToolError) Error() string {
+	var texts []string
+	for _, item := range e.Content {
+		if text, ok := item.Data["text"].(string); ok {
+			texts = append(texts, text)
+		}
+	}
+	return fmt.Sprintf("tool %s failed: %s", e.Name, strings.Join(texts, "\n"))
+}
+
+// CallToolInto calls the named tool and decodes its structured content, or
+// the JSON text of its first content item, into out.
+func (c *Client) CallToolInto(ctx context.Context, name string, args, out any) error {
+	res, err := c.CallTool(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	if res.IsError {
+		return &ToolError{Name: name, Content: res.Content}
+	}
+	data := []byte(res.StructuredContent)
+	if len(data) == 0 {
+		if len(res.Content) == 0 {
+			return fmt.Errorf("client: tool %s returned no content", name)
+		}
+		text, _ := res.Content[0].Data["text"].(string)
+		data = []byte(text)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("client: decoding result of tool %s: %w", name, err)
+	}
+	return nil
+}
+
+// ReadResourceInto reads the resource at uri and decodes its first JSON
+// text content into out.
+func (c *Client) ReadResourceInto(ctx context.Context, uri string, out any) error {
+	res, err := c.ReadResource(ctx, uri)
+	if err != nil {
+		return err
+	}
+	if len(res.Contents) == 0 {
+		return fmt.Errorf("client: resource %s has no contents", uri)
+	}
+	if err := json.Unmarshal([]byte(res.Contents[0].Text), out); err != nil {
+		return fmt.Errorf("client: decoding resource %s: %w", uri, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"encoding/json"
+
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/schema"
+)
+
+// Implementation names a client or server.
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Tool is a tool offered by the server.
+type Tool struct {
+	Name         string         `json:"name"`
+	Description  string         `json:"description,omitempty"`
+	InputSchema  *schema.Schema `json:"inputSchema"`
+	OutputSchema *schema.Schema `json:"outputSchema,omitempty"`
+}
+
+// UnmarshalJSON keeps the schemas verbatim, so they can be listed again
+// unchanged, for example by a gateway.
+func (t *Tool) UnmarshalJSON(b []byte) error {
+	var in struct {
+		Name         string          `json:"name"`
+		Description  string          `json:"description"`
+		InputSchema  json.RawMessage `json:"inputSchema"`
+		OutputSchema json.RawMessage `json:"outputSchema"`
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	*t = Tool{Name: in.Name, Description: in.Description}
+	var err error
+	if len(in.InputSchema) > 0 && string(in.InputSchema) != "null" {
+		if t.InputSchema, err = schema.FromJSON(in.InputSchema); err != nil {
+			return err
+		}
+	}
+	if len(in.OutputSchema) > 0 && string(in.OutputSchema) != "null" {
+		if t.OutputSchema, err = schema.FromJSON(in.OutputSchema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CallToolResult is the result of a tool call. IsError reports a failure of
+// the tool itself, described by Content.
+type CallToolResult struct {
+	Content           []rpc.ContentItem `json:"content,omitempty"`
+	StructuredContent json.RawMessage   `json:"structuredContent,omitempty"`
+	IsError           bool              `json:"isError,omitempty"`
+}
+
+// Resource is a concrete resource offered by the server.
+type Resource struct {
+	Name        string `json:"name"`
+	URI         string `json:"uri"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceTemplate describes a family of resources by URI template.
+type ResourceTemplate struct {
+	Name        string `json:"name"`
+	URITemplate string `json:"uriTemplate"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Prompt is a prompt template offered by the server.
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// GetPromptResult is a prompt rendered with its arguments.
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type PromptMessage struct {
+	Role    string          `json:"role"`
+	Content rpc.ContentItem `json:"content"`
+}
+
+// CreateMessageRequest asks the client to sample from its language model,
+// the "sampling/createMessage" request.
+type CreateMessageRequest struct {
+	Messages         []SamplingMessage `json:"messages"`
+	ModelPreferences json.RawMessage   `json:"modelPreferences,omitempty"`
+	SystemPrompt     string            `json:"systemPrompt,omitempty"`
+	IncludeContext   string            `json:"includeContext,omitempty"`
+	Temperature      *float64          `json:"temperature,omitempty"`
+	MaxTokens        int               `json:"maxTokens"`
+	StopSequences    []string          `json:"stopSequences,omitempty"`
+	Metadata         json.RawMessage   `json:"metadata,omitempty"`
+}
+
+type SamplingMessage struct {
+	Role    string          `json:"role"`
+	Content rpc.ContentItem `json:"content"`
+}
+
+type CreateMessageResult struct {
+	Role       string          `json:"role"`
+	Content    rpc.ContentItem `json:"content"`
+	Model      string          `json:"model"`
+	StopReason string          `json:"stopReason,omitempty"`
+}
+
+// Root is a directory or file the client exposes to the server.
+type Root struct {
+	URI  string `json:"uri"`
+	Name string `json:"name,omitempty"`
+}
+
+// ElicitRequest asks the user for structured input, the
+// "elicitation/create" request.
+type ElicitRequest struct {
+	Message         string         `json:"message"`
+	RequestedSchema *schema.Schema `json:"requestedSchema"`
+}
+
+// ElicitResult is the user's answer. Action is "accept", "decline" or
+// "cancel"; Content is only set when accepting.
+type ElicitResult struct {
+	Action  string         `json:"action"`
+	Content map[string]any `json:"content,omitempty"`
+}
+
+// InitializeResult is the server's answer to the initialization handshake.
+type InitializeResult struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ServerCapabilities `json:"capabilities"`
+	ServerInfo      Implementation     `json:"serverInfo"`
+	Instructions    string             `json:"instructions,omitempty"`
+}
+
+// ServerCapabilities lists the optional features offered by the server.
+// A nil field means the feature is not offered.
+type ServerCapabilities struct {
+	Tools     *ListChangedCapability `json:"tools,omitempty"`
+	Resources *ResourcesCapability   `json:"resources,omitempty"`
+	Prompts   *ListChangedCapability `json:"prompts,omitempty"`
+	Logging   json.RawMessage        `json:"logging,omitempty"`
+}
+
+type ListChangedCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type ResourcesCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+	Subscribe   bool `json:"subscribe,omitempty"`
+}
@@ -0,0 +1,394 @@
+// Package clientgen generates typed Go clients for the tools and resource
+// templates of a registry.
+//
+// Each tool registered with registry.RegisterTool[Req, Resp] becomes a
+// method taking Req and returning Resp, and each resource template becomes
+// a method taking its URI variables and returning the resource type. Tools
+// and templates registered with the raw variants, such as
+// registry.RegisterRawTool, have no Go types and are left out. The
+// generated client wraps a *client.Client, so it works over any transport.
+package clientgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/cyrusaf/mcp/registry"
+)
+
+type generator struct {
+	pkg      string
+	pkgPath  string
+	typeName string
+
+	// imports maps import paths to their names in the generated file.
+	imports map[string]string
+	names   map[string]bool
+}
+
+type Option func(*generator)
+
+// WithTypeName sets the name of the generated client type. It defaults to
+// "Client".
+func WithTypeName(name string) Option {
+	return func(g *generator) { g.typeName = name }
+}
+
+// WithPackagePath sets the import path of the generated package, so that
+// types declared in it are referenced without an import.
+func WithPackagePath(path string) Option {
+	return func(g *generator) { g.pkgPath = path }
+}
+
+// Generate returns the formatted source of package pkg declaring a typed
+// client for reg. Types used by tools and templates are referenced from
+// their own packages, so they must not be declared in package main.
+func Generate(reg *registry.Registry, pkg string, opts ...Option) ([]byte, error) {
+	g := &generator{
+		pkg:      pkg,
+		typeName: "Client",
+		imports:  map[string]string{"context": "context", "github.com/cyrusaf/mcp/client": "client"},
+		names:    map[string]bool{"context": true, "client": true},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	if !token.IsIdentifier(pkg) {
+		return nil, fmt.Errorf("clientgen: invalid package name %q", pkg)
+	}
+	if !token.IsExported(g.typeName) {
+		return nil, fmt.Errorf("clientgen: type name %q is not exported", g.typeName)
+	}
+
+	var body bytes.Buffer
+	methods := map[string]string{}
+	claim := func(method, what string) error {
+		if prev, ok := methods[method]; ok {
+			return fmt.Errorf("clientgen: %s and %s both map to method %s", prev, what, method)
+		}
+		methods[method] = what
+		return nil
+	}
+
+	tools := reg.Tools()
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	for _, t := range tools {
+		desc := reg.FindTool(t.Name)
+		if desc == nil || desc.Handler == nil {
+			// removed since listing, or raw
+			continue
+		}
+		method := exportedName(t.Name)
+		if err := claim(method, "tool "+t.Name); err != nil {
+			return nil, err
+		}
+		in, err := g.typeExpr(desc.Handler.Req())
+		if err != nil {
+			return nil, fmt.Errorf("clientgen: tool %s: %w", t.Name, err)
+		}
+		out, err := g.typeExpr(desc.Handler.Resp())
+		if err != nil {
+			return nil, fmt.Errorf("clientgen: tool %s: %w", t.Name, err)
+		}
+		fmt.Fprintf(&body, "\n// %s calls the %q tool.", method, t.Name)
+		writeDescription(&body, t.Description)
+		fmt.Fprintf(&body, `
+func (c *%[1]s) %[2]s(ctx context.Context, in %[3]s) (%[4]s, error) {
+	var out %[4]s
+	err := c.mcp.CallToolInto(ctx, %[5]q, in, &out)
+	return out, err
+}
+`, g.typeName, method, in, out, t.Name)
+	}
+
+	templates := reg.ResourceTemplates()
+	sort.Slice(templates, func(i, j int) bool { return templates[i].URITemplate < templates[j].URITemplate })
+	for _, t := range templates {
+		desc := reg.FindResourceTemplate(t.URITemplate)
+		if desc == nil || desc.Handler == nil {
+			continue
+		}
+		method := "Read" + exportedName(t.Name)
+		if err := claim(method, "resource template "+t.URITemplate); err != nil {
+			return nil, err
+		}
+		out, err := g.typeExpr(desc.Handler.Resp())
+		if err != nil {
+			return nil, fmt.Errorf("clientgen: resource template %s: %w", t.URITemplate, err)
+		}
+		params, uri, err := g.expandTemplate(t.URITemplate)
+		if err != nil {
+			return nil, fmt.Errorf("clientgen: resource template %s: %w", t.URITemplate, err)
+		}
+		var args strings.Builder
+		for _, p := range params {
+			fmt.Fprintf(&args, ", %s string", p)
+		}
+		fmt.Fprintf(&body, "\n// %s reads the %q resource at %s.", method, t.Name, t.URITemplate)
+		if t.Description != nil {
+			writeDescription(&body, *t.Description)
+		}
+		fmt.Fprintf(&body, `
+func (c *%[1]s) %[2]s(ctx context.Context%[3]s) (%[4]s, error) {
+	var out %[4]s
+	err := c.mcp.ReadResourceInto(ctx, %[5]s, &out)
+	return out, err
+}
+`, g.typeName, method, args.String(), out, uri)
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by mcp-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	var std, other []string
+	for p := range g.imports {
+		if strings.Contains(strings.Split(p, "/")[0], ".") {
+			other = append(other, p)
+		} else {
+			std = append(std, p)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for i, group := range [][]string{std, other} {
+		if i > 0 && len(std) > 0 && len(other) > 0 {
+			src.WriteString("\n")
+		}
+		for _, p := range group {
+			if name := g.imports[p]; name != path.Base(p) {
+				fmt.Fprintf(&src, "\t%s %q\n", name, p)
+			} else {
+				fmt.Fprintf(&src, "\t%q\n", p)
+			}
+		}
+	}
+	fmt.Fprintf(&src, `)
+
+// %[1]s calls the tools and resources of an MCP server with typed
+// arguments and results.
+type %[1]s struct {
+	mcp *client.Client
+}
+
+// New%[1]s wraps an initialized MCP client.
+func New%[1]s(c *client.Client) *%[1]s {
+	return &%[1]s{mcp: c}
+}
+
+// MCP returns the underlying MCP client.
+func (c *%[1]s) MCP() *client.Client {
+	return c.mcp
+}
+`, g.typeName)
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("clientgen: formatting generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+func writeDescription(w *bytes.Buffer, desc string) {
+	desc = strings.TrimSpace(desc)
+	if desc == "" {
+		return
+	}
+	w.WriteString("\n//")
+	for _, line := range strings.Split(desc, "\n") {
+		w.WriteString("\n// " + strings.TrimSpace(line))
+	}
+}
+
+// importName returns the name under which pkgPath is imported, adding the
+// import if needed.
+func (g *generator) importName(pkgPath string) string {
+	if name, ok := g.imports[pkgPath]; ok {
+		return name
+	}
+	base := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, path.Base(pkgPath))
+	if base == "" || unicode.IsDigit([]rune(base)[0]) {
+		base = "pkg" + base
+	}
+	name := base
+	for i := 2; g.names[name] || token.IsKeyword(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	g.imports[pkgPath] = name
+	g.names[name] = true
+	return name
+}
+
+// typeExpr returns the Go expression for t in the generated file.
+func (g *generator) typeExpr(t reflect.Type) (string, error) {
+	if t.Name() != "" {
+		switch {
+		case strings.Contains(t.Name(), "["):
+			return "", fmt.Errorf("generic type %s is not supported", t)
+		case t.PkgPath() == "":
+			return t.Name(), nil
+		case t.PkgPath() == "main":
+			return "", fmt.Errorf("type %s is declared in package main and cannot be imported", t)
+		case t.PkgPath() == g.pkgPath:
+			return t.Name(), nil
+		case !token.IsExported(t.Name()):
+			return "", fmt.Errorf("type %s is not exported", t)
+		}
+		return g.importName(t.PkgPath()) + "." + t.Name(), nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem, err := g.typeExpr(t.Elem())
+		return "*" + elem, err
+	case reflect.Slice:
+		elem, err := g.typeExpr(t.Elem())
+		return "[]" + elem, err
+	case reflect.Array:
+		elem, err := g.typeExpr(t.Elem())
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), err
+	case reflect.Map:
+		key, err := g.typeExpr(t.Key())
+		if err != nil {
+			return "", err
+		}
+		elem, err := g.typeExpr(t.Elem())
+		return "map[" + key + "]" + elem, err
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "any", nil
+		}
+	case reflect.Struct:
+		var b strings.Builder
+		b.WriteString("struct {")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				return "", fmt.Errorf("anonymous struct %s has unexported field %s", t, f.Name)
+			}
+			ft, err := g.typeExpr(f.Type)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("\n")
+			if !f.Anonymous {
+				b.WriteString(f.Name + " ")
+			}
+			b.WriteString(ft)
+			if tag := string(f.Tag); tag != "" && !strings.Contains(tag, "`") {
+				b.WriteString(" `" + tag + "`")
+			} else if tag != "" {
+				b.WriteString(" " + strconv.Quote(tag))
+			}
+		}
+		if t.NumField() > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("}")
+		return b.String(), nil
+	}
+	return "", fmt.Errorf("type %s cannot be sent as JSON", t)
+}
+
+// expandTemplate returns the parameter names of an RFC 6570 level 1 or 2
+// URI template and a Go expression building the URI from them. Simple
+// variables are escaped; reserved ({+var}) ones are inserted as is.
+func (g *generator) expandTemplate(tmpl string) ([]string, string, error) {
+	var params, parts []string
+	seen := map[string]bool{}
+	if strings.Contains(strings.ReplaceAll(tmpl, "{+", ""), "{") {
+		g.importName("net/url")
+	}
+	rest := tmpl
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			parts = append(parts, strconv.Quote(rest))
+			break
+		}
+		if open > 0 {
+			parts = append(parts, strconv.Quote(rest[:open]))
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated expression")
+		}
+		expr := rest[open+1 : open+end]
+		rest = rest[open+end+1:]
+		reserved := strings.HasPrefix(expr, "+")
+		expr = strings.TrimPrefix(expr, "+")
+		if expr == "" || strings.ContainsAny(expr, ",:*#./;?&=") {
+			return nil, "", fmt.Errorf("expression {%s} is not supported", expr)
+		}
+		param := identifier(expr, true)
+		if token.IsKeyword(param) || g.names[param] || param == "c" || param == "out" || param == "err" {
+			// avoid shadowing the receiver, locals and imported packages
+			param += "Arg"
+		}
+		if !seen[param] {
+			seen[param] = true
+			params = append(params, param)
+		}
+		if reserved {
+			parts = append(parts, param)
+		} else {
+			parts = append(parts, g.imports["net/url"]+".PathEscape("+param+")")
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{`""`}
+	}
+	return params, strings.Join(parts, " + "), nil
+}
+
+// exportedName converts a tool or resource name such as "create_user" or
+// "users.get" to an exported Go identifier.
+func exportedName(name string) string {
+	id := identifier(name, false)
+	r := []rune(id)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// identifier converts s to a Go identifier in camel case, starting with a
+// lower case letter if lower is set.
+func identifier(s string, lower bool) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if b.Len() == 0 && unicode.IsDigit(r) {
+				b.WriteByte('X')
+			}
+			if upper {
+				r = unicode.ToUpper(r)
+				upper = false
+			}
+			b.WriteRune(r)
+		default:
+			upper = b.Len() > 0
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "X"
+	}
+	if lower {
+		r := []rune(id)
+		r[0] = unicode.ToLower(r[0])
+		id = string(r)
+	}
+	return id
+}
@@ -0,0 +1,101 @@
+package clientgen_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyrusaf/mcp/clientgen"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+)
+
+func testRegistry() *registry.Registry {
+	reg := registry.New()
+	registry.RegisterTool(reg, "read_params", func(ctx context.Context, in rpc.ResourceReadParams) (rpc.ResourceReadResult, error) {
+		return rpc.ResourceReadResult{}, nil
+	}, registry.WithDescription("Echo the parameters."))
+	registry.RegisterTool(reg, "Search", func(ctx context.Context, in struct {
+		Query string `json:"query"`
+	}) ([]string, error) {
+		return nil, nil
+	})
+	registry.RegisterResourceTemplate(reg, "File", "files://{url}/{+path}", func(ctx context.Context, uri string) (rpc.ResourceContent, error) {
+		return rpc.ResourceContent{}, nil
+	})
+	registry.RegisterRawTool(reg, "Proxy", nil, func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	})
+	registry.RegisterRawResourceTemplate(reg, "Blob", "blobs://{id}", func(ctx context.Context, uri string) (json.RawMessage, error) {
+		return nil, nil
+	})
+	return reg
+}
+
+func TestGenerate(t *testing.T) {
+	src, err := clientgen.Generate(testRegistry(), "gentest")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	for _, want := range []string{
+		"// Code generated by mcp-gen. DO NOT EDIT.",
+		"func (c *Client) ReadParams(ctx context.Context, in rpc.ResourceReadParams) (rpc.ResourceReadResult, error) {",
+		"// Echo the parameters.",
+		"Query string `json:\"query\"`",
+		"}) ([]string, error) {",
+		`func (c *Client) ReadFile(ctx context.Context, urlArg string, path string) (rpc.ResourceContent, error) {`,
+		`"files://"+url.PathEscape(urlArg)+"/"+path`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Fatalf("generated code is missing %q:\n%s", want, src)
+		}
+	}
+	// raw tools and templates have no Go types to generate
+	for _, unwanted := range []string{"Proxy", "ReadBlob"} {
+		if strings.Contains(string(src), unwanted) {
+			t.Fatalf("generated code contains raw entry %q:\n%s", unwanted, src)
+		}
+	}
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	// the generated package must live inside the module to resolve imports
+	dir, err := os.MkdirTemp(".", "_gentest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.WriteFile(filepath.Join(dir, "client_gen.go"), src, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command(gobin, "vet", "./"+dir).CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s\n%s", err, out, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	reg := registry.New()
+	registry.RegisterTool(reg, "create_user", func(ctx context.Context, in struct{}) (struct{}, error) { return struct{}{}, nil })
+	registry.RegisterTool(reg, "CreateUser", func(ctx context.Context, in struct{}) (struct{}, error) { return struct{}{}, nil })
+	if _, err := clientgen.Generate(reg, "gentest"); err == nil || !strings.Contains(err.Error(), "both map to method CreateUser") {
+		t.Fatalf("expected a method name collision, got %v", err)
+	}
+
+	reg = registry.New()
+	registry.RegisterTool(reg, "Watch", func(ctx context.Context, in struct{}) (chan int, error) { return nil, nil })
+	if _, err := clientgen.Generate(reg, "gentest"); err == nil || !strings.Contains(err.Error(), "cannot be sent as JSON") {
+		t.Fatalf("expected an unsupported type error, got %v", err)
+	}
+
+	reg = registry.New()
+	registry.RegisterResourceTemplate(reg, "Search", "search://{?q}", func(ctx context.Context, uri string) (string, error) { return "", nil })
+	if _, err := clientgen.Generate(reg, "gentest"); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Fatalf("expected an unsupported template error, got %v", err)
+	}
+}
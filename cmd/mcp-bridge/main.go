@@ -0,0 +1,91 @@
+// Command mcp-bridge connects MCP clients and servers that speak different
+// transports.
+//
+// To use a remote Streamable HTTP server from a client that only speaks
+// stdio, configure the client to run:
+//
+//	mcp-bridge -url https://example.com/mcp -header "Authorization: Bearer ..."
+//
+// To serve a local stdio server over HTTP, starting one process per client
+// session:
+//
+//	mcp-bridge -addr localhost:8080 -- mcp-files -root /srv
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/cyrusaf/mcp/bridge"
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type headers []string
+
+func (h *headers) String() string     { return strings.Join(*h, ", ") }
+func (h *headers) Set(v string) error { *h = append(*h, v); return nil }
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mcp-bridge: ")
+	url := flag.String("url", "", "Streamable HTTP server to expose over stdio")
+	var hdrs headers
+	flag.Var(&hdrs, "header", "`name: value` header sent to the -url server (repeatable)")
+	addr := flag.String("addr", "", "address to serve the command given after the flags over HTTP")
+	origins := flag.String("allowed-origins", "", "comma-separated browser origins allowed besides localhost")
+	flag.Parse()
+
+	var down transport.Transport
+	var dial bridge.Dialer
+	switch {
+	case *url != "" && *addr == "" && flag.NArg() == 0:
+		var opts []client.HTTPOption
+		for _, h := range hdrs {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				log.Fatalf("invalid header %q", h)
+			}
+			opts = append(opts, client.WithHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+		}
+		down = transport.StdioTransport()
+		dial = func(ctx context.Context) (transport.Transport, error) {
+			return client.NewHTTPTransport(*url, opts...), nil
+		}
+	case *addr != "" && *url == "" && flag.NArg() > 0:
+		var opts []transport.HTTPOption
+		if host, _, err := net.SplitHostPort(*addr); err == nil && (host == "localhost" || net.ParseIP(host).IsLoopback()) {
+			// only answer requests addressed to localhost, defeating DNS rebinding
+			opts = append(opts, transport.WithAllowedHosts(transport.LocalhostHosts...))
+		}
+		if *origins != "" {
+			opts = append(opts, transport.WithAllowedOrigins(strings.Split(*origins, ",")...))
+		}
+		tr, err := transport.ListenHTTP(*addr, opts...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tr.Close()
+		down = tr
+		args := flag.Args()
+		dial = func(ctx context.Context) (transport.Transport, error) {
+			return transport.CommandTransport(args[0], args[1:], transport.WithStderrLogger(log.New(os.Stderr, args[0]+": ", 0)))
+		}
+	default:
+		flag.Usage()
+		log.Fatal("use either -url, or -addr followed by a command")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := bridge.New(down, dial).Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,109 @@
+// Command mcp-gateway serves the tools, resources and prompts of several MCP
+// servers as one. The upstreams are read from a JSON file:
+//
+//	{"upstreams": [
+//		{"name": "files", "prefix": "fs_", "command": ["mcp-files", "-root", "/srv"]},
+//		{"name": "github", "prefix": "gh_", "url": "https://example.com/mcp",
+//		 "headers": {"Authorization": "Bearer ..."}}
+//	]}
+//
+// The gateway serves over stdio, or over HTTP when -addr is given.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/gateway"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type config struct {
+	Upstreams []struct {
+		Name    string            `json:"name"`
+		Prefix  string            `json:"prefix"`
+		Command []string          `json:"command"`
+		Env     []string          `json:"env"`
+		Dir     string            `json:"dir"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers"`
+	} `json:"upstreams"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mcp-gateway: ")
+	configFile := flag.String("config", "gateway.json", "file listing the upstream servers")
+	addr := flag.String("addr", "", "serve over HTTP on this address instead of stdio")
+	flag.Parse()
+
+	b, err := os.ReadFile(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var cfg config
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		log.Fatalf("%s: %v", *configFile, err)
+	}
+	var upstreams []gateway.Upstream
+	for _, u := range cfg.Upstreams {
+		var dial gateway.Dialer
+		switch {
+		case len(u.Command) > 0:
+			// stdout carries the protocol when serving over stdio, so
+			// upstream diagnostics go to our stderr
+			dial = gateway.Command(u.Command[0], u.Command[1:],
+				transport.WithCommandEnv(u.Env...), transport.WithCommandDir(u.Dir),
+				transport.WithStderrLogger(log.New(os.Stderr, u.Name+": ", 0)))
+		case u.URL != "":
+			var opts []client.HTTPOption
+			for k, v := range u.Headers {
+				opts = append(opts, client.WithHeader(k, v))
+			}
+			dial = gateway.HTTP(u.URL, opts...)
+		default:
+			log.Fatalf("upstream %q needs a command or a url", u.Name)
+		}
+		upstreams = append(upstreams, gateway.Upstream{Name: u.Name, Prefix: u.Prefix, Dial: dial})
+	}
+
+	reg := registry.New()
+	gw := gateway.New(reg, upstreams, gateway.WithClientOptions(client.WithClientInfo("mcp-gateway", "0.1.0")))
+	if err := gw.Start(context.Background()); err != nil {
+		// failed upstreams are retried in the background
+		log.Print(err)
+	}
+	defer gw.Close()
+
+	tr := transport.StdioTransport()
+	if *addr != "" {
+		if tr, err = transport.ListenHTTP(*addr); err != nil {
+			log.Fatal(err)
+		}
+	}
+	srv := rpc.NewServer(reg, tr, rpc.WithListChanged())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+	if err := srv.Run(context.Background()); err != nil && !errors.Is(err, rpc.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,118 @@
+// Command mcp-gen writes a typed client for the tools and resource templates
+// of a registry. The registry is obtained by calling an exported function
+// without arguments, for example:
+//
+//	//go:generate go run github.com/cyrusaf/mcp/cmd/mcp-gen -registry example.com/svc/api.NewRegistry -o apiclient/client_gen.go
+//
+// The function's package must be importable, so it cannot be a main
+// package. mcp-gen builds and runs a small program calling it from a
+// temporary directory inside the current module.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var program = template.Must(template.New("main").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cyrusaf/mcp/clientgen"
+	reg {{printf "%q" .Import}}
+)
+
+func main() {
+	src, err := clientgen.Generate(reg.{{.Func}}(), {{printf "%q" .Package}},
+		clientgen.WithTypeName({{printf "%q" .Type}}),
+		clientgen.WithPackagePath({{printf "%q" .PackagePath}}))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(src)
+}
+`))
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mcp-gen: ")
+	registryFlag := flag.String("registry", "", "`importpath.Func` returning the *registry.Registry")
+	out := flag.String("o", "", "output file (default stdout)")
+	pkg := flag.String("package", "", "package name of the generated file (default $GOPACKAGE or the output directory)")
+	pkgPath := flag.String("package-path", "", "import path of the generated package, whose types need no import")
+	typeName := flag.String("type", "Client", "name of the generated client type")
+	flag.Parse()
+
+	dot := strings.LastIndex(*registryFlag, ".")
+	if dot <= 0 || dot < strings.LastIndex(*registryFlag, "/") {
+		log.Fatal("-registry must name a function, like example.com/svc/api.NewRegistry")
+	}
+	if *pkg == "" {
+		*pkg = os.Getenv("GOPACKAGE")
+	}
+	if *pkg == "" && *out != "" {
+		abs, err := filepath.Abs(*out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		*pkg = filepath.Base(filepath.Dir(abs))
+	}
+	if *pkg == "" {
+		log.Fatal("cannot determine the package name; use -package")
+	}
+
+	src, err := generate(map[string]string{
+		"Import":      (*registryFlag)[:dot],
+		"Func":        (*registryFlag)[dot+1:],
+		"Package":     *pkg,
+		"PackagePath": *pkgPath,
+		"Type":        *typeName,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// generate runs the generator program for the given template arguments and
+// returns its output.
+func generate(args map[string]string) ([]byte, error) {
+	// go run ignores directories starting with "_" in patterns, but
+	// resolves imports through the enclosing module
+	dir, err := os.MkdirTemp(".", "_mcpgen")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	var src bytes.Buffer
+	if err := program.Execute(&src, args); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), src.Bytes(), 0o644); err != nil {
+		return nil, err
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command("go", "run", "./"+filepath.ToSlash(dir))
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running generator: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
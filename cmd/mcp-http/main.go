@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/cyrusaf/mcp/registry"
 	"github.com/cyrusaf/mcp/rpc"
@@ -33,9 +41,51 @@ func main() {
 
 	registry.RegisterTool(api, "FetchWebpage", WebpageHandler, registry.WithDescription("Fetch contents of a webpage by URL"))
 
-	tr := transport.HTTPTransport(":8080")
-	srv := rpc.NewServer(api, tr)
-	log.Fatal(srv.Run(context.Background()))
+	addr := flag.String("addr", "localhost:8080", "address to listen on")
+	origins := flag.String("allowed-origins", "", "comma-separated browser origins allowed besides localhost")
+	record := flag.String("record", "", "`file` to append the JSON-RPC traffic to, for the replay package")
+	flag.Parse()
+
+	opts := []transport.HTTPOption{}
+	if host, _, err := net.SplitHostPort(*addr); err == nil && (host == "localhost" || net.ParseIP(host).IsLoopback()) {
+		// only answer requests addressed to localhost, defeating DNS rebinding
+		opts = append(opts, transport.WithAllowedHosts(transport.LocalhostHosts...))
+	}
+	if *origins != "" {
+		opts = append(opts, transport.WithAllowedOrigins(strings.Split(*origins, ",")...))
+	}
+	tr, err := transport.ListenHTTP(*addr, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var srvTr transport.Transport = tr
+	if *record != "" {
+		f, err := os.OpenFile(*record, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		srvTr = transport.Recording(tr, f)
+	}
+	srv := rpc.NewServer(api, srvTr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+	if err := srv.Run(context.Background()); err != nil && !errors.Is(err, rpc.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	// Run returns as soon as Shutdown starts; wait for it to drain
+	<-shutdownDone
 }
 
 func UserHandler(ctx context.Context, id string) (User, error) {
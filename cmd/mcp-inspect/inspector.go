@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/schema"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+const help = `commands:
+  tools                     list tools
+  schema TOOL               show a tool's input and output schemas
+  call TOOL [JSON]          call a tool, asking for arguments if JSON is omitted
+  resources                 list resources
+  templates                 list resource templates
+  read URI                  read a resource
+  prompts                   list prompts
+  prompt NAME [ARG=VALUE]…  get a prompt
+  ping                      check the server responds
+  trace on|off              print raw protocol traffic
+  help                      show this help
+  quit                      disconnect`
+
+// inspector runs commands against a connected server.
+type inspector struct {
+	c           *client.Client
+	in          *bufio.Reader
+	out         io.Writer
+	interactive bool
+	json        bool
+	timeout     time.Duration
+	trace       atomic.Bool
+}
+
+func (in *inspector) connect(ctx context.Context, tr transport.Transport) error {
+	c, err := client.Connect(ctx, &tracer{Transport: tr, in: in},
+		client.WithClientInfo("mcp-inspect", "0.1.0"),
+		client.WithNotificationHandler(func(ctx context.Context, method string, params json.RawMessage) {
+			if in.interactive {
+				fmt.Fprintf(in.out, "notification %s %s\n", method, params)
+			}
+		}))
+	if err != nil {
+		return err
+	}
+	in.c = c
+	if in.interactive {
+		info := c.InitializeResult()
+		fmt.Fprintf(in.out, "connected to %s %s (protocol %s)\n", info.ServerInfo.Name, info.ServerInfo.Version, info.ProtocolVersion)
+		if info.Instructions != "" {
+			fmt.Fprintln(in.out, info.Instructions)
+		}
+	}
+	return nil
+}
+
+// run executes commands until the input ends. Outside interactive mode the
+// first failing command ends the run with its error.
+func (in *inspector) run(ctx context.Context) error {
+	for {
+		if in.interactive {
+			fmt.Fprint(in.out, "mcp> ")
+		}
+		line, err := in.in.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			if line == "quit" || line == "exit" {
+				return nil
+			}
+			if cerr := in.exec(ctx, line); cerr != nil {
+				if !in.interactive {
+					return fmt.Errorf("%s: %w", line, cerr)
+				}
+				fmt.Fprintln(in.out, "error:", cerr)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (in *inspector) exec(ctx context.Context, line string) error {
+	cmd, rest, _ := strings.Cut(line, " ")
+	rest = strings.TrimSpace(rest)
+	if cmd == "call" {
+		// waiting for arguments to be typed does not count
+		return in.call(ctx, rest)
+	}
+	ctx, cancel := context.WithTimeout(ctx, in.timeout)
+	defer cancel()
+	switch cmd {
+	case "help":
+		fmt.Fprintln(in.out, help)
+		return nil
+	case "trace":
+		switch rest {
+		case "on", "off":
+			in.trace.Store(rest == "on")
+			return nil
+		}
+		return errors.New("usage: trace on|off")
+	case "ping":
+		if err := in.c.Ping(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintln(in.out, "ok")
+		return nil
+	case "tools":
+		tools, err := in.c.ListTools(ctx)
+		if err != nil {
+			return err
+		}
+		sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+		return list(in, tools, func(t client.Tool) (string, string) { return t.Name, t.Description })
+	case "resources":
+		res, err := in.c.ListResources(ctx)
+		if err != nil {
+			return err
+		}
+		return list(in, res, func(r client.Resource) (string, string) { return r.URI, join(r.Name, r.Description) })
+	case "templates":
+		tmpls, err := in.c.ListResourceTemplates(ctx)
+		if err != nil {
+			return err
+		}
+		return list(in, tmpls, func(t client.ResourceTemplate) (string, string) {
+			return t.URITemplate, join(t.Name, t.Description)
+		})
+	case "prompts":
+		prompts, err := in.c.ListPrompts(ctx)
+		if err != nil {
+			return err
+		}
+		return list(in, prompts, func(p client.Prompt) (string, string) {
+			var args []string
+			for _, a := range p.Arguments {
+				if !a.Required {
+					a.Name += "?"
+				}
+				args = append(args, a.Name)
+			}
+			return p.Name + "(" + strings.Join(args, ", ") + ")", p.Description
+		})
+	case "schema":
+		tool, err := in.tool(ctx, rest)
+		if err != nil {
+			return err
+		}
+		if in.json {
+			return in.print(map[string]any{"inputSchema": tool.InputSchema, "outputSchema": tool.OutputSchema})
+		}
+		if tool.Description != "" {
+			fmt.Fprintln(in.out, tool.Description)
+		}
+		fmt.Fprintln(in.out, "arguments:")
+		describe(in.out, tool.InputSchema, "  ")
+		if tool.OutputSchema != nil {
+			fmt.Fprintln(in.out, "result:")
+			describe(in.out, tool.OutputSchema, "  ")
+		}
+		return nil
+	case "read":
+		if rest == "" {
+			return errors.New("usage: read URI")
+		}
+		res, err := in.c.ReadResource(ctx, rest)
+		if err != nil {
+			return err
+		}
+		if in.json {
+			return in.print(res)
+		}
+		for _, c := range res.Contents {
+			if c.Text != "" {
+				fmt.Fprintln(in.out, c.Text)
+			} else {
+				fmt.Fprintf(in.out, "%s: %d bytes of %s\n", c.URI, len(c.Blob), c.MimeType)
+			}
+		}
+		return nil
+	case "prompt":
+		name, args, err := promptArgs(rest)
+		if err != nil {
+			return err
+		}
+		res, err := in.c.GetPrompt(ctx, name, args)
+		if err != nil {
+			return err
+		}
+		if in.json {
+			return in.print(res)
+		}
+		if res.Description != "" {
+			fmt.Fprintln(in.out, res.Description)
+		}
+		for _, m := range res.Messages {
+			fmt.Fprintf(in.out, "[%s] %s\n", m.Role, contentText(m.Content))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q; try help", cmd)
+}
+
+// list prints one line per item, or the items as JSON.
+func list[T any](in *inspector, items []T, line func(T) (string, string)) error {
+	if in.json {
+		return in.print(items)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(in.out, "(none)")
+	}
+	for _, item := range items {
+		name, desc := line(item)
+		if desc != "" {
+			name += "  " + desc
+		}
+		fmt.Fprintln(in.out, name)
+	}
+	return nil
+}
+
+func (in *inspector) print(v any) error {
+	enc := json.NewEncoder(in.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (in *inspector) tool(ctx context.Context, name string) (*client.Tool, error) {
+	if name == "" {
+		return nil, errors.New("missing tool name")
+	}
+	tools, err := in.c.ListTools(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tools {
+		if tools[i].Name == name {
+			return &tools[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no tool named %q", name)
+}
+
+func (in *inspector) call(ctx context.Context, rest string) error {
+	name, argText, _ := strings.Cut(rest, " ")
+	if name == "" {
+		return errors.New("usage: call TOOL [JSON]")
+	}
+	var args json.RawMessage
+	switch argText = strings.TrimSpace(argText); {
+	case argText != "":
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(argText), &obj); err != nil || obj == nil {
+			return errors.New("arguments must be a JSON object")
+		}
+		args = json.RawMessage(argText)
+	case in.interactive:
+		lctx, cancel := context.WithTimeout(ctx, in.timeout)
+		tool, err := in.tool(lctx, name)
+		cancel()
+		if err != nil {
+			return err
+		}
+		if args, err = in.ask(tool.InputSchema); err != nil {
+			return err
+		}
+	default:
+		args = json.RawMessage(`{}`)
+	}
+	ctx, cancel := context.WithTimeout(ctx, in.timeout)
+	defer cancel()
+	res, err := in.c.CallTool(ctx, name, args)
+	if err != nil {
+		return err
+	}
+	if in.json {
+		if err := in.print(res); err != nil {
+			return err
+		}
+	} else {
+		for _, item := range res.Content {
+			fmt.Fprintln(in.out, contentText(item))
+		}
+		if len(res.StructuredContent) > 0 {
+			var buf bytes.Buffer
+			if json.Indent(&buf, res.StructuredContent, "", "  ") == nil {
+				fmt.Fprintln(in.out, "structured:", buf.String())
+			}
+		}
+	}
+	if res.IsError {
+		return errors.New("the tool reported an error")
+	}
+	return nil
+}
+
+// ask reads a value for each property of an object schema.
+func (in *inspector) ask(s *schema.Schema) (json.RawMessage, error) {
+	args := map[string]json.RawMessage{}
+	if s == nil || len(s.Properties) == 0 {
+		return json.Marshal(args)
+	}
+	for _, name := range propertyNames(s) {
+		prop := s.Properties[name]
+		required := contains(s.Required, name)
+		for {
+			fmt.Fprintf(in.out, "%s (%s): ", name, summary(prop, required))
+			line, err := in.in.ReadString('\n')
+			if err != nil && line == "" {
+				return nil, err
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				if required {
+					continue
+				}
+				break
+			}
+			v, perr := parseValue(prop, line)
+			if perr != nil {
+				fmt.Fprintln(in.out, perr)
+				continue
+			}
+			args[name] = v
+			break
+		}
+	}
+	return json.Marshal(args)
+}
+
+// parseValue reads a value typed at the prompt: strings as they are,
+// anything else as JSON.
+func parseValue(s *schema.Schema, text string) (json.RawMessage, error) {
+	if s != nil && s.Type == "string" && !(s.Nullable && text == "null") {
+		return json.Marshal(text)
+	}
+	if !json.Valid([]byte(text)) {
+		if s == nil || s.Type == "" {
+			return json.Marshal(text)
+		}
+		return nil, fmt.Errorf("enter a JSON %s", s.Type)
+	}
+	if s != nil && s.Type != "" {
+		if err := s.ValidateJSON([]byte(text)); err != nil {
+			return nil, err
+		}
+	}
+	return json.RawMessage(text), nil
+}
+
+// describe prints the properties of an object schema, or its JSON.
+func describe(w io.Writer, s *schema.Schema, indent string) {
+	if s == nil || len(s.Properties) == 0 {
+		b, _ := json.Marshal(s)
+		fmt.Fprintln(w, indent+string(b))
+		return
+	}
+	for _, name := range propertyNames(s) {
+		prop := s.Properties[name]
+		fmt.Fprintf(w, "%s%s: %s\n", indent, name, summary(prop, contains(s.Required, name)))
+		if prop != nil && len(prop.Properties) > 0 {
+			describe(w, prop, indent+"  ")
+		}
+	}
+}
+
+// summary describes a property in a few words, like "integer, required".
+func summary(s *schema.Schema, required bool) string {
+	var parts []string
+	switch {
+	case s == nil:
+		parts = append(parts, "any")
+	case s.Type == "array" && s.Items != nil && s.Items.Type != "":
+		parts = append(parts, "array of "+s.Items.Type)
+	case s.Type != "":
+		parts = append(parts, s.Type)
+	case len(s.OneOf) > 0 || len(s.AnyOf) > 0:
+		parts = append(parts, "one of several shapes")
+	default:
+		parts = append(parts, "any")
+	}
+	if s != nil && len(s.Enum) > 0 {
+		var vals []string
+		for _, v := range s.Enum {
+			b, _ := json.Marshal(v)
+			vals = append(vals, string(b))
+		}
+		parts = append(parts, "one of "+strings.Join(vals, ", "))
+	}
+	if s != nil && s.Nullable {
+		parts = append(parts, "nullable")
+	}
+	if required {
+		parts = append(parts, "required")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// propertyNames returns the required properties first, then the others,
+// each in alphabetical order.
+func propertyNames(s *schema.Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := contains(s.Required, names[i]), contains(s.Required, names[j])
+		if ri != rj {
+			return ri
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// promptArgs parses "NAME ARG=VALUE ..."; values may be quoted Go strings.
+func promptArgs(rest string) (string, map[string]string, error) {
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", nil, errors.New("usage: prompt NAME [ARG=VALUE]...")
+	}
+	args := map[string]string{}
+	for i := 1; i < len(fields); i++ {
+		k, v, ok := strings.Cut(fields[i], "=")
+		if !ok {
+			return "", nil, fmt.Errorf("argument %q is not ARG=VALUE", fields[i])
+		}
+		if strings.HasPrefix(v, `"`) {
+			// rejoin a quoted value containing spaces
+			for !strings.HasSuffix(v, `"`) || len(v) == 1 {
+				if i++; i == len(fields) {
+					return "", nil, fmt.Errorf("unterminated value for %s", k)
+				}
+				v += " " + fields[i]
+			}
+			uq, err := strconv.Unquote(v)
+			if err != nil {
+				return "", nil, fmt.Errorf("value for %s: %v", k, err)
+			}
+			v = uq
+		}
+		args[k] = v
+	}
+	return fields[0], args, nil
+}
+
+// contentText renders a content item, printing text as is.
+func contentText(v any) string {
+	b, _ := json.Marshal(v)
+	var item struct {
+		Type     string `json:"type"`
+		Text     string `json:"text"`
+		MimeType string `json:"mimeType"`
+	}
+	if json.Unmarshal(b, &item) == nil && item.Type == "text" {
+		return item.Text
+	}
+	if item.Type == "image" || item.Type == "audio" {
+		return fmt.Sprintf("(%s, %s)", item.Type, item.MimeType)
+	}
+	return string(b)
+}
+
+func join(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + " - " + b
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
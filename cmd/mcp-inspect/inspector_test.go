@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type addIn struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type addOut struct {
+	Sum int `json:"sum"`
+}
+
+// testInspector connects an inspector reading script to an in-process
+// server.
+func testInspector(t *testing.T, script string, interactive bool) (*inspector, *bytes.Buffer) {
+	t.Helper()
+	reg := registry.New()
+	registry.RegisterTool(reg, "add", func(ctx context.Context, in addIn) (addOut, error) {
+		return addOut{Sum: in.A + in.B}, nil
+	}, registry.WithDescription("Add two numbers."))
+	registry.RegisterTool(reg, "fail", func(ctx context.Context, in struct{}) (struct{}, error) {
+		return struct{}{}, errors.New("boom")
+	})
+	registry.RegisterResource(reg, "Greeting", "mem://greeting", func(ctx context.Context, uri string) (string, error) {
+		return "hello", nil
+	})
+	registry.RegisterPrompt(reg, "greet", func(ctx context.Context, args map[string]string) (*registry.PromptResult, error) {
+		return &registry.PromptResult{Messages: []registry.PromptMessage{{Role: "user", Content: rpc.NewTextContent("Hello, " + args["name"])}}}, nil
+	}, registry.WithPromptArgument("name", "", true))
+
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	t.Cleanup(func() { fromClient.Close(); fromServer.Close() })
+	go func() {
+		_ = rpc.NewServer(reg, transport.NewStdioTransport(toServer, fromServer)).Run(context.Background())
+	}()
+
+	var out bytes.Buffer
+	in := &inspector{in: bufio.NewReader(strings.NewReader(script)), out: &out, interactive: interactive, timeout: 5 * time.Second}
+	if err := in.connect(context.Background(), transport.NewStdioTransport(toClient, fromClient)); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return in, &out
+}
+
+func TestScript(t *testing.T) {
+	in, out := testInspector(t, `
+# comments and blank lines are skipped
+tools
+schema add
+call add {"a": 2, "b": 3}
+resources
+read mem://greeting
+prompts
+prompt greet name="Ann Lee"
+ping
+`, false)
+	if err := in.run(context.Background()); err != nil {
+		t.Fatalf("run: %v\n%s", err, out)
+	}
+	for _, want := range []string{
+		"add  Add two numbers.\nfail\n",
+		"arguments:\n  a: integer, required\n  b: integer, required\nresult:\n  sum: integer, required\n",
+		`{"sum":5}`,
+		"mem://greeting  Greeting\n",
+		`"hello"`,
+		"greet(name)\n",
+		"[user] Hello, Ann Lee\n",
+		"ok\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestScriptFailure(t *testing.T) {
+	in, out := testInspector(t, "call fail\nping\n", false)
+	err := in.run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "call fail") {
+		t.Fatalf("expected the failing call to end the run, got %v", err)
+	}
+	if strings.Contains(out.String(), "ok") {
+		t.Fatalf("commands ran after the failure:\n%s", out)
+	}
+
+	for script, want := range map[string]string{
+		"call add 5\n":    "arguments must be a JSON object",
+		"call add null\n": "arguments must be a JSON object",
+		"call add [1]\n":  "arguments must be a JSON object",
+		"call\n":          "usage: call TOOL",
+	} {
+		in, _ = testInspector(t, script, false)
+		if err := in.run(context.Background()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected %q, got %v", script, want, err)
+		}
+	}
+	// the usage error comes before looking up the tool to ask for arguments
+	in, out = testInspector(t, "call\nquit\n", true)
+	if err := in.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(out.String(), "error: usage: call TOOL") {
+		t.Fatalf("expected a usage error:\n%s", out)
+	}
+
+	in, _ = testInspector(t, "frobnicate\n", false)
+	if err := in.run(context.Background()); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Fatalf("expected an unknown command error, got %v", err)
+	}
+}
+
+func TestInteractiveArguments(t *testing.T) {
+	// b is required, so the empty answer is asked again; a rejects text
+	in, out := testInspector(t, "call add\nx\n2\n\n3\nquit\n", true)
+	if err := in.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	for _, want := range []string{"connected to cyrusaf/mcp", "a (integer, required): ", "enter a JSON integer", `"sum": 5`} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	in, out := testInspector(t, "tools\n", false)
+	in.json = true
+	if err := in.run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "[\n  {\n    \"name\": ") {
+		t.Fatalf("unexpected JSON output:\n%s", out)
+	}
+}
@@ -0,0 +1,156 @@
+// Command mcp-inspect connects to an MCP server to explore and call it.
+//
+// Connect to a stdio server by naming its command, or to a Streamable HTTP
+// server with -url:
+//
+//	mcp-inspect -- mcp-files -root /srv
+//	mcp-inspect -url https://example.com/mcp -header "Authorization: Bearer ..."
+//
+// Without -c or -script, and with a terminal on stdin, mcp-inspect reads
+// commands interactively and asks for tool arguments following each tool's
+// input schema; type "help" for the commands. Otherwise the commands are run
+// in order, for example from CI:
+//
+//	mcp-inspect -c 'call add {"a":1,"b":2}' -c 'read mem://greeting' -- ./server
+//
+// and the first failing command, including a tool returning an error, ends
+// mcp-inspect with a non-zero status. -trace prints the raw messages
+// exchanged with the server to stderr.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, "; ") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mcp-inspect: ")
+	url := flag.String("url", "", "Streamable HTTP server to connect to, instead of a command")
+	var headers, commands stringList
+	flag.Var(&headers, "header", "`name: value` header sent to the -url server (repeatable)")
+	flag.Var(&commands, "c", "`command` to run non-interactively (repeatable)")
+	script := flag.String("script", "", "`file` of commands to run non-interactively, one per line; - for stdin")
+	jsonOut := flag.Bool("json", false, "print results as JSON")
+	trace := flag.Bool("trace", false, "print raw protocol traffic to stderr")
+	timeout := flag.Duration("timeout", time.Minute, "timeout for each request")
+	flag.Parse()
+
+	var tr transport.Transport
+	switch {
+	case *url != "" && flag.NArg() == 0:
+		var opts []client.HTTPOption
+		for _, h := range headers {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				log.Fatalf("invalid header %q", h)
+			}
+			opts = append(opts, client.WithHeader(strings.TrimSpace(name), strings.TrimSpace(value)))
+		}
+		tr = client.NewHTTPTransport(*url, opts...)
+	case *url == "" && flag.NArg() > 0:
+		var err error
+		tr, err = transport.CommandTransport(flag.Arg(0), flag.Args()[1:],
+			transport.WithStderrLogger(log.New(os.Stderr, flag.Arg(0)+": ", 0)))
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		flag.Usage()
+		log.Fatal("name a server command or use -url")
+	}
+
+	in := &inspector{out: os.Stdout, json: *jsonOut, timeout: *timeout}
+	in.trace.Store(*trace)
+	var lines io.Reader
+	switch {
+	case len(commands) > 0:
+		lines = strings.NewReader(strings.Join(commands, "\n"))
+	case *script == "-":
+		lines = os.Stdin
+	case *script != "":
+		f, err := os.Open(*script)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		lines = f
+	default:
+		if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+			in.interactive = true
+		}
+		lines = os.Stdin
+	}
+	in.in = bufio.NewReader(lines)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	err := in.connect(ctx, tr)
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer in.c.Close()
+	if err := in.run(context.Background()); err != nil {
+		in.c.Close()
+		log.Fatal(err)
+	}
+}
+
+// tracer prints the messages passing through a client transport.
+type tracer struct {
+	transport.Transport
+	in *inspector
+}
+
+func (t *tracer) print(dir string, msg []byte) {
+	if t.in.trace.Load() {
+		fmt.Fprintf(os.Stderr, "%s %s\n", dir, strings.TrimSpace(string(msg)))
+	}
+}
+
+func (t *tracer) Notify(ctx context.Context, msg json.RawMessage) error {
+	t.print("->", msg)
+	return t.Transport.(transport.Notifier).Notify(ctx, msg)
+}
+
+func (t *tracer) Next(ctx context.Context) (transport.Conn, json.RawMessage, error) {
+	conn, msg, err := t.Transport.Next(ctx)
+	if err == nil {
+		t.print("<-", msg)
+		conn = tracerConn{conn, t}
+	}
+	return conn, msg, err
+}
+
+// SetProtocolVersion passes the negotiated version to HTTP transports.
+func (t *tracer) SetProtocolVersion(v string) {
+	if spv, ok := t.Transport.(interface{ SetProtocolVersion(string) }); ok {
+		spv.SetProtocolVersion(v)
+	}
+}
+
+type tracerConn struct {
+	transport.Conn
+	t *tracer
+}
+
+func (c tracerConn) Send(ctx context.Context, msg json.RawMessage) error {
+	c.t.print("->", msg)
+	return c.Conn.Send(ctx, msg)
+}
@@ -26,5 +26,8 @@ func main() {
 	registry.RegisterResource[User](api, "User", "users://{id}", func(context.Context, string) (User, error) { return User{}, nil })
 	registry.RegisterTool(api, "CreateUser", CreateUser, registry.WithDescription("Create a new user account"))
 	srv := rpc.NewServer(api, transport.StdioTransport())
-	log.Fatal(srv.Run(context.Background()))
+	// Run returns nil once the client closes stdin
+	if err := srv.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,226 @@
+// Package gateway aggregates several upstream MCP servers behind a single
+// registry.
+//
+// Each upstream's tools, resources, resource templates and prompts are
+// registered in the gateway's registry under a configurable name prefix and
+// forwarded to the upstream when called. Serving that registry with
+// rpc.NewServer and rpc.WithListChanged gives clients one endpoint for all
+// upstreams. Progress notifications and cancellations are passed through,
+// upstream list changes are mirrored, and an upstream that fails is removed
+// from the registry and reconnected with exponential backoff.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// Dialer connects to an upstream server. It is called again to reconnect
+// after the upstream fails.
+type Dialer func(ctx context.Context) (transport.Transport, error)
+
+// Command returns a Dialer starting the named stdio server.
+func Command(name string, args []string, opts ...transport.CommandOption) Dialer {
+	return func(ctx context.Context) (transport.Transport, error) {
+		return transport.CommandTransport(name, args, opts...)
+	}
+}
+
+// HTTP returns a Dialer for a Streamable HTTP server.
+func HTTP(endpoint string, opts ...client.HTTPOption) Dialer {
+	return func(ctx context.Context) (transport.Transport, error) {
+		return client.NewHTTPTransport(endpoint, opts...), nil
+	}
+}
+
+// Upstream describes a server behind the gateway.
+type Upstream struct {
+	// Name identifies the upstream in logs and status reports.
+	Name string
+	// Prefix is prepended to the names of the upstream's tools, prompts,
+	// resources and templates, for example "github_". Resource URIs are
+	// not changed.
+	Prefix string
+	Dial   Dialer
+}
+
+// Status reports the state of an upstream.
+type Status struct {
+	Name      string
+	Connected bool
+	// Err is why the upstream is not connected.
+	Err                       error
+	Tools, Resources, Prompts int
+}
+
+// Gateway maintains the connections to the upstreams and their entries in
+// the registry.
+type Gateway struct {
+	reg        *registry.Registry
+	upstreams  []*upstream
+	logger     *log.Logger
+	minRetry   time.Duration
+	maxRetry   time.Duration
+	clientOpts []client.Option
+
+	// progress maps the progress tokens sent upstream to the contexts of
+	// the downstream requests.
+	progress  sync.Map
+	nextToken atomic.Int64
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Option func(*Gateway)
+
+// WithLogger sets the logger for upstream failures. The default is
+// log.Default().
+func WithLogger(l *log.Logger) Option {
+	return func(g *Gateway) { g.logger = l }
+}
+
+// WithRetry sets the delay before reconnecting to a failed upstream, which
+// doubles after every failed attempt up to max. The defaults are one
+// second and one minute.
+func WithRetry(min, max time.Duration) Option {
+	return func(g *Gateway) { g.minRetry, g.maxRetry = min, max }
+}
+
+// WithClientOptions sets options for the clients connecting to upstreams,
+// such as client.WithClientInfo.
+func WithClientOptions(opts ...client.Option) Option {
+	return func(g *Gateway) { g.clientOpts = append(g.clientOpts, opts...) }
+}
+
+// New returns a gateway registering the upstreams' entries in reg, which
+// may also hold local tools. Names already registered are not overwritten.
+func New(reg *registry.Registry, upstreams []Upstream, opts ...Option) *Gateway {
+	g := &Gateway{
+		reg:      reg,
+		logger:   log.Default(),
+		minRetry: time.Second,
+		maxRetry: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	for _, u := range upstreams {
+		g.upstreams = append(g.upstreams, &upstream{Upstream: u, g: g})
+	}
+	return g
+}
+
+// Start connects to every upstream and keeps reconnecting those that fail
+// until Close. It returns once each upstream has been tried, with the
+// errors of those that could not be connected yet.
+func (g *Gateway) Start(ctx context.Context) error {
+	g.ctx, g.cancel = context.WithCancel(context.Background())
+	results := make(chan error, len(g.upstreams))
+	for _, u := range g.upstreams {
+		g.wg.Add(1)
+		go func(u *upstream) {
+			defer g.wg.Done()
+			u.run(ctx, results)
+		}(u)
+	}
+	var errs []error
+	for range g.upstreams {
+		if err := <-results; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close disconnects from the upstreams and removes their entries from the
+// registry.
+func (g *Gateway) Close() error {
+	if g.cancel == nil {
+		return nil
+	}
+	g.cancel()
+	g.wg.Wait()
+	return nil
+}
+
+// Status returns the state of every upstream.
+func (g *Gateway) Status() []Status {
+	out := make([]Status, 0, len(g.upstreams))
+	for _, u := range g.upstreams {
+		u.mu.Lock()
+		out = append(out, Status{
+			Name:      u.Name,
+			Connected: u.c != nil,
+			Err:       u.err,
+			Tools:     len(u.tools),
+			Resources: len(u.resources) + len(u.templates),
+			Prompts:   len(u.prompts),
+		})
+		u.mu.Unlock()
+	}
+	return out
+}
+
+// notify handles a notification from an upstream.
+func (g *Gateway) notify(u *upstream, method string, params json.RawMessage) {
+	switch method {
+	case "notifications/progress":
+		var p rpc.ProgressParams
+		if json.Unmarshal(params, &p) != nil {
+			return
+		}
+		var token string
+		if json.Unmarshal(p.ProgressToken, &token) != nil {
+			return
+		}
+		if ctx, ok := g.progress.Load(token); ok {
+			_ = rpc.NotifyProgress(ctx.(context.Context), p.Progress, p.Total, p.Message)
+		}
+	case "notifications/tools/list_changed", "notifications/resources/list_changed", "notifications/prompts/list_changed":
+		if err := u.sync(g.ctx); err != nil {
+			g.logger.Printf("gateway: %s: refreshing after %s: %v", u.Name, method, err)
+		}
+	}
+}
+
+// forward sends a request to u on behalf of the downstream request in ctx,
+// asking for progress under a token of the gateway's own.
+func (g *Gateway) forward(ctx context.Context, u *upstream, method string, params map[string]any) (json.RawMessage, error) {
+	c := u.client()
+	if c == nil {
+		return nil, u.unavailable()
+	}
+	token := "gateway-" + strconv.FormatInt(g.nextToken.Add(1), 10)
+	g.progress.Store(token, ctx)
+	defer g.progress.Delete(token)
+	params["_meta"] = map[string]any{"progressToken": token}
+
+	var res json.RawMessage
+	err := c.Call(ctx, method, params, &res)
+	if errors.Is(err, client.ErrClosed) {
+		return nil, u.unavailable()
+	}
+	return res, err
+}
+
+// CodeUnavailable is the JSON-RPC error code returned for calls to an
+// upstream that is not connected.
+const CodeUnavailable = -32001
+
+func (u *upstream) unavailable() error {
+	return &rpc.Error{Code: CodeUnavailable, Message: fmt.Sprintf("upstream %s is unavailable", u.Name)}
+}
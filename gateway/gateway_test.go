@@ -0,0 +1,268 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/gateway"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// pipeTransport is a client transport to an in-process server; closing it
+// closes the pipes, which ends the server.
+type pipeTransport struct {
+	transport.Transport
+	closers []io.Closer
+}
+
+func (p *pipeTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	return p.Transport.(transport.Notifier).Notify(ctx, msg)
+}
+
+func (p *pipeTransport) Close() error {
+	for _, c := range p.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// serve starts a server for reg and returns a transport connected to it.
+func serve(reg *registry.Registry) *pipeTransport {
+	toServer, fromClient := io.Pipe()
+	toClient, fromServer := io.Pipe()
+	srv := rpc.NewServer(reg, transport.NewStdioTransport(toServer, fromServer), rpc.WithListChanged())
+	go func() { _ = srv.Run(context.Background()) }()
+	return &pipeTransport{
+		Transport: transport.NewStdioTransport(toClient, fromClient),
+		closers:   []io.Closer{fromClient, fromServer},
+	}
+}
+
+// fakeUpstream serves a registry and can be taken down.
+type fakeUpstream struct {
+	reg  *registry.Registry
+	mu   sync.Mutex
+	down bool
+	conn *pipeTransport
+}
+
+func (f *fakeUpstream) dial(ctx context.Context) (transport.Transport, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return nil, errors.New("connection refused")
+	}
+	f.conn = serve(f.reg)
+	return f.conn, nil
+}
+
+func (f *fakeUpstream) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+	if down && f.conn != nil {
+		f.conn.Close()
+	}
+}
+
+type echoIn struct {
+	Text string `json:"text"`
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	cancelled := make(chan struct{})
+	regA := registry.New()
+	registry.RegisterTool(regA, "Echo", func(ctx context.Context, in echoIn) (string, error) {
+		return "a:" + in.Text, nil
+	})
+	registry.RegisterTool(regA, "Slow", func(ctx context.Context, in struct{}) (string, error) {
+		_ = rpc.NotifyProgress(ctx, 1, 2, "halfway")
+		<-ctx.Done()
+		close(cancelled)
+		return "", ctx.Err()
+	})
+	registry.RegisterResource(regA, "Motd", "mem://motd", func(ctx context.Context, uri string) (string, error) {
+		return "hello", nil
+	})
+	registry.RegisterPrompt(regA, "Greet", func(ctx context.Context, args map[string]string) (*registry.PromptResult, error) {
+		return &registry.PromptResult{Messages: []registry.PromptMessage{{
+			Role:    "user",
+			Content: rpc.NewTextContent("Hello, " + args["name"]),
+		}}}, nil
+	}, registry.WithPromptArgument("name", "who to greet", true))
+	regB := registry.New()
+	registry.RegisterTool(regB, "Echo", func(ctx context.Context, in echoIn) (string, error) {
+		return "b:" + in.Text, nil
+	})
+	a, b := &fakeUpstream{reg: regA}, &fakeUpstream{reg: regB}
+
+	reg := registry.New()
+	gw := gateway.New(reg, []gateway.Upstream{
+		{Name: "a", Prefix: "a_", Dial: a.dial},
+		{Name: "b", Prefix: "b_", Dial: b.dial},
+	}, gateway.WithRetry(10*time.Millisecond, 50*time.Millisecond), gateway.WithLogger(log.New(io.Discard, "", 0)))
+	if err := gw.Start(context.Background()); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer gw.Close()
+
+	notes := make(chan string, 100)
+	progress := make(chan string, 10)
+	c, err := client.Connect(context.Background(), serve(reg), client.WithNotificationHandler(func(ctx context.Context, method string, params json.RawMessage) {
+		if method == "notifications/progress" {
+			progress <- string(params)
+			return
+		}
+		select {
+		case notes <- method:
+		default:
+		}
+	}))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	tools, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("list tools: %v", err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "a_Echo,a_Slow,b_Echo" {
+		t.Fatalf("tools %s", got)
+	}
+	var out string
+	if err := c.CallToolInto(ctx, "b_Echo", echoIn{Text: "hi"}, &out); err != nil || out != "b:hi" {
+		t.Fatalf("call: %q, %v", out, err)
+	}
+	if err := c.ReadResourceInto(ctx, "mem://motd", &out); err != nil || out != "hello" {
+		t.Fatalf("read: %q, %v", out, err)
+	}
+	prompt, err := c.GetPrompt(ctx, "a_Greet", map[string]string{"name": "Ann"})
+	if err != nil || len(prompt.Messages) != 1 {
+		t.Fatalf("prompt: %+v, %v", prompt, err)
+	}
+
+	// progress reaches the caller under its own token and cancelling the
+	// call cancels the upstream handler
+	callCtx, cancel := context.WithCancel(ctx)
+	callErr := make(chan error, 1)
+	go func() {
+		callErr <- c.Call(callCtx, "tools/call", map[string]any{
+			"name":      "a_Slow",
+			"arguments": map[string]any{},
+			"_meta":     map[string]any{"progressToken": "mine"},
+		}, nil)
+	}()
+	select {
+	case p := <-progress:
+		if !strings.Contains(p, `"progressToken":"mine"`) || !strings.Contains(p, "halfway") {
+			t.Fatalf("progress %s", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no progress")
+	}
+	cancel()
+	if err := <-callErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the call to be cancelled, got %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream handler was not cancelled")
+	}
+
+	// upstream list changes are mirrored downstream
+	registry.RegisterTool(regB, "Reverse", func(ctx context.Context, in echoIn) (string, error) {
+		return in.Text, nil
+	})
+	waitFor(t, "b_Reverse", func() bool { return reg.FindTool("b_Reverse") != nil })
+	waitFor(t, "list_changed", func() bool {
+		for {
+			select {
+			case m := <-notes:
+				if m == "notifications/tools/list_changed" {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	})
+
+	// a failed upstream is removed and reconnected
+	b.setDown(true)
+	waitFor(t, "b to be removed", func() bool { return reg.FindTool("b_Echo") == nil })
+	if st := gw.Status(); st[0].Connected != true || st[1].Connected || st[1].Err == nil {
+		t.Fatalf("status %+v", st)
+	}
+	var rerr *rpc.Error
+	if _, err := c.CallTool(ctx, "b_Echo", echoIn{}); !errors.As(err, &rerr) || rerr.Code != -32601 {
+		t.Fatalf("expected an unknown tool, got %v", err)
+	}
+	if err := c.CallToolInto(ctx, "a_Echo", echoIn{Text: "x"}, &out); err != nil || out != "a:x" {
+		t.Fatalf("call: %q, %v", out, err)
+	}
+	b.setDown(false)
+	waitFor(t, "b to reconnect", func() bool { return reg.FindTool("b_Echo") != nil })
+	if err := c.CallToolInto(ctx, "b_Echo", echoIn{Text: "back"}, &out); err != nil || out != "b:back" {
+		t.Fatalf("call: %q, %v", out, err)
+	}
+
+	gw.Close()
+	if len(reg.Tools()) != 0 {
+		t.Fatalf("entries left after close: %d", len(reg.Tools()))
+	}
+}
+
+func TestGatewayStartErrors(t *testing.T) {
+	reg := registry.New()
+	registry.RegisterTool(reg, "a_Echo", func(ctx context.Context, in echoIn) (string, error) {
+		return "local", nil
+	})
+	up := &fakeUpstream{reg: registry.New(), down: true}
+	gw := gateway.New(reg, []gateway.Upstream{{Name: "a", Prefix: "a_", Dial: up.dial}},
+		gateway.WithRetry(10*time.Millisecond, 10*time.Millisecond), gateway.WithLogger(log.New(io.Discard, "", 0)))
+	err := gw.Start(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected a dial error, got %v", err)
+	}
+	defer gw.Close()
+
+	// local entries win over upstream ones with the same name
+	registry.RegisterTool(up.reg, "Echo", func(ctx context.Context, in echoIn) (string, error) {
+		return "remote", nil
+	})
+	up.setDown(false)
+	waitFor(t, "a to connect", func() bool { return gw.Status()[0].Connected })
+	if st := gw.Status()[0]; st.Tools != 0 || reg.FindTool("a_Echo").Raw != nil {
+		t.Fatalf("the local tool was replaced: %+v", st)
+	}
+}
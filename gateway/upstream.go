@@ -0,0 +1,232 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+)
+
+type upstream struct {
+	Upstream
+	g *Gateway
+
+	// mu guards the fields below and serializes syncs.
+	mu  sync.Mutex
+	c   *client.Client
+	err error
+
+	// the registry keys of the entries registered for the upstream
+	tools, resources, templates, prompts []string
+}
+
+func (u *upstream) client() *client.Client {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.c
+}
+
+// run keeps u connected until the gateway is closed. The outcome of the
+// first attempt is sent on first.
+func (u *upstream) run(ctx context.Context, first chan<- error) {
+	delay := u.g.minRetry
+	for {
+		c, err := u.connect(ctx)
+		if first != nil {
+			if err != nil {
+				err = fmt.Errorf("gateway: %s: %w", u.Name, err)
+			}
+			first <- err
+			first, ctx = nil, u.g.ctx
+		}
+		if err == nil {
+			delay = u.g.minRetry
+			select {
+			case <-c.Done():
+				err = c.Err()
+			case <-u.g.ctx.Done():
+			}
+			c.Close()
+			u.disconnect(err)
+			if u.g.ctx.Err() != nil {
+				return
+			}
+			u.g.logger.Printf("gateway: %s disconnected: %v", u.Name, err)
+		} else {
+			u.mu.Lock()
+			u.err = err
+			u.mu.Unlock()
+			u.g.logger.Printf("gateway: connecting to %s: %v", u.Name, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-u.g.ctx.Done():
+			return
+		}
+		delay = min(2*delay, u.g.maxRetry)
+	}
+}
+
+func (u *upstream) connect(ctx context.Context) (*client.Client, error) {
+	tr, err := u.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := append(u.g.clientOpts[:len(u.g.clientOpts):len(u.g.clientOpts)],
+		client.WithNotificationHandler(func(_ context.Context, method string, params json.RawMessage) {
+			u.g.notify(u, method, params)
+		}))
+	c, err := client.Connect(ctx, tr, opts...)
+	if err != nil {
+		tr.Close()
+		return nil, err
+	}
+	u.mu.Lock()
+	u.c, u.err = c, nil
+	u.mu.Unlock()
+	if err := u.sync(ctx); err != nil {
+		c.Close()
+		u.disconnect(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// disconnect removes u's entries from the registry.
+func (u *upstream) disconnect(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.c, u.err = nil, err
+	u.unregister()
+}
+
+func (u *upstream) unregister() {
+	reg := u.g.reg
+	for _, name := range u.tools {
+		reg.RemoveTool(name)
+	}
+	for _, uri := range u.resources {
+		reg.RemoveResource(uri)
+	}
+	for _, tmpl := range u.templates {
+		reg.RemoveResourceTemplate(tmpl)
+	}
+	for _, name := range u.prompts {
+		reg.RemovePrompt(name)
+	}
+	u.tools, u.resources, u.templates, u.prompts = nil, nil, nil, nil
+}
+
+// listOrEmpty treats a server not implementing a list method as offering
+// nothing.
+func listOrEmpty[T any](items []T, err error) ([]T, error) {
+	var rerr *rpc.Error
+	if errors.As(err, &rerr) && rerr.Code == -32601 {
+		return nil, nil
+	}
+	return items, err
+}
+
+// sync lists everything u offers and replaces its entries in the registry.
+func (u *upstream) sync(ctx context.Context) error {
+	c := u.client()
+	if c == nil {
+		return nil
+	}
+	tools, err := listOrEmpty(c.ListTools(ctx))
+	if err != nil {
+		return fmt.Errorf("listing tools: %w", err)
+	}
+	resources, err := listOrEmpty(c.ListResources(ctx))
+	if err != nil {
+		return fmt.Errorf("listing resources: %w", err)
+	}
+	templates, err := listOrEmpty(c.ListResourceTemplates(ctx))
+	if err != nil {
+		return fmt.Errorf("listing resource templates: %w", err)
+	}
+	prompts, err := listOrEmpty(c.ListPrompts(ctx))
+	if err != nil {
+		return fmt.Errorf("listing prompts: %w", err)
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.c != c {
+		// disconnected meanwhile
+		return nil
+	}
+	u.unregister()
+	reg := u.g.reg
+	for _, t := range tools {
+		name := u.Prefix + t.Name
+		if reg.FindTool(name) != nil {
+			u.g.logger.Printf("gateway: %s: tool %s is already registered", u.Name, name)
+			continue
+		}
+		opts := []registry.ToolOption{registry.WithDescription(t.Description)}
+		if t.OutputSchema != nil {
+			opts = append(opts, registry.WithOutputSchema(t.OutputSchema))
+		}
+		upstreamName := t.Name
+		registry.RegisterRawTool(reg, name, t.InputSchema, func(ctx context.Context, args json.RawMessage) (json.RawMessage, error) {
+			return u.g.forward(ctx, u, "tools/call", map[string]any{"name": upstreamName, "arguments": args})
+		}, opts...)
+		u.tools = append(u.tools, name)
+	}
+	read := func(ctx context.Context, uri string) (json.RawMessage, error) {
+		return u.g.forward(ctx, u, "resources/read", map[string]any{"uri": uri})
+	}
+	for _, r := range resources {
+		if reg.FindResource(r.URI) != nil || reg.FindRawResource(r.URI) != nil {
+			u.g.logger.Printf("gateway: %s: resource %s is already registered", u.Name, r.URI)
+			continue
+		}
+		registry.RegisterRawResource(reg, u.Prefix+r.Name, r.URI, read,
+			registry.WithResourceDescription(r.Description), registry.WithMimeType(r.MimeType))
+		u.resources = append(u.resources, r.URI)
+	}
+	for _, t := range templates {
+		if reg.FindResourceTemplate(t.URITemplate) != nil {
+			u.g.logger.Printf("gateway: %s: resource template %s is already registered", u.Name, t.URITemplate)
+			continue
+		}
+		opts := []registry.ResourceTemplateOption{registry.WithTemplateMimeType(t.MimeType)}
+		if t.Description != "" {
+			opts = append(opts, registry.WithTemplateDescription(t.Description))
+		}
+		registry.RegisterRawResourceTemplate(reg, u.Prefix+t.Name, t.URITemplate, read, opts...)
+		u.templates = append(u.templates, t.URITemplate)
+	}
+	for _, p := range prompts {
+		name := u.Prefix + p.Name
+		if reg.FindPrompt(name) != nil {
+			u.g.logger.Printf("gateway: %s: prompt %s is already registered", u.Name, name)
+			continue
+		}
+		opts := []registry.PromptOption{registry.WithPromptDescription(p.Description)}
+		for _, arg := range p.Arguments {
+			opts = append(opts, registry.WithPromptArgument(arg.Name, arg.Description, arg.Required))
+		}
+		upstreamName := p.Name
+		registry.RegisterPrompt(reg, name, func(ctx context.Context, args map[string]string) (*registry.PromptResult, error) {
+			raw, err := u.g.forward(ctx, u, "prompts/get", map[string]any{"name": upstreamName, "arguments": args})
+			if err != nil {
+				return nil, err
+			}
+			var res registry.PromptResult
+			if err := json.Unmarshal(raw, &res); err != nil {
+				return nil, fmt.Errorf("upstream %s: decoding prompt: %w", u.Name, err)
+			}
+			return &res, nil
+		}, opts...)
+		u.prompts = append(u.prompts, name)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package registry
+
+// ChangeKind names the list affected by a change to a Registry.
+type ChangeKind string
+
+const (
+	ToolsChanged     ChangeKind = "tools"
+	ResourcesChanged ChangeKind = "resources"
+	PromptsChanged   ChangeKind = "prompts"
+)
+
+// OnChange calls fn after every registration or removal, with the kind of
+// list that changed. The returned function stops the calls.
+func (r *Registry) OnChange(fn func(ChangeKind)) (stop func()) {
+	r.lmu.Lock()
+	defer r.lmu.Unlock()
+	if r.listeners == nil {
+		r.listeners = make(map[int]func(ChangeKind))
+	}
+	id := r.nextL
+	r.nextL++
+	r.listeners[id] = fn
+	return func() {
+		r.lmu.Lock()
+		defer r.lmu.Unlock()
+		delete(r.listeners, id)
+	}
+}
+
+func (r *Registry) changed(kind ChangeKind) {
+	r.lmu.Lock()
+	fns := make([]func(ChangeKind), 0, len(r.listeners))
+	for _, fn := range r.listeners {
+		fns = append(fns, fn)
+	}
+	r.lmu.Unlock()
+	for _, fn := range fns {
+		fn(kind)
+	}
+}
+
+// RemoveTool unregisters the named tool and reports whether it existed.
+func (r *Registry) RemoveTool(name string) bool {
+	r.mu.Lock()
+	_, ok := r.tools[name]
+	delete(r.tools, name)
+	r.mu.Unlock()
+	if ok {
+		r.changed(ToolsChanged)
+	}
+	return ok
+}
+
+// RemoveResource unregisters the resource at uri and reports whether it
+// existed.
+func (r *Registry) RemoveResource(uri string) bool {
+	r.mu.Lock()
+	_, ok := r.resources[uri]
+	delete(r.resources, uri)
+	r.mu.Unlock()
+	if ok {
+		r.changed(ResourcesChanged)
+	}
+	return ok
+}
+
+// RemoveResourceTemplate unregisters the template and reports whether it
+// existed.
+func (r *Registry) RemoveResourceTemplate(uriTemplate string) bool {
+	r.mu.Lock()
+	_, ok := r.resourceTemplates[uriTemplate]
+	delete(r.resourceTemplates, uriTemplate)
+	r.mu.Unlock()
+	if ok {
+		r.changed(ResourcesChanged)
+	}
+	return ok
+}
+
+// RemovePrompt unregisters the named prompt and reports whether it existed.
+func (r *Registry) RemovePrompt(name string) bool {
+	r.mu.Lock()
+	_, ok := r.prompts[name]
+	delete(r.prompts, name)
+	r.mu.Unlock()
+	if ok {
+		r.changed(PromptsChanged)
+	}
+	return ok
+}
@@ -0,0 +1,23 @@
+package registry
+
+// Option configures a Registry.
+type Option func(*Registry)
+
+// WithSchemaDialect stamps "$schema" with the given meta-schema URI, e.g.
+// schema.Draft202012, on every generated schema.
+func WithSchemaDialect(uri string) Option {
+	return func(r *Registry) { r.reflector.Dialect = uri }
+}
+
+// WithAdditionalProperties sets whether generated object schemas accept
+// properties their struct does not declare. The default is false.
+func WithAdditionalProperties(allow bool) Option {
+	return func(r *Registry) { r.reflector.AllowAdditionalProperties = allow }
+}
+
+// WithStrictSchemas generates schemas compatible with providers' strict
+// function calling: all properties are required, optional ones are nullable
+// and additional properties are rejected.
+func WithStrictSchemas() Option {
+	return func(r *Registry) { r.reflector.Strict = true }
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"context"
+	"sort"
+)
+
+// PromptDesc describes a prompt template offered to clients.
+type PromptDesc struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+	// RequiredScopes must all be granted to a caller to list or get the
+	// prompt.
+	RequiredScopes []string      `json:"-"`
+	Handler        PromptHandler `json:"-"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage is a message of a rendered prompt. Content is a content
+// block such as rpc.NewTextContent("...").
+type PromptMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// PromptResult is the result of prompts/get.
+type PromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// PromptHandler renders a prompt with the arguments given by the client.
+type PromptHandler func(ctx context.Context, args map[string]string) (*PromptResult, error)
+
+type PromptOption func(*PromptDesc)
+
+func WithPromptDescription(desc string) PromptOption {
+	return func(p *PromptDesc) { p.Description = desc }
+}
+
+// WithPromptArgument declares an argument of the prompt. Required
+// arguments are checked before the handler is called.
+func WithPromptArgument(name, description string, required bool) PromptOption {
+	return func(p *PromptDesc) {
+		p.Arguments = append(p.Arguments, PromptArgument{Name: name, Description: description, Required: required})
+	}
+}
+
+// WithPromptRequiredScopes restricts the prompt to callers granted every
+// one of scopes. See WithRequiredScopes.
+func WithPromptRequiredScopes(scopes ...string) PromptOption {
+	return func(p *PromptDesc) { p.RequiredScopes = append(p.RequiredScopes, scopes...) }
+}
+
+func RegisterPrompt(r *Registry, name string, handler PromptHandler, opts ...PromptOption) *Registry {
+	defer r.changed(PromptsChanged)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := &PromptDesc{Name: name, Handler: handler}
+	for _, opt := range opts {
+		opt(desc)
+	}
+	if r.prompts == nil {
+		r.prompts = make(map[string]*PromptDesc)
+	}
+	r.prompts[name] = desc
+	return r
+}
+
+// Prompts returns the registered prompts without their handlers, sorted by
+// name.
+func (r *Registry) Prompts() []*PromptDesc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*PromptDesc, 0, len(r.prompts))
+	for _, p := range r.prompts {
+		clone := *p
+		clone.Handler = nil
+		out = append(out, &clone)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (r *Registry) FindPrompt(name string) *PromptDesc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.prompts[name]
+}
@@ -0,0 +1,72 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cyrusaf/mcp/schema"
+)
+
+// RawToolHandler serves a tool without Go types, for example by forwarding
+// it to another server. It receives the arguments object and returns the
+// complete tools/call result, which is sent unchanged.
+type RawToolHandler func(ctx context.Context, args json.RawMessage) (json.RawMessage, error)
+
+// RawResourceHandler reads a resource without Go types. It returns the
+// complete resources/read result, which is sent unchanged.
+type RawResourceHandler func(ctx context.Context, uri string) (json.RawMessage, error)
+
+// RegisterRawTool registers a tool accepting arguments described by input.
+// Arguments are not validated against input before handler is called.
+func RegisterRawTool(r *Registry, name string, input *schema.Schema, handler RawToolHandler, opts ...ToolOption) *Registry {
+	defer r.changed(ToolsChanged)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := &ToolDesc{Name: name, Raw: handler}
+	if input != nil {
+		desc.InputSchema = *input
+	} else {
+		desc.InputSchema = schema.Schema{Type: "object"}
+	}
+	for _, opt := range opts {
+		opt(desc)
+	}
+	if r.tools == nil {
+		r.tools = make(map[string]*ToolDesc)
+	}
+	r.tools[name] = desc
+	return r
+}
+
+// RegisterRawResource registers the resource at uri, read by handler.
+func RegisterRawResource(r *Registry, name, uri string, handler RawResourceHandler, opts ...ResourceOption) *Registry {
+	defer r.changed(ResourcesChanged)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := &ResourceDesc{Name: name, URI: uri, Raw: handler}
+	for _, opt := range opts {
+		opt(desc)
+	}
+	if r.resources == nil {
+		r.resources = make(map[string]*ResourceDesc)
+	}
+	r.resources[uri] = desc
+	return r
+}
+
+// RegisterRawResourceTemplate registers the resources matching uriTemplate,
+// read by handler.
+func RegisterRawResourceTemplate(r *Registry, name, uriTemplate string, handler RawResourceHandler, opts ...ResourceTemplateOption) *Registry {
+	defer r.changed(ResourcesChanged)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	desc := &ResourceTemplateDesc{Name: name, URITemplate: uriTemplate, Raw: handler}
+	for _, opt := range opts {
+		opt(desc)
+	}
+	if r.resourceTemplates == nil {
+		r.resourceTemplates = make(map[string]*ResourceTemplateDesc)
+	}
+	r.resourceTemplates[uriTemplate] = desc
+	return r
+}
@@ -3,6 +3,7 @@ package registry
 import (
 	"context"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
@@ -14,17 +15,46 @@ type Registry struct {
 	resources         map[string]*ResourceDesc
 	resourceTemplates map[string]*ResourceTemplateDesc
 	tools             map[string]*ToolDesc
+	prompts           map[string]*PromptDesc
+	reflector         schema.Reflector
+
+	lmu       sync.Mutex
+	listeners map[int]func(ChangeKind)
+	nextL     int
 }
 
-func New() *Registry {
-	return &Registry{
+func New(opts ...Option) *Registry {
+	r := &Registry{
 		resources:         make(map[string]*ResourceDesc),
 		resourceTemplates: make(map[string]*ResourceTemplateDesc),
 		tools:             make(map[string]*ToolDesc),
+		prompts:           make(map[string]*PromptDesc),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
+}
+
+// OverrideSchema makes r describe every occurrence of T, including nested
+// fields and slice elements, with s instead of the reflected schema. It only
+// affects tools and resources registered afterwards.
+func OverrideSchema[T any](r *Registry, s *schema.Schema) *Registry {
+	r.reflector.Override(reflect.TypeOf((*T)(nil)).Elem(), s)
+	return r
+}
+
+// SetAdditionalProperties controls whether the struct type T accepts
+// properties it does not declare, overriding struct tags and
+// WithAdditionalProperties. It only affects tools and resources registered
+// afterwards.
+func SetAdditionalProperties[T any](r *Registry, allow bool) *Registry {
+	r.reflector.SetAdditionalProperties(reflect.TypeOf((*T)(nil)).Elem(), allow)
+	return r
 }
 
 func RegisterResource[T any](r *Registry, name, uri string, handler func(context.Context, string) (T, error), opts ...ResourceOption) *Registry {
+	defer r.changed(ResourcesChanged)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	desc := &ResourceDesc{Name: name, URI: uri, Handler: ResourceHandlerFunc(handler)}
@@ -34,9 +64,9 @@ func RegisterResource[T any](r *Registry, name, uri string, handler func(context
 	var zero T
 	if desc.JSONSchema == nil {
 		if desc.Handler != nil {
-			desc.JSONSchema = schema.ReflectFromType(desc.Handler.Resp())
+			desc.JSONSchema = r.reflector.ReflectFromType(desc.Handler.Resp())
 		} else {
-			desc.JSONSchema = schema.ReflectFromType(reflect.TypeOf(zero))
+			desc.JSONSchema = r.reflector.ReflectFromType(reflect.TypeOf(zero))
 		}
 	}
 	if r.resources == nil {
@@ -47,6 +77,7 @@ func RegisterResource[T any](r *Registry, name, uri string, handler func(context
 }
 
 func RegisterResourceTemplate[T any](r *Registry, name, uriTemplate string, handler func(context.Context, string) (T, error), opts ...ResourceTemplateOption) *Registry {
+	defer r.changed(ResourcesChanged)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	desc := &ResourceTemplateDesc{Name: name, URITemplate: uriTemplate, Handler: ResourceHandlerFunc(handler)}
@@ -56,9 +87,9 @@ func RegisterResourceTemplate[T any](r *Registry, name, uriTemplate string, hand
 	var zero T
 	if desc.JSONSchema == nil {
 		if desc.Handler != nil {
-			desc.JSONSchema = schema.ReflectFromType(desc.Handler.Resp())
+			desc.JSONSchema = r.reflector.ReflectFromType(desc.Handler.Resp())
 		} else {
-			desc.JSONSchema = schema.ReflectFromType(reflect.TypeOf(zero))
+			desc.JSONSchema = r.reflector.ReflectFromType(reflect.TypeOf(zero))
 		}
 	}
 	if r.resourceTemplates == nil {
@@ -69,14 +100,24 @@ func RegisterResourceTemplate[T any](r *Registry, name, uriTemplate string, hand
 }
 
 func RegisterTool[Req any, Resp any](r *Registry, name string, fn func(context.Context, Req) (Resp, error), opts ...ToolOption) *Registry {
+	defer r.changed(ToolsChanged)
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	desc := &ToolDesc{Name: name, Handler: HandlerFunc(fn)}
 	for _, opt := range opts {
 		opt(desc)
 	}
-	desc.InputSchema = *schema.ReflectFromType(desc.Handler.Req())
-	desc.OutputSchema = schema.ReflectFromType(desc.Handler.Resp())
+	desc.InputSchema = *r.reflector.ReflectFromType(desc.Handler.Req())
+	if desc.OutputSchema == nil {
+		desc.OutputSchema = r.reflector.ReflectFromType(desc.Handler.Resp())
+	}
+	// tool schemas describe the arguments and structured content objects
+	// themselves, which are never null; the output schema may belong to
+	// the caller, so change a copy
+	out := *desc.OutputSchema
+	out.Nullable = false
+	desc.OutputSchema = &out
+	desc.InputSchema.Nullable = false
 	if r.tools == nil {
 		r.tools = make(map[string]*ToolDesc)
 	}
@@ -84,15 +125,17 @@ func RegisterTool[Req any, Resp any](r *Registry, name string, fn func(context.C
 	return r
 }
 
+// Tools returns the registered tools sorted by name.
 func (r *Registry) Tools() []*ToolDesc {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	out := make([]*ToolDesc, 0, len(r.tools))
 	for _, t := range r.tools {
 		clone := *t
-		clone.Handler = nil
+		clone.Handler, clone.Raw = nil, nil
 		out = append(out, &clone)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
 	return out
 }
 
@@ -102,12 +145,13 @@ func (r *Registry) ToolsMap() map[string]*ToolDesc {
 	out := make(map[string]*ToolDesc, len(r.tools))
 	for name, t := range r.tools {
 		clone := *t
-		clone.Handler = nil
+		clone.Handler, clone.Raw = nil, nil
 		out[name] = &clone
 	}
 	return out
 }
 
+// Resources returns the registered resources sorted by URI.
 func (r *Registry) Resources() []*ResourceDesc {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -116,18 +160,21 @@ func (r *Registry) Resources() []*ResourceDesc {
 		clone := *res
 		out = append(out, &clone)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URI < out[j].URI })
 	return out
 }
 
+// ResourceTemplates returns the registered templates sorted by URI template.
 func (r *Registry) ResourceTemplates() []*ResourceTemplateDesc {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	out := make([]*ResourceTemplateDesc, 0, len(r.resourceTemplates))
 	for _, res := range r.resourceTemplates {
 		clone := *res
-		clone.Handler = nil
+		clone.Handler, clone.Raw = nil, nil
 		out = append(out, &clone)
 	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URITemplate < out[j].URITemplate })
 	return out
 }
 
@@ -142,28 +189,75 @@ func (r *Registry) ResourcesMap() map[string]*ResourceDesc {
 	return out
 }
 
-func (r *Registry) findResource(uri string) rawResourceHandler {
+// ResourceMatch is the resource or template serving a URI. Exactly one of
+// Handler and Raw is set.
+type ResourceMatch struct {
+	Handler        rawResourceHandler
+	Raw            RawResourceHandler
+	RequiredScopes []string
+}
+
+// MatchResource returns the resource registered for uri or, failing that,
+// the template whose literal prefix is the longest one matching uri. It
+// returns nil if nothing serves uri. Handler and scopes come from the same
+// entry, so callers should check the scopes of the returned match rather
+// than look them up again.
+func (r *Registry) MatchResource(uri string) *ResourceMatch {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findResource(uri)
+}
+
+func (r *Registry) findResource(uri string) *ResourceMatch {
 	if res, ok := r.resources[uri]; ok {
-		return res.Handler
+		return &ResourceMatch{res.Handler, res.Raw, res.RequiredScopes}
 	}
+	var best *ResourceTemplateDesc
+	bestLen := -1
 	for _, res := range r.resourceTemplates {
 		tmpl := res.URITemplate
+		n := -1
 		if i := strings.Index(tmpl, "{"); i > 0 {
-			prefix := tmpl[:i]
-			if strings.HasPrefix(uri, prefix) {
-				return res.Handler
+			if strings.HasPrefix(uri, tmpl[:i]) {
+				n = i
 			}
 		} else if tmpl == uri {
-			return res.Handler
+			n = len(tmpl)
+		}
+		// the map order is random, so ties go to the smaller template
+		if n > bestLen || (n == bestLen && n >= 0 && tmpl < best.URITemplate) {
+			best, bestLen = res, n
 		}
 	}
-	return nil
+	if best == nil {
+		return nil
+	}
+	return &ResourceMatch{best.Handler, best.Raw, best.RequiredScopes}
 }
 
 func (r *Registry) FindResource(uri string) rawResourceHandler {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.findResource(uri)
+	if m := r.MatchResource(uri); m != nil {
+		return m.Handler
+	}
+	return nil
+}
+
+// FindRawResource returns the raw handler serving uri, if the resource or
+// template matching it was registered with RegisterRawResource or
+// RegisterRawResourceTemplate.
+func (r *Registry) FindRawResource(uri string) RawResourceHandler {
+	if m := r.MatchResource(uri); m != nil {
+		return m.Raw
+	}
+	return nil
+}
+
+// ResourceScopes returns the scopes required to read the resource at uri.
+func (r *Registry) ResourceScopes(uri string) []string {
+	if m := r.MatchResource(uri); m != nil {
+		return m.RequiredScopes
+	}
+	return nil
 }
 
 func (r *Registry) findTool(name string) *ToolDesc {
@@ -178,3 +272,11 @@ func (r *Registry) FindTool(name string) *ToolDesc {
 	defer r.mu.RUnlock()
 	return r.findTool(name)
 }
+
+// FindResourceTemplate returns the template registered for uriTemplate,
+// including its handler, or nil.
+func (r *Registry) FindResourceTemplate(uriTemplate string) *ResourceTemplateDesc {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.resourceTemplates[uriTemplate]
+}
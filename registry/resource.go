@@ -8,10 +8,16 @@ import (
 )
 
 type ResourceDesc struct {
-	Name       string             `json:"name"`
-	URI        string             `json:"uri"`
-	JSONSchema *schema.Schema     `json:"json_schema,omitempty"`
-	Handler    rawResourceHandler `json:"-"`
+	Name        string         `json:"name"`
+	URI         string         `json:"uri"`
+	Description string         `json:"description,omitempty"`
+	MimeType    string         `json:"mimeType,omitempty"`
+	JSONSchema  *schema.Schema `json:"json_schema,omitempty"`
+	// RequiredScopes must all be granted to a caller to list or read the
+	// resource.
+	RequiredScopes []string           `json:"-"`
+	Handler        rawResourceHandler `json:"-"`
+	Raw            RawResourceHandler `json:"-"`
 }
 
 type rawResourceHandler interface {
@@ -41,3 +47,19 @@ type ResourceOption func(*ResourceDesc)
 func WithSchema(s *schema.Schema) ResourceOption {
 	return func(r *ResourceDesc) { r.JSONSchema = s }
 }
+
+// WithResourceDescription sets the description listed for the resource.
+func WithResourceDescription(desc string) ResourceOption {
+	return func(r *ResourceDesc) { r.Description = desc }
+}
+
+// WithMimeType sets the MIME type listed for the resource.
+func WithMimeType(mimeType string) ResourceOption {
+	return func(r *ResourceDesc) { r.MimeType = mimeType }
+}
+
+// WithResourceRequiredScopes restricts the resource to callers granted
+// every one of scopes. See WithRequiredScopes.
+func WithResourceRequiredScopes(scopes ...string) ResourceOption {
+	return func(r *ResourceDesc) { r.RequiredScopes = append(r.RequiredScopes, scopes...) }
+}
@@ -5,11 +5,16 @@ import (
 )
 
 type ResourceTemplateDesc struct {
-	Name        string             `json:"name"`
-	URITemplate string             `json:"uriTemplate"`
-	JSONSchema  *schema.Schema     `json:"json_schema,omitempty"`
-	Description *string            `json:"description,omitempty"`
-	Handler     rawResourceHandler `json:"-"`
+	Name        string         `json:"name"`
+	URITemplate string         `json:"uriTemplate"`
+	JSONSchema  *schema.Schema `json:"json_schema,omitempty"`
+	Description *string        `json:"description,omitempty"`
+	MimeType    string         `json:"mimeType,omitempty"`
+	// RequiredScopes must all be granted to a caller to list the template
+	// or read resources matching it.
+	RequiredScopes []string           `json:"-"`
+	Handler        rawResourceHandler `json:"-"`
+	Raw            RawResourceHandler `json:"-"`
 }
 
 type ResourceTemplateOption func(*ResourceTemplateDesc)
@@ -21,3 +26,15 @@ func WithTemplateSchema(s *schema.Schema) ResourceTemplateOption {
 func WithTemplateDescription(desc string) ResourceTemplateOption {
 	return func(r *ResourceTemplateDesc) { r.Description = &desc }
 }
+
+// WithTemplateMimeType sets the MIME type of resources matching the
+// template.
+func WithTemplateMimeType(mimeType string) ResourceTemplateOption {
+	return func(r *ResourceTemplateDesc) { r.MimeType = mimeType }
+}
+
+// WithTemplateRequiredScopes restricts the template to callers granted
+// every one of scopes. See WithRequiredScopes.
+func WithTemplateRequiredScopes(scopes ...string) ResourceTemplateOption {
+	return func(r *ResourceTemplateDesc) { r.RequiredScopes = append(r.RequiredScopes, scopes...) }
+}
@@ -15,7 +15,11 @@ type ToolDesc struct {
 	Description  string         `json:"description"`
 	InputSchema  schema.Schema  `json:"inputSchema"`
 	OutputSchema *schema.Schema `json:"outputSchema,omitempty"`
-	Handler      rawHandler     `json:"-"`
+	// RequiredScopes must all be granted to a caller to list or call the
+	// tool.
+	RequiredScopes []string       `json:"-"`
+	Handler        rawHandler     `json:"-"`
+	Raw            RawToolHandler `json:"-"`
 }
 
 type rawHandler interface {
@@ -30,6 +34,19 @@ func WithDescription(desc string) ToolOption {
 	return func(t *ToolDesc) { t.Description = desc }
 }
 
+// WithOutputSchema describes the tool's structured content with s instead
+// of the schema reflected from its result type.
+func WithOutputSchema(s *schema.Schema) ToolOption {
+	return func(t *ToolDesc) { t.OutputSchema = s }
+}
+
+// WithRequiredScopes restricts the tool to callers granted every one of
+// scopes, such as the scopes of an OAuth access token. Other callers do not
+// see the tool in tools/list and cannot call it.
+func WithRequiredScopes(scopes ...string) ToolOption {
+	return func(t *ToolDesc) { t.RequiredScopes = append(t.RequiredScopes, scopes...) }
+}
+
 type handlerFunc[Req any, Resp any] struct {
 	f func(context.Context, Req) (Resp, error)
 }
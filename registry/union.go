@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"reflect"
+
+	"github.com/cyrusaf/mcp/schema"
+)
+
+type UnionOption func(*schema.Union)
+
+// WithAnyOf describes the union with "anyOf" instead of "oneOf".
+func WithAnyOf() UnionOption {
+	return func(u *schema.Union) { u.AnyOf = true }
+}
+
+// RegisterUnion declares the concrete variants of the interface type I, keyed
+// by the value of the discriminator property. Values of type I in tool
+// arguments and results are described as a oneOf over the variants, and
+// arguments are decoded into the variant the discriminator names. It only
+// affects tools and resources registered afterwards.
+//
+// RegisterUnion panics if I is not an interface type.
+func RegisterUnion[I any](r *Registry, discriminator string, variants map[string]I, opts ...UnionOption) *Registry {
+	u := schema.Union{Discriminator: discriminator, Variants: make(map[string]reflect.Type, len(variants))}
+	for name, v := range variants {
+		u.Variants[name] = reflect.TypeOf(v)
+	}
+	for _, opt := range opts {
+		opt(&u)
+	}
+	if err := r.reflector.RegisterUnion(reflect.TypeOf((*I)(nil)).Elem(), u); err != nil {
+		panic("registry: " + err.Error())
+	}
+	return r
+}
+
+// Unmarshal decodes the JSON document data into v, resolving registered
+// unions to their concrete variants.
+func (r *Registry) Unmarshal(data []byte, v any) error {
+	return r.reflector.Unmarshal(data, v)
+}
+
+// Marshal encodes v like json.Marshal, writing the discriminators of
+// registered unions so that the result matches the reflected schemas.
+func (r *Registry) Marshal(v any) ([]byte, error) {
+	return r.reflector.Marshal(v)
+}
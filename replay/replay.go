@@ -0,0 +1,383 @@
+// Package replay checks a server against traffic recorded with
+// transport.Recording.
+//
+// Run feeds the recorded client messages to a fresh rpc.Server one at a
+// time, waiting for the response to each request, and compares it with the
+// recorded response. Values that legitimately change between runs, such as
+// generated IDs and timestamps, can be excluded with ignore rules:
+//
+//	recs, err := replay.Load(f)
+//	...
+//	report, err := replay.Run(ctx, newRegistry(), recs,
+//		replay.IgnoreKeys("createdAt"),
+//		replay.IgnorePaths("result.content.*.text"),
+//		replay.IgnoreTimestamps())
+//	...
+//	for _, d := range report.Diffs {
+//		t.Error(d)
+//	}
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// Load reads the records written by transport.Recording.
+func Load(r io.Reader) ([]transport.Record, error) {
+	var recs []transport.Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), transport.DefaultMaxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var rec transport.Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("replay: line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, sc.Err()
+}
+
+type config struct {
+	serverOpts []rpc.ServerOption
+	timeout    time.Duration
+	ignore     []func(path []string, want, got any) bool
+}
+
+type Option func(*config)
+
+// WithServerOptions sets the options of the server under test.
+func WithServerOptions(opts ...rpc.ServerOption) Option {
+	return func(c *config) { c.serverOpts = append(c.serverOpts, opts...) }
+}
+
+// WithTimeout sets how long to wait for each response. The default is ten
+// seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) { c.timeout = d }
+}
+
+// IgnorePaths skips the values at the given paths of the responses. A path
+// lists object keys and array indices separated by dots, such as
+// "result.tools.0.description"; "*" matches any single key or index and
+// "**" any number of them.
+func IgnorePaths(patterns ...string) Option {
+	return func(c *config) {
+		for _, p := range patterns {
+			pattern := strings.Split(p, ".")
+			c.ignore = append(c.ignore, func(path []string, _, _ any) bool { return match(pattern, path) })
+		}
+	}
+}
+
+// IgnoreKeys skips the values of object keys with the given names anywhere
+// in the responses, such as generated "id" fields.
+func IgnoreKeys(names ...string) Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, func(path []string, _, _ any) bool {
+			if len(path) == 0 {
+				return false
+			}
+			for _, name := range names {
+				if path[len(path)-1] == name {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// IgnoreTimestamps skips differences between two RFC 3339 timestamps.
+func IgnoreTimestamps() Option {
+	return func(c *config) {
+		c.ignore = append(c.ignore, func(_ []string, want, got any) bool {
+			return isTimestamp(want) && isTimestamp(got)
+		})
+	}
+}
+
+func isTimestamp(v any) bool {
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	_, err := time.Parse(time.RFC3339Nano, s)
+	return err == nil
+}
+
+// Diff is a difference between a recorded and a replayed response.
+type Diff struct {
+	Session string
+	// Request is the recorded request.
+	Request json.RawMessage
+	// Path locates the differing value in the response; it is empty when
+	// the whole response is missing.
+	Path string
+	// Want and Got are the recorded and replayed values, or nil when the
+	// value is missing on that side.
+	Want, Got json.RawMessage
+}
+
+func (d Diff) String() string {
+	show := func(v json.RawMessage) string {
+		if v == nil {
+			return "(missing)"
+		}
+		return string(v)
+	}
+	where := d.Path
+	if where == "" {
+		where = "response"
+	}
+	return fmt.Sprintf("%s: %s: want %s, got %s", d.Request, where, show(d.Want), show(d.Got))
+}
+
+// Report is the outcome of a replay.
+type Report struct {
+	// Requests is the number of requests replayed.
+	Requests int
+	Diffs    []Diff
+}
+
+// Run replays the client messages in recs against a server for reg and
+// compares its responses with the recorded ones. Messages of different
+// sessions are replayed in their recorded order, each session on its own
+// transport session, and the messages of batches one at a time. Only
+// responses are compared; notifications are not, and requests recorded
+// without a response are sent without waiting.
+func Run(ctx context.Context, reg *registry.Registry, recs []transport.Record, opts ...Option) (*Report, error) {
+	cfg := config{timeout: 10 * time.Second}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	recorded := make(map[string]json.RawMessage)
+	for _, rec := range recs {
+		if rec.Direction != transport.DirectionOut {
+			continue
+		}
+		for _, msg := range split(rec.Message) {
+			if m := parse(msg); m.isResponse() {
+				recorded[rec.Session+" "+idKey(m.ID)] = msg
+			}
+		}
+	}
+
+	tr := &memTransport{in: make(chan memMessage), done: make(chan struct{})}
+	srv := rpc.NewServer(reg, tr, cfg.serverOpts...)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var runErr error
+	stopped := make(chan struct{})
+	go func() {
+		runErr = srv.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		// handlers of requests recorded without a response may still run
+		cancel()
+		tr.Close()
+		<-stopped
+	}()
+
+	sessions := make(map[string]*transport.Session)
+	report := &Report{}
+	for _, rec := range recs {
+		if rec.Direction != transport.DirectionIn {
+			continue
+		}
+		var sess *transport.Session
+		if rec.Session != "" {
+			if sess = sessions[rec.Session]; sess == nil {
+				sess = transport.NewSession()
+				sessions[rec.Session] = sess
+			}
+		}
+		// the messages of a batch are replayed one at a time
+		for _, msg := range split(rec.Message) {
+			conn := &memConn{session: sess, out: make(chan json.RawMessage, 16), done: make(chan struct{})}
+			select {
+			case tr.in <- memMessage{conn, msg}:
+			case <-stopped:
+				return nil, fmt.Errorf("replay: server stopped: %v", runErr)
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			m := parse(msg)
+			if !m.isRequest() {
+				continue
+			}
+			report.Requests++
+			want := recorded[rec.Session+" "+idKey(m.ID)]
+			if want == nil {
+				// the request was cancelled or the recording ended before
+				// its response; whatever the server replies now is dropped
+				conn.stop()
+				continue
+			}
+			got, err := conn.response(ctx, m.ID, cfg.timeout)
+			conn.stop()
+			if err != nil {
+				return nil, err
+			}
+			if got == nil {
+				report.Diffs = append(report.Diffs, Diff{Session: rec.Session, Request: msg, Want: want, Got: got})
+				continue
+			}
+			for _, d := range cfg.compare(want, got) {
+				d.Session, d.Request = rec.Session, msg
+				report.Diffs = append(report.Diffs, d)
+			}
+		}
+	}
+	return report, nil
+}
+
+// compare returns the differences between two messages.
+func (c *config) compare(want, got json.RawMessage) []Diff {
+	var w, g any
+	if decode(want, &w) != nil || decode(got, &g) != nil {
+		if bytes.Equal(want, got) {
+			return nil
+		}
+		return []Diff{{Want: want, Got: got}}
+	}
+	var diffs []Diff
+	c.walk(nil, w, g, &diffs)
+	return diffs
+}
+
+func decode(b []byte, v *any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// walk compares two decoded values, descending into objects, arrays and
+// strings holding JSON documents, such as the text of tool results.
+func (c *config) walk(path []string, want, got any, diffs *[]Diff) {
+	if c.ignored(path, want, got) {
+		return
+	}
+	if ws, ok := want.(string); ok {
+		if gs, ok := got.(string); ok && ws != gs {
+			var wv, gv any
+			if embedded(ws, &wv) && embedded(gs, &gv) {
+				c.walk(path, wv, gv, diffs)
+				return
+			}
+		}
+	}
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			break
+		}
+		for _, k := range keys(w, g) {
+			wv, win := w[k]
+			gv, gin := g[k]
+			p := append(path[:len(path):len(path)], k)
+			if win && gin {
+				c.walk(p, wv, gv, diffs)
+				continue
+			}
+			if c.ignored(p, wv, gv) {
+				continue
+			}
+			d := Diff{Path: strings.Join(p, ".")}
+			if win {
+				d.Want = marshal(wv)
+			}
+			if gin {
+				d.Got = marshal(gv)
+			}
+			*diffs = append(*diffs, d)
+		}
+		return
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			break
+		}
+		for i := range w {
+			c.walk(append(path[:len(path):len(path)], fmt.Sprint(i)), w[i], g[i], diffs)
+		}
+		return
+	}
+	if !bytes.Equal(marshal(want), marshal(got)) {
+		*diffs = append(*diffs, Diff{Path: strings.Join(path, "."), Want: marshal(want), Got: marshal(got)})
+	}
+}
+
+func (c *config) ignored(path []string, want, got any) bool {
+	for _, ignore := range c.ignore {
+		if ignore(path, want, got) {
+			return true
+		}
+	}
+	return false
+}
+
+// embedded decodes s if it holds a JSON object or array.
+func embedded(s string, v *any) bool {
+	s = strings.TrimSpace(s)
+	if s == "" || (s[0] != '{' && s[0] != '[') {
+		return false
+	}
+	return decode([]byte(s), v) == nil
+}
+
+func keys(a, b map[string]any) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, m := range []map[string]any{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func marshal(v any) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// match reports whether path matches the pattern segments.
+func match(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if match(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (pattern[0] != "*" && pattern[0] != path[0]) {
+		return false
+	}
+	return match(pattern[1:], path[1:])
+}
@@ -0,0 +1,190 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/replay"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type order struct {
+	ID      string    `json:"id"`
+	Item    string    `json:"item"`
+	Total   int       `json:"total"`
+	Created time.Time `json:"created"`
+}
+
+type orderIn struct {
+	Item string `json:"item"`
+}
+
+// newRegistry returns an order service whose IDs and timestamps differ on
+// every run. price sets the order totals.
+func newRegistry(price int) *registry.Registry {
+	var n int
+	reg := registry.New()
+	registry.RegisterTool(reg, "order", func(ctx context.Context, in orderIn) (order, error) {
+		n++
+		return order{
+			ID:      strings.Repeat("x", n) + time.Now().Format("150405.000000000"),
+			Item:    in.Item,
+			Total:   price,
+			Created: time.Now(),
+		}, nil
+	}, registry.WithDescription("Place an order."))
+	registry.RegisterResource(reg, "Menu", "mem://menu", func(ctx context.Context, uri string) ([]string, error) {
+		return []string{"tea", "cake"}, nil
+	})
+	return reg
+}
+
+const session = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+{"jsonrpc":"2.0","id":2,"method":"tools/list"}
+{"jsonrpc":"2.0","id":"a","method":"tools/call","params":{"name":"order","arguments":{"item":"tea"}}}
+{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"mem://menu"}}
+`
+
+func record(t *testing.T) []transport.Record {
+	t.Helper()
+	var out, log bytes.Buffer
+	tr := transport.Recording(transport.NewStdioTransport(strings.NewReader(session), &out), &log)
+	if err := rpc.NewServer(newRegistry(3), tr).Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	recs, err := replay.Load(&log)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	return recs
+}
+
+func TestReplay(t *testing.T) {
+	recs := record(t)
+	ctx := context.Background()
+
+	report, err := replay.Run(ctx, newRegistry(3), recs)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if report.Requests != 4 {
+		t.Fatalf("expected 4 requests, got %d", report.Requests)
+	}
+	var paths []string
+	for _, d := range report.Diffs {
+		paths = append(paths, d.Path)
+	}
+	// the generated values differ in both the text and structured content
+	want := "result.content.0.text.created result.content.0.text.id result.structuredContent.created result.structuredContent.id"
+	if strings.Join(paths, " ") != want {
+		t.Fatalf("unexpected diffs %v", report.Diffs)
+	}
+
+	report, err = replay.Run(ctx, newRegistry(3), recs, replay.IgnoreKeys("id"), replay.IgnoreTimestamps())
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(report.Diffs) != 0 {
+		t.Fatalf("unexpected diffs %v", report.Diffs)
+	}
+
+	report, err = replay.Run(ctx, newRegistry(4), recs,
+		replay.IgnorePaths("**.id", "result.*.created", "result.content.*.text.created"))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(report.Diffs) != 2 {
+		t.Fatalf("expected the totals to differ, got %v", report.Diffs)
+	}
+	d := report.Diffs[0]
+	if d.Path != "result.content.0.text.total" || string(d.Want) != "3" || string(d.Got) != "4" {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+	if s := d.String(); !strings.Contains(s, `"method":"tools/call"`) || !strings.HasSuffix(s, "result.content.0.text.total: want 3, got 4") {
+		t.Fatalf("unexpected diff text %q", s)
+	}
+}
+
+func TestReplayChangedRegistry(t *testing.T) {
+	recs := record(t)
+	reg := registry.New()
+	registry.RegisterTool(reg, "order", func(ctx context.Context, in orderIn) (order, error) {
+		return order{}, nil
+	}, registry.WithDescription("Place an order for pickup."))
+
+	report, err := replay.Run(context.Background(), reg, recs, replay.IgnorePaths("result.content", "result.structuredContent"))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	var got []string
+	for _, d := range report.Diffs {
+		got = append(got, d.Path)
+	}
+	want := []string{"result.tools.0.description", "error", "result"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("unexpected diffs %v", report.Diffs)
+	}
+}
+
+func TestReplayMissingResponse(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	reg := registry.New()
+	registry.RegisterTool(reg, "wait", func(ctx context.Context, in struct{}) (struct{}, error) {
+		<-release
+		return struct{}{}, nil
+	})
+	recs := []transport.Record{
+		{Direction: transport.DirectionIn, Message: []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"wait"}}`)},
+		{Direction: transport.DirectionOut, Message: []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`)},
+	}
+	report, err := replay.Run(context.Background(), reg, recs, replay.WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if len(report.Diffs) != 1 || report.Diffs[0].Got != nil || !strings.Contains(report.Diffs[0].String(), "got (missing)") {
+		t.Fatalf("unexpected diffs %v", report.Diffs)
+	}
+}
+
+func TestReplayBatch(t *testing.T) {
+	recs := []transport.Record{
+		{Direction: transport.DirectionIn, Message: []byte(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`)},
+		{Direction: transport.DirectionOut, Message: []byte(`[{"jsonrpc":"2.0","id":1,"result":{}},{"jsonrpc":"2.0","id":2,"result":{"x":1}}]`)},
+	}
+	report, err := replay.Run(context.Background(), registry.New(), recs)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if report.Requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", report.Requests)
+	}
+	if len(report.Diffs) != 1 || report.Diffs[0].Path != "result.x" || !strings.Contains(string(report.Diffs[0].Request), `"id":2`) {
+		t.Fatalf("unexpected diffs %v", report.Diffs)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	recs, err := replay.Load(strings.NewReader(`{"time":"2024-01-02T03:04:05Z","direction":"in","session":"s","message":{"id":1}}
+
+{"time":"2024-01-02T03:04:06Z","direction":"out","message":{"id":1,"result":{}}}
+`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(recs) != 2 || recs[0].Session != "s" || recs[1].Direction != transport.DirectionOut {
+		t.Fatalf("unexpected records %+v", recs)
+	}
+	if _, err := replay.Load(strings.NewReader("{}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected a line 2 error, got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// message holds the fields used to tell requests from responses.
+type message struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  json.RawMessage `json:"error"`
+}
+
+func parse(raw json.RawMessage) message {
+	var m message
+	_ = json.Unmarshal(raw, &m)
+	return m
+}
+
+func (m message) hasID() bool {
+	return len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+func (m message) isRequest() bool { return m.Method != "" && m.hasID() }
+
+func (m message) isResponse() bool {
+	return m.Method == "" && m.hasID() && (m.Result != nil || m.Error != nil)
+}
+
+// split returns the messages of a JSON-RPC batch, or raw alone if it is
+// not one.
+func split(raw json.RawMessage) []json.RawMessage {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '[' {
+		return []json.RawMessage{raw}
+	}
+	var batch []json.RawMessage
+	if err := json.Unmarshal(trimmed, &batch); err != nil {
+		return []json.RawMessage{raw}
+	}
+	return batch
+}
+
+// idKey normalizes a request ID for use as a map key.
+func idKey(id json.RawMessage) string {
+	return string(compactJSON(id))
+}
+
+func compactJSON(b json.RawMessage) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	return marshal(v)
+}
+
+type memMessage struct {
+	conn *memConn
+	msg  json.RawMessage
+}
+
+// memTransport hands the replayed messages to the server.
+type memTransport struct {
+	in   chan memMessage
+	done chan struct{}
+	once sync.Once
+}
+
+func (t *memTransport) Next(ctx context.Context) (transport.Conn, json.RawMessage, error) {
+	select {
+	case m := <-t.in:
+		return m.conn, m.msg, nil
+	case <-t.done:
+		return nil, nil, io.EOF
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// Notify drops server-initiated notifications, which are not compared.
+func (t *memTransport) Notify(ctx context.Context, msg json.RawMessage) error { return nil }
+
+func (t *memTransport) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
+
+var errConnDone = errors.New("replay: request finished")
+
+// memConn collects the messages the server sends for one replayed message.
+type memConn struct {
+	session *transport.Session
+	out     chan json.RawMessage
+	done    chan struct{}
+	once    sync.Once
+}
+
+func (c *memConn) Session() *transport.Session { return c.session }
+
+func (c *memConn) Send(ctx context.Context, msg json.RawMessage) error {
+	select {
+	case c.out <- msg:
+		return nil
+	case <-c.done:
+		return errConnDone
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stop drops anything sent on c from now on.
+func (c *memConn) stop() {
+	c.once.Do(func() { close(c.done) })
+}
+
+// response waits for the response to the request with the given ID,
+// skipping notifications and server requests. It returns nil if none
+// arrives within timeout.
+func (c *memConn) response(ctx context.Context, id json.RawMessage, timeout time.Duration) (json.RawMessage, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	key := idKey(id)
+	for {
+		select {
+		case msg := <-c.out:
+			if m := parse(msg); m.isResponse() && idKey(m.ID) == key {
+				return msg, nil
+			}
+		case <-timer.C:
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
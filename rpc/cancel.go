@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// inflightKey identifies a request by the session it arrived on and its ID.
+func inflightKey(conn transport.Conn, id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		buf.Reset()
+		buf.Write(id)
+	}
+	if sc, ok := conn.(transport.SessionConn); ok && sc.Session() != nil {
+		return sc.Session().ID + " " + buf.String()
+	}
+	return buf.String()
+}
+
+// track makes the request cancellable through notifications/cancelled. The
+// returned function must be called when the handler is done.
+func (s *Server) track(ctx context.Context, conn transport.Conn, id json.RawMessage) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	key := inflightKey(conn, id)
+	s.mu.Lock()
+	if s.inflight == nil {
+		s.inflight = make(map[string]context.CancelFunc)
+	}
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.inflight, key)
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// cancelRequest handles a notifications/cancelled message from the client.
+func (s *Server) cancelRequest(conn transport.Conn, params json.RawMessage) {
+	var p struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(params, &p) != nil || len(p.RequestID) == 0 {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.inflight[inflightKey(conn, p.RequestID)]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
@@ -1,16 +1,59 @@
 package rpc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cyrusaf/mcp/schema"
+)
 
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
 }
 
 type rpcError = Error
 
+// handlerError converts an error returned by a tool, resource or prompt
+// handler. Handlers may return an *Error to choose the code sent.
+func handlerError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Code: -32000, Message: err.Error()}
+}
+
 func ErrorMethodNotFound(method string) *Error {
 	return &Error{Code: -32601, Message: fmt.Sprintf("method not found: %s", method)}
 }
 
 var ErrInvalidParams = &Error{Code: -32602, Message: "invalid params"}
+
+// ErrorForbidden returns the error sent when the caller lacks some of the
+// scopes required by a tool or resource.
+func ErrorForbidden(scopes []string) *Error {
+	return &Error{
+		Code:    -32003,
+		Message: fmt.Sprintf("forbidden: requires scopes %s", strings.Join(scopes, " ")),
+		Data:    map[string]any{"requiredScopes": scopes},
+	}
+}
+
+// ErrorInvalidParams returns an invalid params error describing err. Schema
+// validation failures are attached as Data so callers can see every
+// offending path.
+func ErrorInvalidParams(err error) *Error {
+	e := &Error{Code: ErrInvalidParams.Code, Message: fmt.Sprintf("invalid params: %v", err)}
+	var verrs schema.ValidationErrors
+	if errors.As(err, &verrs) {
+		e.Data = map[string]any{"errors": verrs}
+	}
+	return e
+}
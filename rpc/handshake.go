@@ -1,5 +1,10 @@
 package rpc
 
+import (
+	"encoding/json"
+	"slices"
+)
+
 // InitializeResult describes the response payload for the JSON-RPC "initialize" call.
 type InitializeResult struct {
 	ProtocolVersion string `json:"protocolVersion"`
@@ -16,7 +21,29 @@ type InitializeResult struct {
 			Subscribe   bool `json:"subscribe"`
 		} `json:"resources"`
 		Prompts struct {
-			Offered bool `json:"offered"`
+			Offered     bool `json:"offered"`
+			ListChanged bool `json:"listChanged"`
 		} `json:"prompts"`
 	} `json:"capabilities"`
 }
+
+// LatestProtocolVersion is the newest protocol revision the server speaks.
+const LatestProtocolVersion = "2025-03-26"
+
+// SupportedProtocolVersions lists the protocol revisions the server speaks,
+// newest first.
+var SupportedProtocolVersions = []string{LatestProtocolVersion, "2024-11-05"}
+
+// negotiateVersion returns the protocol version to answer an initialize
+// request with: the version the client asked for if the server speaks it,
+// and otherwise the latest one, which the client may then reject.
+func negotiateVersion(params json.RawMessage) string {
+	var p struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	_ = json.Unmarshal(params, &p)
+	if slices.Contains(SupportedProtocolVersions, p.ProtocolVersion) {
+		return p.ProtocolVersion
+	}
+	return LatestProtocolVersion
+}
@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// ErrNoRequest is returned by Notify and NotifyProgress when ctx does not
+// belong to a request being handled by a Server.
+var ErrNoRequest = errors.New("rpc: context does not carry a request")
+
+type rpcNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type requestKey struct{}
+
+// requestInfo describes the request a handler is serving.
+type requestInfo struct {
+	conn          transport.Conn
+	progressToken json.RawMessage
+}
+
+func withRequest(ctx context.Context, conn transport.Conn, params json.RawMessage) context.Context {
+	info := &requestInfo{conn: conn}
+	var p struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	if json.Unmarshal(params, &p) == nil {
+		info.progressToken = p.Meta.ProgressToken
+	}
+	return context.WithValue(ctx, requestKey{}, info)
+}
+
+func requestFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestKey{}).(*requestInfo)
+	return info
+}
+
+// SessionFrom returns the transport session of the request handled in ctx,
+// or nil if the transport does not track sessions.
+func SessionFrom(ctx context.Context) *transport.Session {
+	info := requestFrom(ctx)
+	if info == nil {
+		return nil
+	}
+	if sc, ok := info.conn.(transport.SessionConn); ok {
+		return sc.Session()
+	}
+	return nil
+}
+
+// Notify sends a notification on the stream of the request handled in ctx,
+// ahead of its response. Over HTTP this upgrades the response to an event
+// stream.
+func Notify(ctx context.Context, method string, params any) error {
+	info := requestFrom(ctx)
+	if info == nil {
+		return ErrNoRequest
+	}
+	data, err := json.Marshal(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return info.conn.Send(ctx, data)
+}
+
+// ProgressParams is the payload of a "notifications/progress" message.
+type ProgressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+// NotifyProgress reports progress on the request handled in ctx. It does
+// nothing unless the client asked for progress with a _meta.progressToken.
+// A zero total means the total is unknown.
+func NotifyProgress(ctx context.Context, progress, total float64, message string) error {
+	info := requestFrom(ctx)
+	if info == nil {
+		return ErrNoRequest
+	}
+	if len(info.progressToken) == 0 {
+		return nil
+	}
+	return Notify(ctx, "notifications/progress", ProgressParams{
+		ProgressToken: info.progressToken,
+		Progress:      progress,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+// Notify sends a server-initiated notification outside the scope of any
+// request, if the transport supports it.
+func (s *Server) Notify(ctx context.Context, method string, params any) error {
+	n, ok := s.tr.(transport.Notifier)
+	if !ok {
+		return errors.New("rpc: transport does not support server-initiated messages")
+	}
+	data, err := json.Marshal(rpcNotification{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return n.Notify(ctx, data)
+}
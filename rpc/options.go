@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"context"
+	"log"
+
+	"github.com/cyrusaf/mcp/auth"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// OutputValidation controls how the server reacts when a tool's structured
+// content does not conform to its declared output schema.
+type OutputValidation int
+
+const (
+	// OutputValidationOff sends structured content without checking it.
+	OutputValidationOff OutputValidation = iota
+	// OutputValidationLog logs mismatches but still sends the result.
+	OutputValidationLog
+	// OutputValidationFail replaces the result with an internal error.
+	OutputValidationFail
+)
+
+// WithOutputValidation sets the output schema validation mode. The default
+// is OutputValidationOff.
+func WithOutputValidation(mode OutputValidation) ServerOption {
+	return func(s *Server) { s.outputValidation = mode }
+}
+
+// WithLogger sets the logger used for diagnostics. The default is
+// log.Default().
+func WithLogger(l *log.Logger) ServerOption {
+	return func(s *Server) { s.logger = l }
+}
+
+// WithIdentity sets a function supplying the claims of callers on
+// connections that do not carry any, such as stdio, where the identity may
+// come from the environment. Without claims, callers can only use tools and
+// resources that require no scopes.
+func WithIdentity(fn func(ctx context.Context, conn transport.Conn) *auth.Claims) ServerOption {
+	return func(s *Server) { s.identity = fn }
+}
+
+// WithListChanged makes the server advertise and send
+// notifications/tools/list_changed, notifications/resources/list_changed
+// and notifications/prompts/list_changed whenever the registry changes
+// while Run is serving. The transport must support server-initiated
+// messages.
+func WithListChanged() ServerOption {
+	return func(s *Server) { s.listChanged = true }
+}
@@ -0,0 +1,50 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+type promptGetParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments"`
+}
+
+func (s *Server) handlePromptGet(ctx context.Context, conn transport.Conn, req rpcRequest) {
+	var p promptGetParams
+	if err := json.Unmarshal(req.Params, &p); err != nil || p.Name == "" {
+		s.sendError(ctx, conn, req.ID, ErrInvalidParams)
+		return
+	}
+	prompt := s.reg.FindPrompt(p.Name)
+	if prompt == nil {
+		s.sendError(ctx, conn, req.ID, ErrorInvalidParams(fmt.Errorf("unknown prompt %s", p.Name)))
+		return
+	}
+	if !allowed(ctx, prompt.RequiredScopes) {
+		s.sendError(ctx, conn, req.ID, ErrorForbidden(prompt.RequiredScopes))
+		return
+	}
+	for _, arg := range prompt.Arguments {
+		if _, ok := p.Arguments[arg.Name]; arg.Required && !ok {
+			s.sendError(ctx, conn, req.ID, ErrorInvalidParams(fmt.Errorf("missing argument %s", arg.Name)))
+			return
+		}
+	}
+	if p.Arguments == nil {
+		p.Arguments = map[string]string{}
+	}
+	res, err := prompt.Handler(ctx, p.Arguments)
+	if err != nil {
+		s.sendError(ctx, conn, req.ID, handlerError(err))
+		return
+	}
+	if res.Messages == nil {
+		res.Messages = []registry.PromptMessage{}
+	}
+	s.send(ctx, conn, req.ID, res)
+}
@@ -0,0 +1,28 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/cyrusaf/mcp/auth"
+)
+
+// allowed reports whether the caller of ctx was granted every one of
+// scopes.
+func allowed(ctx context.Context, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+	claims, ok := auth.ClaimsFrom(ctx)
+	return ok && claims.HasScopes(scopes...)
+}
+
+// visible returns the items the caller of ctx may use.
+func visible[T any](ctx context.Context, items []T, scopes func(T) []string) []T {
+	out := items[:0]
+	for _, item := range items {
+		if allowed(ctx, scopes(item)) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
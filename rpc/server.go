@@ -3,9 +3,14 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"reflect"
+	"sync"
 
+	"github.com/cyrusaf/mcp/auth"
 	"github.com/cyrusaf/mcp/registry"
 	"github.com/cyrusaf/mcp/transport"
 )
@@ -15,19 +20,84 @@ import (
 type Server struct {
 	reg *registry.Registry
 	tr  transport.Transport
+
+	logger           *log.Logger
+	outputValidation OutputValidation
+	identity         func(context.Context, transport.Conn) *auth.Claims
+	listChanged      bool
+
+	mu             sync.Mutex
+	active         int           // in-flight handlers
+	idle           chan struct{} // closed when active drops to zero
+	stop           chan struct{} // closed by Shutdown
+	shuttingDown   bool
+	cancelHandlers context.CancelFunc
+	inflight       map[string]context.CancelFunc // by inflightKey
 }
 
-func NewServer(reg *registry.Registry, tr transport.Transport) *Server {
-	return &Server{reg: reg, tr: tr}
+func NewServer(reg *registry.Registry, tr transport.Transport, opts ...ServerOption) *Server {
+	s := &Server{reg: reg, tr: tr, logger: log.Default(), stop: make(chan struct{})}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
+// Run serves messages from the transport until it fails, ctx is cancelled
+// or Shutdown is called, in which case ErrServerClosed is returned. When
+// the transport reaches the end of its input, as stdio does when the client
+// exits, Run waits for in-flight handlers and returns nil.
 func (s *Server) Run(ctx context.Context) error {
+	handlerCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	if s.shuttingDown {
+		s.mu.Unlock()
+		cancel()
+		return ErrServerClosed
+	}
+	s.cancelHandlers = cancel
+	s.mu.Unlock()
+
+	if s.listChanged {
+		defer s.reg.OnChange(func(kind registry.ChangeKind) {
+			_ = s.Notify(handlerCtx, "notifications/"+string(kind)+"/list_changed", nil)
+		})()
+	}
+
+	nextCtx, stopNext := context.WithCancel(ctx)
+	defer stopNext()
+	go func() {
+		select {
+		case <-s.stop:
+			stopNext()
+		case <-nextCtx.Done():
+		}
+	}()
+
 	for {
-		conn, raw, err := s.tr.Next(ctx)
+		conn, raw, err := s.tr.Next(nextCtx)
 		if err != nil {
+			select {
+			case <-s.stop:
+				// Shutdown waits for the handlers and cancels them
+				return ErrServerClosed
+			default:
+			}
+			if errors.Is(err, io.EOF) {
+				_ = s.waitIdle(ctx)
+				cancel()
+				return nil
+			}
+			cancel()
 			return err
 		}
-		go s.handle(ctx, conn, raw)
+		if !s.startHandler() {
+			continue
+		}
+		go func() {
+			defer s.finishHandler()
+			s.handle(handlerCtx, conn, raw)
+		}()
 	}
 }
 
@@ -37,27 +107,58 @@ func (s *Server) handle(ctx context.Context, conn transport.Conn, raw json.RawMe
 		s.sendError(ctx, conn, nil, ErrInvalidParams)
 		return
 	}
+	if req.Method == "notifications/cancelled" {
+		s.cancelRequest(conn, req.Params)
+		return
+	}
+	if req.Method == "" || len(req.ID) == 0 || string(req.ID) == "null" {
+		// notifications and responses to server-initiated requests
+		// never get a reply
+		return
+	}
+	ctx, done := s.track(ctx, conn, req.ID)
+	defer done()
+	ctx = withRequest(ctx, conn, req.Params)
+	if cc, ok := conn.(transport.ClaimsConn); ok && cc.Claims() != nil {
+		ctx = auth.WithClaims(ctx, cc.Claims())
+	} else if s.identity != nil {
+		if claims := s.identity(ctx, conn); claims != nil {
+			ctx = auth.WithClaims(ctx, claims)
+		}
+	}
 
 	switch req.Method {
 	case "initialize":
 		var res InitializeResult
-		res.ProtocolVersion = "2025-03-26"
+		res.ProtocolVersion = negotiateVersion(req.Params)
 		res.ServerInfo.Name = "cyrusaf/mcp"
 		res.ServerInfo.Version = "0.1.0"
 		// all capability flags default to false
+		res.Capabilities.Tools.ListChanged = s.listChanged
+		res.Capabilities.Resources.ListChanged = s.listChanged
+		res.Capabilities.Prompts.Offered = len(s.reg.Prompts()) > 0
+		res.Capabilities.Prompts.ListChanged = s.listChanged
 		s.send(ctx, conn, req.ID, res)
 	case "tools/list":
 		s.send(ctx, conn, req.ID, map[string]any{
-			"tools": s.reg.Tools(),
+			"tools": visible(ctx, s.reg.Tools(), func(t *registry.ToolDesc) []string { return t.RequiredScopes }),
 		})
 	case "resources/list":
 		s.send(ctx, conn, req.ID, map[string]any{
-			"resources": s.reg.Resources(),
+			"resources": visible(ctx, s.reg.Resources(), func(r *registry.ResourceDesc) []string { return r.RequiredScopes }),
 		})
 	case "resources/templates/list":
 		s.send(ctx, conn, req.ID, map[string]any{
-			"resourceTemplates": s.reg.ResourceTemplates(),
+			"resourceTemplates": visible(ctx, s.reg.ResourceTemplates(), func(r *registry.ResourceTemplateDesc) []string { return r.RequiredScopes }),
+		})
+	case "prompts/list":
+		s.send(ctx, conn, req.ID, map[string]any{
+			"prompts": visible(ctx, s.reg.Prompts(), func(p *registry.PromptDesc) []string { return p.RequiredScopes }),
 		})
+	case "prompts/get":
+		s.handlePromptGet(ctx, conn, req)
+	case "ping":
+		s.send(ctx, conn, req.ID, struct{}{})
 	case "tools/call":
 		s.handleToolCall(ctx, conn, req)
 	case "resources/read":
@@ -71,13 +172,14 @@ func (s *Server) send(ctx context.Context, conn transport.Conn, id json.RawMessa
 	resp := rpcResponse{JSONRPC: "2.0", ID: id, Result: result}
 	data, _ := json.Marshal(resp)
 	data = append(data, '\n')
-	_ = conn.Send(ctx, data)
+	// responses are still delivered when the handler was cancelled by Shutdown
+	_ = conn.Send(context.WithoutCancel(ctx), data)
 }
 
 func (s *Server) sendError(ctx context.Context, conn transport.Conn, id json.RawMessage, err *Error) {
 	resp := rpcResponse{JSONRPC: "2.0", ID: id, Error: err}
 	data, _ := json.Marshal(resp)
-	_ = conn.Send(ctx, data)
+	_ = conn.Send(context.WithoutCancel(ctx), data)
 }
 
 // tools/call params structure
@@ -103,28 +205,53 @@ func (s *Server) handleToolCall(ctx context.Context, conn transport.Conn, req rp
 		s.sendError(ctx, conn, req.ID, ErrorMethodNotFound(params.Name))
 		return
 	}
-	// decode arguments
-	arg := reflect.New(tool.Handler.Req()).Interface()
-	if len(params.Arguments) > 0 {
-		if err := json.Unmarshal(params.Arguments, &arg); err != nil {
-			s.sendError(ctx, conn, req.ID, ErrInvalidParams)
+	if !allowed(ctx, tool.RequiredScopes) {
+		s.sendError(ctx, conn, req.ID, ErrorForbidden(tool.RequiredScopes))
+		return
+	}
+	// validate and decode arguments
+	args := params.Arguments
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage(`{}`)
+	}
+	if tool.Raw != nil {
+		res, err := tool.Raw(ctx, args)
+		if err != nil {
+			s.sendError(ctx, conn, req.ID, handlerError(err))
 			return
 		}
+		s.send(ctx, conn, req.ID, res)
+		return
+	}
+	if err := tool.InputSchema.ValidateJSON(args); err != nil {
+		s.sendError(ctx, conn, req.ID, ErrorInvalidParams(err))
+		return
+	}
+	arg := reflect.New(tool.Handler.Req()).Interface()
+	if err := s.reg.Unmarshal(args, arg); err != nil {
+		s.sendError(ctx, conn, req.ID, ErrorInvalidParams(err))
+		return
 	}
 	val, err := tool.Handler.Call(ctx, reflect.ValueOf(arg).Elem().Interface())
 	if err != nil {
-		s.sendError(ctx, conn, req.ID, &Error{Code: -32000, Message: err.Error()})
+		s.sendError(ctx, conn, req.ID, handlerError(err))
 		return
 	}
 
 	var resp toolStructuredResp
 	if tool.OutputSchema != nil {
 		resp.StructuredContent = val
-		if b, err := json.Marshal(val); err == nil {
+		b, err := s.reg.Marshal(val)
+		if err == nil {
+			resp.StructuredContent = json.RawMessage(b)
 			resp.Content = []ContentItem{
 				NewTextContent(string(b)),
 			}
 		}
+		if err := s.validateOutput(tool, b, err); err != nil {
+			s.sendError(ctx, conn, req.ID, err)
+			return
+		}
 	} else {
 		resp.Content = []ContentItem{
 			{Type: "text", Data: map[string]any{"text": fmt.Sprint(val)}},
@@ -133,23 +260,58 @@ func (s *Server) handleToolCall(ctx context.Context, conn transport.Conn, req rp
 	s.send(ctx, conn, req.ID, resp)
 }
 
+// validateOutput checks the marshalled structured content b of tool against
+// its output schema according to the server's OutputValidation mode. It
+// returns a non-nil error only when the result must not be sent.
+func (s *Server) validateOutput(tool *registry.ToolDesc, b []byte, marshalErr error) *Error {
+	if s.outputValidation == OutputValidationOff {
+		return nil
+	}
+	err := marshalErr
+	if err == nil {
+		err = tool.OutputSchema.ValidateJSON(b)
+	}
+	if err == nil {
+		return nil
+	}
+	if s.outputValidation == OutputValidationLog {
+		s.logger.Printf("rpc: tool %s returned structured content not matching its output schema: %v", tool.Name, err)
+		return nil
+	}
+	return &Error{Code: -32603, Message: fmt.Sprintf("tool %s returned invalid structured content: %v", tool.Name, err)}
+}
+
 func (s *Server) handleResourceRead(ctx context.Context, conn transport.Conn, req rpcRequest) {
 	var p ResourceReadParams
 	if err := json.Unmarshal(req.Params, &p); err != nil || p.URI == "" {
 		s.sendError(ctx, conn, req.ID, ErrInvalidParams)
 		return
 	}
-	handler := s.reg.FindResource(p.URI)
-	if handler == nil {
+	// the handler and its scopes must come from the same lookup
+	match := s.reg.MatchResource(p.URI)
+	if match == nil || (match.Handler == nil && match.Raw == nil) {
 		s.sendError(ctx, conn, req.ID, ErrorMethodNotFound(p.URI))
 		return
 	}
-	val, err := handler.Read(ctx, p.URI)
+	if !allowed(ctx, match.RequiredScopes) {
+		s.sendError(ctx, conn, req.ID, ErrorForbidden(match.RequiredScopes))
+		return
+	}
+	if match.Raw != nil {
+		res, err := match.Raw(ctx, p.URI)
+		if err != nil {
+			s.sendError(ctx, conn, req.ID, handlerError(err))
+			return
+		}
+		s.send(ctx, conn, req.ID, res)
+		return
+	}
+	val, err := match.Handler.Read(ctx, p.URI)
 	if err != nil {
-		s.sendError(ctx, conn, req.ID, &Error{Code: -32000, Message: err.Error()})
+		s.sendError(ctx, conn, req.ID, handlerError(err))
 		return
 	}
-	valJSON, err := json.Marshal(val)
+	valJSON, err := s.reg.Marshal(val)
 	if err != nil {
 		s.sendError(ctx, conn, req.ID, &Error{Code: -32000, Message: err.Error()})
 		return
@@ -1,15 +1,20 @@
 package rpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
+	"log"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cyrusaf/mcp/auth"
 	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/schema"
 	"github.com/cyrusaf/mcp/transport"
 )
 
@@ -306,3 +311,549 @@ func TestInitialize(t *testing.T) {
 		t.Fatalf("unexpected capabilities: %+v", out.Capabilities)
 	}
 }
+
+func TestInitializeNegotiatesVersion(t *testing.T) {
+	_, tr, cancel := startTestServer(t)
+	defer cancel()
+
+	for i, tc := range []struct{ requested, want string }{
+		{"2024-11-05", "2024-11-05"},
+		{"2025-03-26", "2025-03-26"},
+		{"2099-01-01", LatestProtocolVersion},
+	} {
+		params, _ := json.Marshal(map[string]string{"protocolVersion": tc.requested})
+		req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage(strconv.Itoa(i)), Method: "initialize", Params: params}
+		data, _ := json.Marshal(req)
+		tr.in <- data
+
+		var resp struct {
+			Result InitializeResult `json:"result"`
+		}
+		if err := json.Unmarshal(<-tr.out, &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if resp.Result.ProtocolVersion != tc.want {
+			t.Fatalf("requested %s: got protocol version %s, want %s", tc.requested, resp.Result.ProtocolVersion, tc.want)
+		}
+	}
+}
+
+func TestToolsCallInvalidArguments(t *testing.T) {
+	_, tr, cancel := startTestServer(t)
+	defer cancel()
+
+	params := callParams{Name: "Echo", Arguments: json.RawMessage(`{"Msg":1,"Extra":true}`)}
+	pbytes, _ := json.Marshal(params)
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage(`9`), Method: "tools/call", Params: pbytes}
+	data, _ := json.Marshal(req)
+	tr.in <- data
+
+	respBytes := <-tr.out
+	var resp rpcResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrInvalidParams.Code {
+		t.Fatalf("expected invalid params error, got %+v", resp)
+	}
+	var out struct {
+		Errors []struct {
+			Path    string `json:"path"`
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if b, err := json.Marshal(resp.Error.Data); err == nil {
+		_ = json.Unmarshal(b, &out)
+	}
+	if len(out.Errors) != 2 || out.Errors[0].Path != "" || out.Errors[1].Path != "/Msg" {
+		t.Fatalf("unexpected error data: %+v", out.Errors)
+	}
+}
+
+func TestToolsCallEncodedArguments(t *testing.T) {
+	type scheduleIn struct {
+		At   time.Time       `json:"at"`
+		Data json.RawMessage `json:"data"`
+	}
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterTool(reg, "Schedule", func(ctx context.Context, in scheduleIn) (scheduleIn, error) {
+		return in, nil
+	})
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Schedule","arguments":{"at":"2024-05-06T07:08:09Z","data":{"k":[1,2]}}}}`)
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error != nil {
+		t.Fatalf("unexpected response %+v: %v", resp, err)
+	}
+	if b, _ := json.Marshal(resp.Result); !strings.Contains(string(b), `"at":"2024-05-06T07:08:09Z"`) {
+		t.Fatalf("unexpected result %s", b)
+	}
+}
+
+func TestToolsCallOutputValidation(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterTool(reg, "Broken", func(ctx context.Context, in struct{}) (*struct{ N int }, error) {
+		return nil, nil
+	})
+	srv := NewServer(reg, tr, WithOutputValidation(OutputValidationFail))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	params := callParams{Name: "Broken"}
+	pbytes, _ := json.Marshal(params)
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage(`10`), Method: "tools/call", Params: pbytes}
+	data, _ := json.Marshal(req)
+	tr.in <- data
+
+	respBytes := <-tr.out
+	var resp rpcResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != -32603 {
+		t.Fatalf("expected internal error, got %s", respBytes)
+	}
+}
+
+func TestToolsCallOutputValidationLog(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterTool(reg, "Broken", func(ctx context.Context, in struct{}) (*struct{ N int }, error) {
+		return nil, nil
+	})
+	var logs bytes.Buffer
+	srv := NewServer(reg, tr, WithOutputValidation(OutputValidationLog), WithLogger(log.New(&logs, "", 0)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":10,"method":"tools/call","params":{"name":"Broken"}}`)
+	respBytes := <-tr.out
+	var resp rpcResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Error != nil || resp.Result == nil {
+		t.Fatalf("expected the result to be sent, got %s", respBytes)
+	}
+	if !strings.Contains(logs.String(), "tool Broken returned structured content not matching its output schema") {
+		t.Fatalf("expected a log line, got %q", logs.String())
+	}
+}
+
+type testShape interface{ area() int }
+
+type testSquare struct {
+	Side int `json:"side"`
+}
+
+type testRect struct {
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (s testSquare) area() int { return s.Side * s.Side }
+func (r testRect) area() int   { return r.W * r.H }
+
+func TestToolsCallUnionArguments(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterUnion(reg, "kind", map[string]testShape{"square": testSquare{}, "rect": testRect{}})
+	registry.RegisterTool(reg, "Area", func(ctx context.Context, in struct {
+		Shape testShape `json:"shape"`
+	}) (struct{ Area int }, error) {
+		return struct{ Area int }{Area: in.Shape.area()}, nil
+	})
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	params := callParams{Name: "Area", Arguments: json.RawMessage(`{"shape":{"kind":"rect","w":2,"h":3}}`)}
+	pbytes, _ := json.Marshal(params)
+	req := rpcRequest{JSONRPC: "2.0", ID: json.RawMessage(`11`), Method: "tools/call", Params: pbytes}
+	data, _ := json.Marshal(req)
+	tr.in <- data
+
+	respBytes := <-tr.out
+	var resp rpcResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	b, _ := json.Marshal(resp.Result)
+	var out struct {
+		StructuredContent struct{ Area int } `json:"structuredContent"`
+	}
+	_ = json.Unmarshal(b, &out)
+	if out.StructuredContent.Area != 6 {
+		t.Fatalf("unexpected result: %s", b)
+	}
+}
+
+func TestToolsCallUnionResult(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterUnion(reg, "kind", map[string]testShape{"square": testSquare{}, "rect": testRect{}})
+	registry.RegisterTool(reg, "Grow", func(ctx context.Context, in struct {
+		Shape testShape `json:"shape"`
+	}) (struct {
+		Shapes []testShape `json:"shapes"`
+	}, error) {
+		return struct {
+			Shapes []testShape `json:"shapes"`
+		}{[]testShape{in.Shape, testSquare{Side: 1}}}, nil
+	})
+	srv := NewServer(reg, tr, WithOutputValidation(OutputValidationFail))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":12,"method":"tools/call","params":{"name":"Grow","arguments":{"shape":{"kind":"rect","w":2,"h":3}}}}`)
+	respBytes := <-tr.out
+	var resp rpcResponse
+	if err := json.Unmarshal(respBytes, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	b, _ := json.Marshal(resp.Result)
+	var out struct {
+		StructuredContent json.RawMessage `json:"structuredContent"`
+	}
+	_ = json.Unmarshal(b, &out)
+	if want := `{"shapes":[{"h":3,"kind":"rect","w":2},{"kind":"square","side":1}]}`; string(out.StructuredContent) != want {
+		t.Fatalf("unexpected structured content %s", out.StructuredContent)
+	}
+
+	// the result decodes back into the variants it was made from
+	var back struct {
+		Shapes []testShape `json:"shapes"`
+	}
+	if err := reg.Unmarshal(out.StructuredContent, &back); err != nil || back.Shapes[0] != (testRect{W: 2, H: 3}) {
+		t.Fatalf("round trip: %+v, %v", back, err)
+	}
+}
+
+func TestToolsCallProgress(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterTool(reg, "Slow", func(ctx context.Context, in struct{}) (struct{}, error) {
+		return struct{}{}, NotifyProgress(ctx, 1, 2, "halfway")
+	})
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":12,"method":"tools/call","params":{"name":"Slow","_meta":{"progressToken":"p1"}}}`)
+
+	var note rpcNotification
+	if err := json.Unmarshal(<-tr.out, &note); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if note.Method != "notifications/progress" {
+		t.Fatalf("unexpected notification: %+v", note)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if string(resp.ID) != "12" || resp.Error != nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestRunReturnsNilOnEOF(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterTool(reg, "Echo", func(ctx context.Context, in struct{ Msg string }) (struct{ Msg string }, error) {
+		return in, nil
+	})
+	srv := NewServer(reg, tr)
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Echo","arguments":{"Msg":"hi"}}}`)
+	close(tr.in)
+	if err := srv.Run(context.Background()); err != nil {
+		t.Fatalf("expected clean exit, got %v", err)
+	}
+	select {
+	case <-tr.out:
+	default:
+		t.Fatalf("response not sent before Run returned")
+	}
+}
+
+func TestShutdownWaitsForHandlers(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	started, release := make(chan struct{}), make(chan struct{})
+	registry.RegisterTool(reg, "Slow", func(ctx context.Context, in struct{}) (struct{}, error) {
+		close(started)
+		<-release
+		return struct{}{}, nil
+	})
+	srv := NewServer(reg, tr)
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(context.Background()) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Slow"}}`)
+	<-started
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(context.Background()) }()
+	if err := <-runErr; err != ErrServerClosed {
+		t.Fatalf("expected ErrServerClosed from Run, got %v", err)
+	}
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before handler finished: %v", err)
+	default:
+	}
+	close(release)
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error != nil {
+		t.Fatalf("unexpected response %+v: %v", resp, err)
+	}
+}
+
+func TestShutdownCancelsHandlersAtDeadline(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	started := make(chan struct{})
+	registry.RegisterTool(reg, "Stuck", func(ctx context.Context, in struct{}) (struct{}, error) {
+		close(started)
+		<-ctx.Done()
+		return struct{}{}, ctx.Err()
+	})
+	srv := NewServer(reg, tr)
+	go func() { _ = srv.Run(context.Background()) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Stuck"}}`)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error == nil {
+		t.Fatalf("expected cancelled handler to respond with an error, got %+v: %v", resp, err)
+	}
+	if err := srv.Run(context.Background()); err != ErrServerClosed {
+		t.Fatalf("expected Run after Shutdown to fail, got %v", err)
+	}
+}
+
+func TestShutdownStdio(t *testing.T) {
+	// stdin stays open and silent, as when the client is idle
+	stdin, _ := io.Pipe()
+	var stdout bytes.Buffer
+	srv := NewServer(registry.New(), transport.NewStdioTransport(stdin, &stdout))
+	runErr := make(chan error, 1)
+	go func() { runErr <- srv.Run(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	select {
+	case err := <-runErr:
+		if err != ErrServerClosed {
+			t.Fatalf("expected ErrServerClosed from Run, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run still blocked reading stdin after Shutdown")
+	}
+}
+
+func TestRequiredScopes(t *testing.T) {
+	newServer := func(opts ...ServerOption) *memTransport {
+		tr := newMemTransport()
+		reg := registry.New()
+		registry.RegisterTool(reg, "Echo", func(ctx context.Context, in struct{ Msg string }) (struct{ Msg string }, error) {
+			return in, nil
+		})
+		registry.RegisterTool(reg, "Admin", func(ctx context.Context, in struct{}) (struct{}, error) {
+			return struct{}{}, nil
+		}, registry.WithRequiredScopes("admin"))
+		registry.RegisterResource[struct{ ID int }](reg, "Secret", "secret://1", func(ctx context.Context, uri string) (struct{ ID int }, error) {
+			return struct{ ID int }{1}, nil
+		}, registry.WithResourceRequiredScopes("admin"))
+		registry.RegisterPrompt(reg, "Audit", func(ctx context.Context, args map[string]string) (*registry.PromptResult, error) {
+			return &registry.PromptResult{}, nil
+		}, registry.WithPromptRequiredScopes("admin"))
+		srv := NewServer(reg, tr, opts...)
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = srv.Run(ctx) }()
+		return tr
+	}
+	call := func(tr *memTransport, req string) rpcResponse {
+		t.Helper()
+		tr.in <- json.RawMessage(req)
+		var resp rpcResponse
+		if err := json.Unmarshal(<-tr.out, &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return resp
+	}
+	listed := func(resp rpcResponse, key string) int {
+		t.Helper()
+		data, _ := json.Marshal(resp.Result)
+		var res map[string][]json.RawMessage
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return len(res[key])
+	}
+
+	anon := newServer()
+	if n := listed(call(anon, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`), "tools"); n != 1 {
+		t.Fatalf("expected only the unscoped tool to be listed, got %d", n)
+	}
+	if n := listed(call(anon, `{"jsonrpc":"2.0","id":2,"method":"resources/list"}`), "resources"); n != 0 {
+		t.Fatalf("expected scoped resource to be hidden, got %d", n)
+	}
+	if resp := call(anon, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"Admin"}}`); resp.Error == nil || resp.Error.Code != -32003 {
+		t.Fatalf("expected forbidden error, got %+v", resp)
+	}
+	if resp := call(anon, `{"jsonrpc":"2.0","id":4,"method":"resources/read","params":{"uri":"secret://1"}}`); resp.Error == nil || resp.Error.Code != -32003 {
+		t.Fatalf("expected forbidden error, got %+v", resp)
+	}
+	if n := listed(call(anon, `{"jsonrpc":"2.0","id":5,"method":"prompts/list"}`), "prompts"); n != 0 {
+		t.Fatalf("expected scoped prompt to be hidden, got %d", n)
+	}
+	if resp := call(anon, `{"jsonrpc":"2.0","id":6,"method":"prompts/get","params":{"name":"Audit"}}`); resp.Error == nil || resp.Error.Code != -32003 {
+		t.Fatalf("expected forbidden error, got %+v", resp)
+	}
+
+	admin := newServer(WithIdentity(func(context.Context, transport.Conn) *auth.Claims {
+		return &auth.Claims{Subject: "root", Scopes: []string{"admin"}}
+	}))
+	if n := listed(call(admin, `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`), "tools"); n != 2 {
+		t.Fatalf("expected both tools to be listed, got %d", n)
+	}
+	if resp := call(admin, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"Admin"}}`); resp.Error != nil {
+		t.Fatalf("unexpected error %+v", resp.Error)
+	}
+	if resp := call(admin, `{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"secret://1"}}`); resp.Error != nil {
+		t.Fatalf("unexpected error %+v", resp.Error)
+	}
+	if n := listed(call(admin, `{"jsonrpc":"2.0","id":4,"method":"prompts/list"}`), "prompts"); n != 1 {
+		t.Fatalf("expected the prompt to be listed, got %d", n)
+	}
+	if resp := call(admin, `{"jsonrpc":"2.0","id":5,"method":"prompts/get","params":{"name":"Audit"}}`); resp.Error != nil {
+		t.Fatalf("unexpected error %+v", resp.Error)
+	}
+}
+
+func TestPrompts(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterPrompt(reg, "Greet", func(ctx context.Context, args map[string]string) (*registry.PromptResult, error) {
+		return &registry.PromptResult{Messages: []registry.PromptMessage{{Role: "user", Content: NewTextContent("Hello, " + args["name"])}}}, nil
+	}, registry.WithPromptArgument("name", "who to greet", true))
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"prompts/list"}`)
+	if resp := string(<-tr.out); !strings.Contains(resp, `"name":"Greet"`) || !strings.Contains(resp, `"required":true`) {
+		t.Fatalf("unexpected list: %s", resp)
+	}
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"prompts/get","params":{"name":"Greet","arguments":{"name":"Ann"}}}`)
+	if resp := string(<-tr.out); !strings.Contains(resp, `"text":"Hello, Ann"`) {
+		t.Fatalf("unexpected prompt: %s", resp)
+	}
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":3,"method":"prompts/get","params":{"name":"Greet"}}`)
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error == nil || resp.Error.Code != ErrInvalidParams.Code {
+		t.Fatalf("expected invalid params for a missing argument, got %+v: %v", resp, err)
+	}
+}
+
+func TestCancelledRequest(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	started := make(chan struct{})
+	registry.RegisterTool(reg, "Stuck", func(ctx context.Context, in struct{}) (struct{}, error) {
+		close(started)
+		<-ctx.Done()
+		return struct{}{}, ctx.Err()
+	})
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":7,"method":"tools/call","params":{"name":"Stuck"}}`)
+	<-started
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":7}}`)
+	select {
+	case <-tr.out:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not cancelled")
+	}
+	tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+	var resp rpcResponse
+	if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error != nil || string(resp.ID) != "8" {
+		t.Fatalf("unexpected ping response %+v: %v", resp, err)
+	}
+}
+
+func TestOverlappingTemplateScopes(t *testing.T) {
+	tr := newMemTransport()
+	reg := registry.New()
+	registry.RegisterResourceTemplate(reg, "User", "users://{id}", func(ctx context.Context, uri string) (string, error) {
+		return "user", nil
+	})
+	registry.RegisterResourceTemplate(reg, "Admin", "users://admin/{id}", func(ctx context.Context, uri string) (string, error) {
+		return "admin", nil
+	}, registry.WithTemplateRequiredScopes("admin"))
+	srv := NewServer(reg, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = srv.Run(ctx) }()
+
+	// map order is random, so repeat to catch a lookup mixing entries
+	for i := 0; i < 200; i++ {
+		tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"resources/read","params":{"uri":"users://admin/1"}}`)
+		var resp rpcResponse
+		if err := json.Unmarshal(<-tr.out, &resp); err != nil || resp.Error == nil || resp.Error.Code != -32003 {
+			t.Fatalf("expected the admin template to be forbidden, got %+v: %v", resp, err)
+		}
+		tr.in <- json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"users://7"}}`)
+		if resp := string(<-tr.out); !strings.Contains(resp, `\"user\"`) {
+			t.Fatalf("expected the user template, got %s", resp)
+		}
+	}
+}
+
+func TestToolsOutputSchemaNotMutated(t *testing.T) {
+	shared := &schema.Schema{Type: "object", Nullable: true}
+	reg := registry.New()
+	registry.RegisterTool(reg, "Echo", func(ctx context.Context, in struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	}, registry.WithOutputSchema(shared))
+	tools := reg.Tools()
+	if len(tools) != 1 || tools[0].OutputSchema.Nullable {
+		t.Fatalf("expected a non-nullable output schema, got %+v", tools)
+	}
+	if !shared.Nullable {
+		t.Fatalf("RegisterTool changed the caller's schema")
+	}
+}
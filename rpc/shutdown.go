@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// ErrServerClosed is returned by Run once Shutdown has been called.
+var ErrServerClosed = errors.New("rpc: server closed")
+
+// shutdownGrace is how long Shutdown waits for cancelled handlers to send
+// their final responses before closing the transport.
+const shutdownGrace = time.Second
+
+// Shutdown gracefully stops the server: it stops accepting new messages,
+// waits for in-flight handlers to finish and then closes the transport,
+// flushing pending responses where the transport supports it. If ctx
+// expires first, the remaining handlers are cancelled and given a short
+// grace period to respond, and ctx's error is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.shuttingDown {
+		s.shuttingDown = true
+		close(s.stop)
+	}
+	cancel := s.cancelHandlers
+	s.mu.Unlock()
+
+	err := s.waitIdle(ctx)
+	if err != nil && cancel != nil {
+		cancel()
+		grace, done := context.WithTimeout(context.Background(), shutdownGrace)
+		_ = s.waitIdle(grace)
+		done()
+	}
+
+	var closeErr error
+	if sd, ok := s.tr.(transport.Shutdowner); ok {
+		closeErr = sd.Shutdown(ctx)
+	} else {
+		closeErr = s.tr.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// startHandler records a new in-flight handler. It reports false once the
+// server is shutting down.
+func (s *Server) startHandler() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown {
+		return false
+	}
+	s.active++
+	return true
+}
+
+func (s *Server) finishHandler() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.active == 0 && s.idle != nil {
+		close(s.idle)
+		s.idle = nil
+	}
+}
+
+// waitIdle waits until no handler is running or ctx is done.
+func (s *Server) waitIdle(ctx context.Context) error {
+	s.mu.Lock()
+	if s.active == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	if s.idle == nil {
+		s.idle = make(chan struct{})
+	}
+	idle := s.idle
+	s.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,15 +1,221 @@
 package schema
 
-import "reflect"
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Draft202012 is the meta-schema URI of JSON Schema draft 2020-12.
+const Draft202012 = "https://json-schema.org/draft/2020-12/schema"
 
 type Schema struct {
-	Type       string             `json:"type,omitempty"`
-	Properties map[string]*Schema `json:"properties,omitempty"`
-	Items      *Schema            `json:"items,omitempty"`
+	Dialect              string             `json:"$schema,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Const                any                `json:"const,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	// Nullable permits null in addition to Type. It is encoded as a type
+	// union, e.g. "type": ["string", "null"].
+	Nullable bool `json:"-"`
+	// Raw, when set, is the original document, which is marshalled in
+	// place of the fields above. See FromJSON.
+	Raw json.RawMessage `json:"-"`
+}
+
+// FromJSON parses a schema document, keeping it verbatim for marshalling
+// so that keywords Schema does not model, such as descriptions, survive.
+func FromJSON(b []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	s.Raw = append(json.RawMessage(nil), b...)
+	return &s, nil
+}
+
+type schemaJSON Schema
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	if len(s.Raw) > 0 {
+		return s.Raw, nil
+	}
+	out := struct {
+		Type any `json:"type,omitempty"`
+		schemaJSON
+	}{schemaJSON: schemaJSON(s)}
+	if s.Type != "" {
+		out.Type = s.Type
+		if s.Nullable {
+			out.Type = []string{s.Type, "null"}
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var in struct {
+		Type json.RawMessage `json:"type,omitempty"`
+		*schemaJSON
+	}
+	in.schemaJSON = (*schemaJSON)(s)
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	s.Type, s.Nullable = "", false
+	if len(in.Type) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(in.Type, &s.Type); err == nil {
+		return nil
+	}
+	var types []string
+	if err := json.Unmarshal(in.Type, &types); err != nil {
+		return err
+	}
+	for _, t := range types {
+		if t == "null" {
+			s.Nullable = true
+		} else {
+			s.Type = t
+		}
+	}
+	return nil
+}
+
+// Provider is implemented by types that describe their own JSON encoding,
+// typically alongside a custom MarshalJSON method. The returned schema is
+// used verbatim in place of the reflected one.
+type Provider interface {
+	JSONSchema() *Schema
+}
+
+var providerType = reflect.TypeOf((*Provider)(nil)).Elem()
+
+// Reflector generates schemas from Go types. Overrides take precedence over
+// Provider implementations, which take precedence over reflection. The zero
+// value is ready to use.
+type Reflector struct {
+	// Dialect, when set, is stamped as "$schema" on every root schema,
+	// e.g. Draft202012.
+	Dialect string
+	// AllowAdditionalProperties lets structs accept properties they do not
+	// declare. By default they are rejected with additionalProperties:
+	// false. Individual structs can override this with a blank field,
+	//
+	//	_ struct{} `jsonschema:"additionalProperties=true"`
+	//
+	// or with SetAdditionalProperties.
+	AllowAdditionalProperties bool
+	// Strict generates schemas accepted by providers' strict function
+	// calling modes: every property is required, optional ones are made
+	// nullable through type unions, and additional properties are always
+	// rejected.
+	Strict bool
+
+	mu         sync.RWMutex
+	overrides  map[reflect.Type]*Schema
+	unions     map[reflect.Type]*Union
+	additional map[reflect.Type]bool
+}
+
+// SetAdditionalProperties controls whether the struct type t accepts
+// properties it does not declare, taking precedence over struct tags and
+// AllowAdditionalProperties. It has no effect in Strict mode.
+func (r *Reflector) SetAdditionalProperties(t reflect.Type, allow bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.additional == nil {
+		r.additional = make(map[reflect.Type]bool)
+	}
+	r.additional[t] = allow
+}
+
+// additionalProperties returns the additionalProperties value for struct
+// type t, whose tags have already been applied to s.
+func (r *Reflector) additionalProperties(t reflect.Type, s *Schema) *bool {
+	allow := r.AllowAdditionalProperties
+	if s.AdditionalProperties != nil {
+		allow = *s.AdditionalProperties
+	}
+	r.mu.RLock()
+	if v, ok := r.additional[t]; ok {
+		allow = v
+	}
+	r.mu.RUnlock()
+	if r.Strict {
+		allow = false
+	}
+	if allow {
+		return nil
+	}
+	return new(bool)
+}
+
+// Override makes r use s for every occurrence of t, including nested fields
+// and slice elements.
+func (r *Reflector) Override(t reflect.Type, s *Schema) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.overrides == nil {
+		r.overrides = make(map[reflect.Type]*Schema)
+	}
+	r.overrides[t] = s
+}
+
+func (r *Reflector) override(t reflect.Type) *Schema {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.overrides[t]
+}
+
+// ReflectFromType returns the schema for values of type t.
+func (r *Reflector) ReflectFromType(t reflect.Type) *Schema {
+	s := r.reflectType(t)
+	if r.Dialect != "" {
+		s.Dialect = r.Dialect
+	}
+	return s
 }
 
-func ReflectFromType(t reflect.Type) *Schema {
+func (r *Reflector) reflectType(t reflect.Type) *Schema {
+	if s := r.override(t); s != nil {
+		cp := *s
+		return &cp
+	}
+	if s := provided(t); s != nil {
+		cp := *s
+		return &cp
+	}
+	if s := encoded(t); s != nil {
+		return s
+	}
 	switch t.Kind() {
+	case reflect.Pointer:
+		s := r.reflectType(t.Elem())
+		s.Nullable = s.Type != ""
+		return s
+	case reflect.Interface:
+		if u := r.union(t); u != nil {
+			return r.reflectUnion(u)
+		}
+		// any value is acceptable
+		return &Schema{}
 	case reflect.String:
 		return &Schema{Type: "string"}
 	case reflect.Bool:
@@ -20,20 +226,129 @@ func ReflectFromType(t reflect.Type) *Schema {
 	case reflect.Float32, reflect.Float64:
 		return &Schema{Type: "number"}
 	case reflect.Slice, reflect.Array:
-		return &Schema{Type: "array", Items: ReflectFromType(t.Elem())}
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json marshals []byte as a base64 string
+			return &Schema{Type: "string"}
+		}
+		return &Schema{Type: "array", Items: r.reflectType(t.Elem())}
 	case reflect.Struct:
-		props := make(map[string]*Schema)
-		for i := 0; i < t.NumField(); i++ {
-			f := t.Field(i)
-			if f.PkgPath != "" { // unexported
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		r.reflectFields(s, t)
+		s.AdditionalProperties = r.additionalProperties(t, s)
+		return s
+	default:
+		return &Schema{Type: "object"}
+	}
+}
+
+// provided returns the schema declared by t's Provider implementation, if
+// t or *t has one.
+func provided(t reflect.Type) *Schema {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		// handled once the pointer has been dereferenced
+		return nil
+	case t.Implements(providerType):
+		if p, ok := reflect.Zero(t).Interface().(Provider); ok {
+			return p.JSONSchema()
+		}
+	case reflect.PointerTo(t).Implements(providerType):
+		return reflect.New(t).Interface().(Provider).JSONSchema()
+	}
+	return nil
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// encoded returns the schema for types that encode themselves, whose JSON
+// form cannot be derived from their fields: any value for JSON marshalers
+// such as json.RawMessage, and a string for text marshalers and time.Time.
+func encoded(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer || t.Kind() == reflect.Interface {
+		return nil
+	}
+	implements := func(iface reflect.Type) bool {
+		return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string"}
+	case implements(jsonMarshalerType) || implements(jsonUnmarshalerType):
+		return &Schema{}
+	case implements(textMarshalerType) || implements(textUnmarshalerType):
+		return &Schema{Type: "string"}
+	}
+	return nil
+}
+
+// reflectFields adds the exported fields of struct type t to s using the
+// names encoding/json would use. Embedded structs without a JSON name are
+// flattened into s, as encoding/json does.
+func (r *Reflector) reflectFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name == "_" {
+			applyTag(s, f.Tag.Get("jsonschema"))
+			continue
+		}
+		name, omitempty, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && r.override(ft) == nil && provided(ft) == nil {
+				r.reflectFields(s, ft)
 				continue
 			}
-			props[f.Name] = ReflectFromType(f.Type)
 		}
-		return &Schema{Type: "object", Properties: props}
-	default:
-		return &Schema{Type: "object"}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := r.reflectType(f.Type)
+		applyTag(prop, f.Tag.Get("jsonschema"))
+		s.Properties[name] = prop
+		optional := omitempty || f.Type.Kind() == reflect.Pointer
+		if r.Strict && optional {
+			prop.Nullable = prop.Type != ""
+		}
+		if r.Strict || !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// jsonName parses the json struct tag of f.
+func jsonName(f reflect.StructField) (name string, omitempty, skip bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" || opt == "omitzero" {
+			omitempty = true
+		}
 	}
+	return name, omitempty, false
 }
 
+var defaultReflector Reflector
+
+// ReflectFromType returns the schema for values of type t using a Reflector
+// without overrides.
+func ReflectFromType(t reflect.Type) *Schema { return defaultReflector.ReflectFromType(t) }
+
 func Reflect(v any) *Schema { return ReflectFromType(reflect.TypeOf(v)) }
@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type celsius float64
+
+func (celsius) MarshalJSON() ([]byte, error) { return []byte(`"0C"`), nil }
+
+func (celsius) JSONSchema() *Schema { return &Schema{Type: "string", Pattern: "^-?[0-9]+C$"} }
+
+type point struct{ X, Y int }
+
+type reading struct {
+	Temp    celsius   `json:"temp"`
+	History []celsius `json:"history"`
+	Max     *celsius  `json:"max"`
+	At      point     `json:"at"`
+}
+
+func TestReflectProvider(t *testing.T) {
+	s := Reflect(reading{})
+	if got := s.Properties["temp"]; got.Type != "string" || got.Pattern == "" {
+		t.Fatalf("unexpected temp schema: %+v", got)
+	}
+	if got := s.Properties["history"].Items; got.Type != "string" {
+		t.Fatalf("unexpected history item schema: %+v", got)
+	}
+	if got := s.Properties["max"]; got.Type != "string" || !got.Nullable {
+		t.Fatalf("unexpected max schema: %+v", got)
+	}
+}
+
+func TestReflectorOverride(t *testing.T) {
+	var r Reflector
+	r.Override(reflect.TypeOf(point{}), &Schema{Type: "string"})
+	s := r.ReflectFromType(reflect.TypeOf([]reading{}))
+	if got := s.Items.Properties["at"]; got.Type != "string" {
+		t.Fatalf("unexpected at schema: %+v", got)
+	}
+	// the default reflector is unaffected
+	if got := Reflect(reading{}).Properties["at"]; got.Type != "object" {
+		t.Fatalf("unexpected at schema: %+v", got)
+	}
+}
+
+type strictReq struct {
+	_     struct{} `jsonschema:"additionalProperties=true"`
+	Name  string   `json:"name"`
+	Note  string   `json:"note,omitempty"`
+	Count *int     `json:"count"`
+}
+
+func TestReflectorModes(t *testing.T) {
+	s := Reflect(strictReq{})
+	if s.AdditionalProperties != nil {
+		t.Fatalf("expected tag to allow additional properties: %+v", s)
+	}
+	if len(s.Required) != 1 || s.Required[0] != "name" {
+		t.Fatalf("unexpected required: %v", s.Required)
+	}
+
+	r := Reflector{Dialect: Draft202012, Strict: true}
+	s = r.ReflectFromType(reflect.TypeOf(strictReq{}))
+	if s.Dialect != Draft202012 {
+		t.Fatalf("missing dialect: %+v", s)
+	}
+	if s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Fatalf("strict schemas must reject additional properties: %+v", s)
+	}
+	if len(s.Required) != 3 {
+		t.Fatalf("unexpected required: %v", s.Required)
+	}
+	if !s.Properties["note"].Nullable || !s.Properties["count"].Nullable || s.Properties["name"].Nullable {
+		t.Fatalf("unexpected nullability: %+v", s.Properties)
+	}
+	b, _ := json.Marshal(s)
+	if !strings.Contains(string(b), `"$schema":"https://json-schema.org/draft/2020-12/schema"`) ||
+		!strings.Contains(string(b), `"type":["string","null"]`) {
+		t.Fatalf("unexpected encoding: %s", b)
+	}
+}
+
+type event struct {
+	At      time.Time       `json:"at"`
+	Until   *time.Time      `json:"until"`
+	Payload json.RawMessage `json:"payload"`
+	Host    netip.Addr      `json:"host"`
+	Port    string          `json:"port" jsonschema:"minLength=1,pattern=^\\d{1,5}$"`
+}
+
+func TestReflectEncodedTypes(t *testing.T) {
+	s := Reflect(event{})
+	if got := s.Properties["at"]; got.Type != "string" {
+		t.Fatalf("unexpected at schema: %+v", got)
+	}
+	if got := s.Properties["until"]; got.Type != "string" || !got.Nullable {
+		t.Fatalf("unexpected until schema: %+v", got)
+	}
+	if got := s.Properties["payload"]; got.Type != "" {
+		t.Fatalf("expected payload to accept any value: %+v", got)
+	}
+	if got := s.Properties["host"]; got.Type != "string" {
+		t.Fatalf("unexpected host schema: %+v", got)
+	}
+	if got := s.Properties["port"]; got.Pattern != `^\d{1,5}$` || got.MinLength == nil {
+		t.Fatalf("unexpected port schema: %+v", got)
+	}
+
+	in, err := json.Marshal(event{At: time.Now(), Payload: json.RawMessage(`{"a":[1]}`), Host: netip.MustParseAddr("::1"), Port: "8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateJSON(in); err != nil {
+		t.Fatalf("marshalled event does not validate: %v", err)
+	}
+	if err := s.ValidateJSON([]byte(`{"at":"x","until":null,"payload":1,"host":"h","port":"123456"}`)); err == nil || !strings.Contains(err.Error(), "/port") {
+		t.Fatalf("expected a port pattern error, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"strconv"
+	"strings"
+)
+
+// applyTag applies the constraints in a `jsonschema` struct tag to s.
+//
+// The tag is a comma separated list of key=value pairs, for example
+//
+//	Age  int    `jsonschema:"minimum=0,maximum=150"`
+//	Kind string `jsonschema:"enum=user|group"`
+//
+// A pattern takes the rest of the tag, commas included, so it must come
+// last:
+//
+//	Zip string `jsonschema:"minLength=5,pattern=^\\d{5}(-\\d{1,4})?$"`
+//
+// A blank field carries options for the enclosing struct:
+//
+//	_ struct{} `jsonschema:"additionalProperties=true"`
+//
+// Unknown keys and malformed values are ignored.
+func applyTag(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+	for tag != "" {
+		var part string
+		if strings.HasPrefix(tag, "pattern=") {
+			part, tag = tag, ""
+		} else {
+			part, tag, _ = strings.Cut(tag, ",")
+		}
+		key, val, _ := strings.Cut(part, "=")
+		switch key {
+		case "minimum":
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				s.Minimum = &f
+			}
+		case "maximum":
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				s.Maximum = &f
+			}
+		case "minLength":
+			if n, err := strconv.Atoi(val); err == nil {
+				s.MinLength = &n
+			}
+		case "maxLength":
+			if n, err := strconv.Atoi(val); err == nil {
+				s.MaxLength = &n
+			}
+		case "minItems":
+			if n, err := strconv.Atoi(val); err == nil {
+				s.MinItems = &n
+			}
+		case "maxItems":
+			if n, err := strconv.Atoi(val); err == nil {
+				s.MaxItems = &n
+			}
+		case "additionalProperties":
+			if b, err := strconv.ParseBool(val); err == nil {
+				s.AdditionalProperties = &b
+			}
+		case "pattern":
+			s.Pattern = val
+		case "enum":
+			for _, v := range strings.Split(val, "|") {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		}
+	}
+}
+
+// enumValue converts a tag enum entry to the JSON type described by typ.
+func enumValue(typ, v string) any {
+	switch typ {
+	case "integer", "number":
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
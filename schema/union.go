@@ -0,0 +1,476 @@
+package schema
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Union describes the concrete variants of an interface type. Values are
+// encoded as JSON objects whose Discriminator property names the variant.
+type Union struct {
+	Discriminator string
+	// Variants maps discriminator values to concrete types implementing
+	// the interface. Pointer types are allowed.
+	Variants map[string]reflect.Type
+	// AnyOf emits "anyOf" instead of "oneOf" for clients that do not
+	// understand the latter.
+	AnyOf bool
+}
+
+// RegisterUnion makes r describe iface as a union of u's variants and decode
+// it into the variant named by the discriminator.
+func (r *Reflector) RegisterUnion(iface reflect.Type, u Union) error {
+	if iface.Kind() != reflect.Interface {
+		return fmt.Errorf("schema: %s is not an interface type", iface)
+	}
+	if u.Discriminator == "" {
+		return fmt.Errorf("schema: union %s has no discriminator", iface)
+	}
+	for name, vt := range u.Variants {
+		if vt == nil || !vt.Implements(iface) {
+			return fmt.Errorf("schema: variant %q (%v) does not implement %s", name, vt, iface)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unions == nil {
+		r.unions = make(map[reflect.Type]*Union)
+	}
+	r.unions[iface] = &u
+	return nil
+}
+
+func (r *Reflector) union(t reflect.Type) *Union {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.unions[t]
+}
+
+// reflectUnion returns a oneOf (or anyOf) schema with one entry per variant,
+// each constraining the discriminator to the variant's name.
+func (r *Reflector) reflectUnion(u *Union) *Schema {
+	var variants []*Schema
+	for _, name := range u.names() {
+		vs := r.reflectType(u.Variants[name])
+		vs.Nullable = false
+		props := make(map[string]*Schema, len(vs.Properties)+1)
+		for k, v := range vs.Properties {
+			props[k] = v
+		}
+		props[u.Discriminator] = &Schema{Type: "string", Const: name}
+		vs.Properties = props
+		if !contains(vs.Required, u.Discriminator) {
+			vs.Required = append(append([]string{}, vs.Required...), u.Discriminator)
+		}
+		variants = append(variants, vs)
+	}
+	if u.AnyOf {
+		return &Schema{AnyOf: variants}
+	}
+	return &Schema{OneOf: variants}
+}
+
+func (u *Union) names() []string {
+	names := make([]string, 0, len(u.Variants))
+	for name := range u.Variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Unmarshal decodes the JSON document data into v like json.Unmarshal, but
+// decodes registered union interfaces into the variant selected by their
+// discriminator.
+func (r *Reflector) Unmarshal(data []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("schema: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+	return r.decode(data, rv.Elem())
+}
+
+func (r *Reflector) decode(data []byte, v reflect.Value) error {
+	t := v.Type()
+	if !r.hasUnion(t, make(map[reflect.Type]bool)) {
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+	if isNull(data) {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(t))
+		}
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		u := r.union(t)
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(data, &probe); err != nil {
+			return err
+		}
+		var name string
+		if err := json.Unmarshal(probe[u.Discriminator], &name); err != nil || name == "" {
+			return fmt.Errorf("missing %q discriminator for %s", u.Discriminator, t)
+		}
+		vt, ok := u.Variants[name]
+		if !ok {
+			return fmt.Errorf("unknown %s variant %q", t, name)
+		}
+		nv := reflect.New(vt).Elem()
+		if err := r.decode(data, nv); err != nil {
+			return err
+		}
+		v.Set(nv)
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		return r.decode(data, v.Elem())
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if err := r.decode(item, s.Index(i)); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Array:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		for i := 0; i < v.Len() && i < len(items); i++ {
+			if err := r.decode(items[i], v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		m := reflect.MakeMapWithSize(t, len(items))
+		for k, item := range items {
+			kv, err := mapKey(k, t.Key())
+			if err != nil {
+				return err
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := r.decode(item, ev); err != nil {
+				return err
+			}
+			m.SetMapIndex(kv, ev)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		return r.decodeFields(fields, v)
+	default:
+		return json.Unmarshal(data, v.Addr().Interface())
+	}
+	return nil
+}
+
+// mapKey converts the object key k to a map key of type t the way
+// encoding/json does.
+func mapKey(k string, t reflect.Type) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		kv := reflect.New(t)
+		if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(k)); err != nil {
+			return reflect.Value{}, err
+		}
+		return kv.Elem(), nil
+	}
+	kv := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		kv.SetString(k)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(k, 10, 64)
+		if err != nil || kv.OverflowInt(n) {
+			return reflect.Value{}, fmt.Errorf("invalid %s map key %q", t, k)
+		}
+		kv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(k, 10, 64)
+		if err != nil || kv.OverflowUint(n) {
+			return reflect.Value{}, fmt.Errorf("invalid %s map key %q", t, k)
+		}
+		kv.SetUint(n)
+	default:
+		return reflect.Value{}, fmt.Errorf("unsupported map key type %s", t)
+	}
+	return kv, nil
+}
+
+// keyName returns the object key encoding/json writes for the map key k.
+func keyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		b, err := tm.MarshalText()
+		return string(b), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", k.Type())
+}
+
+// Marshal encodes v like json.Marshal, but writes the discriminator of
+// registered union interfaces into their objects, as the reflected schemas
+// require. Objects of types containing unions have their keys sorted.
+func (r *Reflector) Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || v == nil {
+		return data, err
+	}
+	return r.encode(data, reflect.ValueOf(v))
+}
+
+// encode adds the discriminators of the unions in v to data, the JSON
+// encoding of v.
+func (r *Reflector) encode(data []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	if isNull(data) || !r.hasUnion(t, make(map[reflect.Type]bool)) {
+		return data, nil
+	}
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return data, nil
+		}
+		elem := v.Elem()
+		data, err := r.encode(data, elem)
+		if err != nil {
+			return nil, err
+		}
+		u := r.union(t)
+		if u == nil {
+			return data, nil
+		}
+		for _, name := range u.names() {
+			if u.Variants[name] == elem.Type() {
+				return setMember(data, u.Discriminator, name)
+			}
+		}
+		return nil, fmt.Errorf("schema: %s is not a registered variant of %s", elem.Type(), t)
+	case reflect.Pointer:
+		return r.encode(data, v.Elem())
+	case reflect.Slice, reflect.Array:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for i := range items {
+			if i >= v.Len() {
+				break
+			}
+			item, err := r.encode(items[i], v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return json.Marshal(items)
+	case reflect.Map:
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		for iter := v.MapRange(); iter.Next(); {
+			k, err := keyName(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			item, ok := items[k]
+			if !ok {
+				continue
+			}
+			enc, err := r.encode(item, iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			items[k] = enc
+		}
+		return json.Marshal(items)
+	case reflect.Struct:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if err := r.encodeFields(fields, v); err != nil {
+			return nil, err
+		}
+		return json.Marshal(fields)
+	}
+	return data, nil
+}
+
+// encodeFields adds discriminators to the members of the encoded struct v,
+// matching names the way encoding/json does.
+func (r *Reflector) encodeFields(fields map[string]json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := r.encodeFields(fields, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		raw, ok := fields[name]
+		if !ok {
+			continue
+		}
+		enc, err := r.encode(raw, v.Field(i))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fields[name] = enc
+	}
+	return nil
+}
+
+// setMember sets the string member key of the JSON object data.
+func setMember(data []byte, key, value string) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(value)
+	obj[key] = b
+	return json.Marshal(obj)
+}
+
+// decodeFields decodes the members of a JSON object into the fields of the
+// struct v, matching names the way encoding/json does.
+func (r *Reflector) decodeFields(fields map[string]json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, skip := jsonName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			fv := v.Field(i)
+			if f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(f.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := r.decodeFields(fields, fv); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		raw, ok := fields[name]
+		if !ok {
+			for k, val := range fields {
+				if strings.EqualFold(k, name) {
+					raw, ok = val, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := r.decode(raw, v.Field(i)); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// hasUnion reports whether values of type t can contain a registered union,
+// in which case they cannot be handed to encoding/json directly. visiting
+// holds the types currently being inspected to break cycles.
+func (r *Reflector) hasUnion(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t.Implements(unmarshalerType) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return false
+	}
+	if visiting[t] {
+		return false
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	switch t.Kind() {
+	case reflect.Interface:
+		return r.union(t) != nil
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		return r.hasUnion(t.Elem(), visiting)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if r.hasUnion(t.Field(i).Type, visiting) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func isNull(data []byte) bool {
+	return bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+}
@@ -0,0 +1,136 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type source interface{ isSource() }
+
+type pathSource struct {
+	Path string `json:"path"`
+}
+
+type inlineSource struct {
+	Kind    string `json:"kind"`
+	Content string `json:"content"`
+}
+
+func (pathSource) isSource()    {}
+func (*inlineSource) isSource() {}
+
+type loadReq struct {
+	Sources []source `json:"sources"`
+	Primary source   `json:"primary"`
+}
+
+func unionReflector(t *testing.T) *Reflector {
+	t.Helper()
+	var r Reflector
+	err := r.RegisterUnion(reflect.TypeOf((*source)(nil)).Elem(), Union{
+		Discriminator: "kind",
+		Variants: map[string]reflect.Type{
+			"path":   reflect.TypeOf(pathSource{}),
+			"inline": reflect.TypeOf(&inlineSource{}),
+		},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return &r
+}
+
+func TestUnionUnmarshal(t *testing.T) {
+	r := unionReflector(t)
+	in := `{"primary":{"kind":"path","path":"/tmp/a"},"sources":[{"kind":"inline","content":"hi"},{"kind":"path","path":"b"}]}`
+	var req loadReq
+	if err := r.Unmarshal([]byte(in), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p, ok := req.Primary.(pathSource); !ok || p.Path != "/tmp/a" {
+		t.Fatalf("unexpected primary: %#v", req.Primary)
+	}
+	if len(req.Sources) != 2 {
+		t.Fatalf("unexpected sources: %#v", req.Sources)
+	}
+	if s, ok := req.Sources[0].(*inlineSource); !ok || s.Content != "hi" || s.Kind != "inline" {
+		t.Fatalf("unexpected source 0: %#v", req.Sources[0])
+	}
+	if err := r.Unmarshal([]byte(`{"primary":{"kind":"url"}}`), &req); err == nil {
+		t.Fatal("expected error for unknown variant")
+	}
+}
+
+func TestUnionSchema(t *testing.T) {
+	r := unionReflector(t)
+	s := r.ReflectFromType(reflect.TypeOf(loadReq{}))
+	primary := s.Properties["primary"]
+	if len(primary.OneOf) != 2 || primary.OneOf[0].Properties["kind"].Const != "inline" {
+		t.Fatalf("unexpected primary schema: %+v", primary)
+	}
+	if err := s.ValidateJSON([]byte(`{"sources":[],"primary":{"kind":"path","path":"a"}}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := s.ValidateJSON([]byte(`{"sources":[],"primary":{"kind":"path","path":1}}`))
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Path != "/primary/path" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestUnionMarshal(t *testing.T) {
+	r := unionReflector(t)
+	s := r.ReflectFromType(reflect.TypeOf(loadReq{}))
+	req := loadReq{Primary: pathSource{Path: "a"}, Sources: []source{&inlineSource{Content: "hi"}}}
+	data, err := r.Marshal(req)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"primary":{"kind":"path","path":"a"},"sources":[{"content":"hi","kind":"inline"}]}`; string(data) != want {
+		t.Fatalf("unexpected encoding %s", data)
+	}
+	if err := s.ValidateJSON(data); err != nil {
+		t.Fatalf("encoding does not match the schema: %v", err)
+	}
+	var back loadReq
+	if err := r.Unmarshal(data, &back); err != nil || back.Primary != req.Primary {
+		t.Fatalf("round trip: %+v, %v", back, err)
+	}
+	if _, err := r.Marshal(loadReq{Primary: otherSource{}}); err == nil {
+		t.Fatal("expected an error for an unregistered variant")
+	}
+}
+
+type otherSource struct{}
+
+func (otherSource) isSource() {}
+
+func TestUnionIntKeyedMap(t *testing.T) {
+	r := unionReflector(t)
+	var in map[int]source
+	if err := r.Unmarshal([]byte(`{"1":{"kind":"path","path":"a"},"-2":{"kind":"inline","content":"hi"}}`), &in); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if p, ok := in[1].(pathSource); !ok || p.Path != "a" {
+		t.Fatalf("unexpected entry 1: %#v", in[1])
+	}
+	if s, ok := in[-2].(*inlineSource); !ok || s.Content != "hi" {
+		t.Fatalf("unexpected entry -2: %#v", in[-2])
+	}
+	if err := r.Unmarshal([]byte(`{"x":{"kind":"path","path":"a"}}`), &in); err == nil {
+		t.Fatal("expected an error for a non-integer key")
+	}
+	var small map[uint8]source
+	if err := r.Unmarshal([]byte(`{"300":{"kind":"path","path":"a"}}`), &small); err == nil {
+		t.Fatal("expected an error for an overflowing key")
+	}
+
+	data, err := r.Marshal(map[int]source{1: pathSource{Path: "a"}})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"1":{"kind":"path","path":"a"}}`; string(data) != want {
+		t.Fatalf("unexpected encoding %s", data)
+	}
+}
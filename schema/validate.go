@@ -0,0 +1,367 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ValidationError describes a single place where a value does not conform
+// to a Schema. Path is a JSON Pointer (RFC 6901) to the offending value; the
+// empty string refers to the root.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	path := e.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Message
+}
+
+// ValidationErrors is the list of problems found by Validate.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateJSON checks the raw JSON document data against s. It returns nil
+// when data conforms, ValidationErrors when it does not and a *json.SyntaxError
+// or similar when data is not valid JSON.
+func (s *Schema) ValidateJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return fmt.Errorf("unexpected data after top-level value")
+	}
+	return s.Validate(v)
+}
+
+// Validate checks v against s. v must be a value as produced by decoding JSON
+// into an any: map[string]any, []any, string, bool, nil, float64 or
+// json.Number.
+func (s *Schema) Validate(v any) error {
+	var errs ValidationErrors
+	s.validate("", v, &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (s *Schema) validate(path string, v any, errs *ValidationErrors) {
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if v == nil && s.Nullable {
+		return
+	}
+	if s.Type != "" && !hasType(v, s.Type) {
+		fail("expected %s, got %s", s.Type, typeOf(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(v, s.Enum) {
+		fail("value must be one of %s", formatEnum(s.Enum))
+	}
+	if s.Const != nil && !inEnum(v, []any{s.Const}) {
+		fail("value must be %s", formatValue(s.Const))
+	}
+	if len(s.OneOf) > 0 {
+		s.validateBranches("oneOf", s.OneOf, path, v, errs)
+	}
+	if len(s.AnyOf) > 0 {
+		s.validateBranches("anyOf", s.AnyOf, path, v, errs)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("unknown property %q", name)
+				}
+				continue
+			}
+			prop.validate(path+"/"+escapePointer(name), v[name], errs)
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must contain at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must contain at most %d items", *s.MaxItems)
+		}
+		for i, item := range v {
+			s.Items.validate(path+"/"+strconv.Itoa(i), item, errs)
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := compilePattern(s.Pattern)
+			if err != nil {
+				fail("schema pattern %q is invalid: %v", s.Pattern, err)
+			} else if !re.MatchString(v) {
+				fail("must match pattern %q", s.Pattern)
+			}
+		}
+	case json.Number, float64:
+		f, _ := toFloat(v)
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+// validateBranches checks v against the oneOf or anyOf subschemas in
+// branches. When nothing matches, the errors of the branch selected by v's
+// const (discriminator) properties are reported, so callers see why their
+// chosen variant is invalid rather than a bare "no match".
+func (s *Schema) validateBranches(keyword string, branches []*Schema, path string, v any, errs *ValidationErrors) {
+	var matched int
+	var selected ValidationErrors
+	var nselected int
+	for _, b := range branches {
+		var berrs ValidationErrors
+		b.validate(path, v, &berrs)
+		if len(berrs) == 0 {
+			matched++
+			continue
+		}
+		if discriminates(b, v) {
+			selected = berrs
+			nselected++
+		}
+	}
+	switch {
+	case matched == 1, matched > 1 && keyword == "anyOf":
+		return
+	case matched > 1:
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf("must match exactly one %s schema, matched %d", keyword, matched)})
+	case nselected == 1:
+		*errs = append(*errs, selected...)
+	default:
+		*errs = append(*errs, &ValidationError{Path: path, Message: fmt.Sprintf("must match one of the %d %s schemas", len(branches), keyword)})
+	}
+}
+
+// discriminates reports whether every const property of b is present in v
+// with the expected value.
+func discriminates(b *Schema, v any) bool {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return false
+	}
+	var found bool
+	for name, prop := range b.Properties {
+		if prop.Const == nil {
+			continue
+		}
+		val, ok := obj[name]
+		if !ok || !inEnum(val, []any{prop.Const}) {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+func hasType(v any, typ string) bool {
+	switch typ {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	case "number":
+		_, ok := toFloat(v)
+		return ok
+	case "integer":
+		f, ok := toFloat(v)
+		return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	}
+	return true
+}
+
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		if f, _ := toFloat(v); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func inEnum(v any, enum []any) bool {
+	want, err := json.Marshal(normalize(v))
+	if err != nil {
+		return false
+	}
+	for _, e := range enum {
+		if got, err := json.Marshal(normalize(e)); err == nil && bytes.Equal(want, got) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize converts numbers to float64 so that equal JSON values marshal
+// identically regardless of how they were decoded.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = normalize(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		parts[i] = formatValue(e)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+func formatValue(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func escapePointer(s string) string {
+	s = strings.ReplaceAll(s, "~", "~0")
+	return strings.ReplaceAll(s, "/", "~1")
+}
+
+// maxPatterns bounds the number of compiled patterns kept by compilePattern.
+const maxPatterns = 256
+
+// patterns caches compiled patterns, which are shared by every validation
+// against a schema. Once it holds maxPatterns entries an arbitrary one is
+// evicted for each new pattern, so schemas built from untrusted input
+// cannot grow it without bound.
+var patterns struct {
+	mu sync.Mutex
+	m  map[string]compiledPattern
+}
+
+type compiledPattern struct {
+	re  *regexp.Regexp
+	err error
+}
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	patterns.mu.Lock()
+	c, ok := patterns.m[p]
+	patterns.mu.Unlock()
+	if ok {
+		return c.re, c.err
+	}
+	c.re, c.err = regexp.Compile(p)
+	patterns.mu.Lock()
+	defer patterns.mu.Unlock()
+	if patterns.m == nil {
+		patterns.m = make(map[string]compiledPattern)
+	}
+	for k := range patterns.m {
+		if len(patterns.m) < maxPatterns {
+			break
+		}
+		delete(patterns.m, k)
+	}
+	patterns.m[p] = c
+	return c.re, c.err
+}
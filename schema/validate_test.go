@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+type validateAddress struct {
+	Street string `json:"street" jsonschema:"minLength=1"`
+	Zip    string `json:"zip,omitempty" jsonschema:"pattern=^[0-9]{5}$"`
+}
+
+type validateUser struct {
+	Name      string            `json:"name"`
+	Age       int               `json:"age" jsonschema:"minimum=0,maximum=150"`
+	Role      string            `json:"role,omitempty" jsonschema:"enum=admin|member"`
+	Nick      *string           `json:"nick"`
+	Addresses []validateAddress `json:"addresses,omitempty"`
+}
+
+func TestValidateJSON(t *testing.T) {
+	s := Reflect(validateUser{})
+	tests := []struct {
+		name  string
+		in    string
+		paths []string
+	}{
+		{"valid", `{"name":"a","age":3,"nick":null}`, nil},
+		{"valid without optional", `{"name":"a","age":3}`, nil},
+		{"missing required", `{"age":3}`, []string{""}},
+		{"unknown property", `{"name":"a","age":3,"extra":1}`, []string{""}},
+		{"wrong type", `{"name":1,"age":3}`, []string{"/name"}},
+		{"not an integer", `{"name":"a","age":3.5}`, []string{"/age"}},
+		{"out of range", `{"name":"a","age":-1}`, []string{"/age"}},
+		{"enum", `{"name":"a","age":3,"role":"owner"}`, []string{"/role"}},
+		{"nested", `{"name":"a","age":3,"addresses":[{"street":"x"},{"street":"","zip":"abc"}]}`,
+			[]string{"/addresses/1/street", "/addresses/1/zip"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateJSON([]byte(tt.in))
+			if len(tt.paths) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var verrs ValidationErrors
+			if !errors.As(err, &verrs) {
+				t.Fatalf("expected validation errors, got %v", err)
+			}
+			if len(verrs) != len(tt.paths) {
+				t.Fatalf("expected %d errors, got %v", len(tt.paths), verrs)
+			}
+			for i, p := range tt.paths {
+				if verrs[i].Path != p {
+					t.Fatalf("error %d: expected path %q, got %q", i, p, verrs[i].Path)
+				}
+			}
+		})
+	}
+}
+
+func TestNullableRoundTrip(t *testing.T) {
+	s := Reflect(validateUser{})
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var out Schema
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	nick := out.Properties["nick"]
+	if nick == nil || nick.Type != "string" || !nick.Nullable {
+		t.Fatalf("unexpected nick schema: %s", b)
+	}
+	if err := out.ValidateJSON([]byte(`{"name":"a","age":1,"nick":null}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestPatternCacheBounded(t *testing.T) {
+	for i := 0; i < 2*maxPatterns; i++ {
+		if _, err := compilePattern("^a{" + strconv.Itoa(i) + "}$"); err != nil {
+			t.Fatalf("compile: %v", err)
+		}
+	}
+	if _, err := compilePattern("("); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+	patterns.mu.Lock()
+	n := len(patterns.m)
+	patterns.mu.Unlock()
+	if n > maxPatterns {
+		t.Fatalf("cache holds %d patterns, want at most %d", n, maxPatterns)
+	}
+}
@@ -0,0 +1,96 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cyrusaf/mcp/auth"
+)
+
+// ClaimsConn is implemented by connections whose messages were sent by an
+// authenticated caller.
+type ClaimsConn interface {
+	Conn
+	Claims() *auth.Claims
+}
+
+type bearerAuth struct {
+	verifier auth.TokenVerifier
+	meta     auth.ProtectedResourceMetadata
+	scopes   []string
+	metadata http.Handler
+}
+
+// WithBearerAuth requires every request to carry an OAuth 2.1 bearer token
+// accepted by v, issued for meta.Resource and granting requiredScopes. The
+// token's audience claim must contain meta.Resource, the canonical URL of
+// the server, so v must report the audience in auth.Claims. Requests
+// without a valid token are rejected with 401 and a WWW-Authenticate
+// challenge pointing to the protected resource metadata, which is served
+// at the well-known path derived from meta.Resource. The verified claims
+// are available to handlers through auth.ClaimsFrom, and sessions can only
+// be used by the subject that created them.
+//
+// WithBearerAuth panics if meta.Resource is empty.
+func WithBearerAuth(v auth.TokenVerifier, meta auth.ProtectedResourceMetadata, requiredScopes ...string) HTTPOption {
+	if meta.Resource == "" {
+		panic("transport: WithBearerAuth requires meta.Resource")
+	}
+	return func(h *httpTransport) {
+		h.auth = &bearerAuth{verifier: v, meta: meta, scopes: requiredScopes, metadata: auth.MetadataHandler(meta)}
+	}
+}
+
+// isMetadataRequest reports whether r asks for the protected resource
+// metadata.
+func (a *bearerAuth) isMetadataRequest(r *http.Request) bool {
+	return r.URL.Path == auth.WellKnownPath || strings.HasPrefix(r.URL.Path, auth.WellKnownPath+"/")
+}
+
+// authenticate verifies the bearer token of r. On failure it writes the
+// challenge and returns nil.
+func (a *bearerAuth) authenticate(w http.ResponseWriter, r *http.Request) *auth.Claims {
+	token, ok := auth.BearerToken(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", auth.Challenge(a.meta.MetadataURL(), nil, a.scopes...))
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	claims, err := a.verifier.VerifyToken(r.Context(), token)
+	switch {
+	case err == nil && claims == nil:
+		err = fmt.Errorf("%w: no claims", auth.ErrInvalidToken)
+	case err == nil && !slices.Contains(claims.Audience, a.meta.Resource):
+		// a token issued for another resource must not be replayed here
+		err = fmt.Errorf("%w: token not issued for %s", auth.ErrInvalidToken, a.meta.Resource)
+	case err == nil && !claims.HasScopes(a.scopes...):
+		err = auth.ErrInsufficientScope
+	case err != nil && !errors.Is(err, auth.ErrInvalidToken):
+		// the verifier could not decide, for example because the
+		// authorization server is unreachable
+		http.Error(w, "token verification failed", http.StatusServiceUnavailable)
+		return nil
+	}
+	if err != nil {
+		status := http.StatusUnauthorized
+		if errors.Is(err, auth.ErrInsufficientScope) {
+			status = http.StatusForbidden
+		}
+		w.Header().Set("WWW-Authenticate", auth.Challenge(a.meta.MetadataURL(), err, a.scopes...))
+		http.Error(w, http.StatusText(status), status)
+		return nil
+	}
+	return claims
+}
+
+// ownsSession reports whether the caller of r may use the session rec.
+func ownsSession(r *http.Request, rec SessionRecord) bool {
+	if rec.Subject == "" {
+		return true
+	}
+	claims, _ := auth.ClaimsFrom(r.Context())
+	return claims != nil && claims.Subject == rec.Subject
+}
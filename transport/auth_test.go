@@ -0,0 +1,141 @@
+package transport_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyrusaf/mcp/auth"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// testVerifier accepts tokens of the form "<subject>:<scope>,<scope>",
+// issued for https://mcp.example.com/mcp unless followed by
+// "@<audience>".
+var testVerifier = auth.TokenVerifierFunc(func(ctx context.Context, token string) (*auth.Claims, error) {
+	token, aud, ok := strings.Cut(token, "@")
+	if !ok {
+		aud = "https://mcp.example.com/mcp"
+	}
+	sub, scopes, ok := strings.Cut(token, ":")
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown token", auth.ErrInvalidToken)
+	}
+	return &auth.Claims{Subject: sub, Audience: []string{aud}, Scopes: strings.Split(scopes, ",")}, nil
+})
+
+func authRequest(t *testing.T, method, url, token, session, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if session != "" {
+		req.Header.Set(transport.SessionHeader, session)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHTTPBearerAuth(t *testing.T) {
+	meta := auth.ProtectedResourceMetadata{
+		Resource:             "https://mcp.example.com/mcp",
+		AuthorizationServers: []string{"https://idp.example.com"},
+		ScopesSupported:      []string{"mcp"},
+	}
+	tr := transport.HTTPHandler(transport.WithBearerAuth(testVerifier, meta, "mcp"))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	reg := registry.New()
+	registry.RegisterTool(reg, "WhoAmI", func(ctx context.Context, in struct{}) (struct{ Subject string }, error) {
+		claims, ok := auth.ClaimsFrom(ctx)
+		if !ok {
+			return struct{ Subject string }{}, fmt.Errorf("no claims")
+		}
+		return struct{ Subject string }{claims.Subject}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(reg, tr).Run(ctx) }()
+	defer tr.Close()
+
+	initialize := `{"jsonrpc":"2.0","id":0,"method":"initialize"}`
+	resp := authRequest(t, http.MethodPost, srv.URL, "", "", initialize)
+	challenge := resp.Header.Get("WWW-Authenticate")
+	if resp.StatusCode != http.StatusUnauthorized ||
+		!strings.Contains(challenge, `resource_metadata="https://mcp.example.com/.well-known/oauth-protected-resource/mcp"`) {
+		t.Fatalf("missing token: status %d, challenge %q", resp.StatusCode, challenge)
+	}
+	resp = authRequest(t, http.MethodPost, srv.URL, "garbage", "", initialize)
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Fatalf("invalid token: status %d, challenge %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	resp = authRequest(t, http.MethodPost, srv.URL, "alice:mcp@https://other.example.com", "", initialize)
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Fatalf("wrong audience: status %d, challenge %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	resp = authRequest(t, http.MethodPost, srv.URL, "alice:other", "", initialize)
+	if resp.StatusCode != http.StatusForbidden || !strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="insufficient_scope", scope="mcp"`) {
+		t.Fatalf("insufficient scope: status %d, challenge %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	resp = authRequest(t, http.MethodGet, srv.URL+auth.WellKnownPath+"/mcp", "", "", "")
+	var got auth.ProtectedResourceMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil || got.Resource != meta.Resource || len(got.AuthorizationServers) != 1 {
+		t.Fatalf("metadata: %+v, %v", got, err)
+	}
+
+	resp = authRequest(t, http.MethodPost, srv.URL, "alice:mcp", "", initialize)
+	session := resp.Header.Get(transport.SessionHeader)
+	if resp.StatusCode != http.StatusOK || session == "" {
+		t.Fatalf("initialize: status %d", resp.StatusCode)
+	}
+	resp = authRequest(t, http.MethodPost, srv.URL, "alice:mcp", session,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"WhoAmI"}}`)
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"Subject":"alice"`) {
+		t.Fatalf("claims not passed to handler: %s", body)
+	}
+	resp = authRequest(t, http.MethodPost, srv.URL, "bob:mcp", session,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"WhoAmI"}}`)
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected another user's session to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPBearerAuthNoClaims(t *testing.T) {
+	meta := auth.ProtectedResourceMetadata{Resource: "https://mcp.example.com/mcp"}
+	none := auth.TokenVerifierFunc(func(ctx context.Context, token string) (*auth.Claims, error) {
+		return nil, nil
+	})
+	srv := httptest.NewServer(transport.HTTPHandler(transport.WithBearerAuth(none, meta)))
+	defer srv.Close()
+
+	resp := authRequest(t, http.MethodPost, srv.URL, "alice:mcp", "", `{"jsonrpc":"2.0","id":0,"method":"initialize"}`)
+	if resp.StatusCode != http.StatusUnauthorized || !strings.Contains(resp.Header.Get("WWW-Authenticate"), `error="invalid_token"`) {
+		t.Fatalf("no claims: status %d, challenge %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+}
+
+func TestWithBearerAuthRequiresResource(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic without meta.Resource")
+		}
+	}()
+	transport.WithBearerAuth(testVerifier, auth.ProtectedResourceMetadata{})
+}
@@ -0,0 +1,315 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+// ErrProcessExited is returned by a command transport once its process
+// has exited and may not be restarted.
+var ErrProcessExited = errors.New("transport: process exited")
+
+type commandTransport struct {
+	name     string
+	args     []string
+	env      []string
+	dir      string
+	logger   *log.Logger
+	restarts int
+	maxLine  int
+
+	mu      sync.Mutex
+	proc    *process
+	started int
+	closed  bool
+	done    chan struct{} // closed by Shutdown
+}
+
+// process is a single run of the command.
+type process struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	out    *lineWriter
+	lines  chan json.RawMessage // stdout messages, closed at EOF
+
+	exited chan struct{}
+	err    error
+}
+
+// CommandOption configures the transport returned by CommandTransport.
+type CommandOption func(*commandTransport)
+
+// WithCommandEnv adds environment variables, in "key=value" form, to the
+// environment inherited from the current process.
+func WithCommandEnv(env ...string) CommandOption {
+	return func(t *commandTransport) { t.env = append(t.env, env...) }
+}
+
+// WithCommandDir sets the working directory of the command.
+func WithCommandDir(dir string) CommandOption {
+	return func(t *commandTransport) { t.dir = dir }
+}
+
+// WithStderrLogger logs every line the command writes to stderr to l. It
+// defaults to log.Default().
+func WithStderrLogger(l *log.Logger) CommandOption {
+	return func(t *commandTransport) { t.logger = l }
+}
+
+// WithRestart allows the command to be started again up to n times after
+// it exits. The exit is still reported by Next, so callers can initialize
+// the new process; it is started by the following call to Next or Notify.
+func WithRestart(n int) CommandOption {
+	return func(t *commandTransport) { t.restarts = n }
+}
+
+// WithCommandMaxLineSize sets the largest message read from the command's
+// stdout. Longer lines are discarded.
+func WithCommandMaxLineSize(n int) CommandOption {
+	return func(t *commandTransport) { t.maxLine = n }
+}
+
+// CommandTransport starts the named program and returns the client side of
+// a stdio connection to it: Notify writes to its stdin and Next reads the
+// messages it writes to stdout. When the process exits Next returns an
+// error wrapping ErrProcessExited and the exit status. Close closes stdin,
+// which tells stdio servers to exit, and kills the process if it has not
+// exited within DefaultShutdownTimeout.
+func CommandTransport(name string, args []string, opts ...CommandOption) (Transport, error) {
+	t := &commandTransport{
+		name:    name,
+		args:    args,
+		logger:  log.Default(),
+		maxLine: DefaultMaxLineSize,
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if _, err := t.current(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// current returns the running process, starting one if allowed.
+func (t *commandTransport) current() (*process, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case t.closed:
+		return nil, io.EOF
+	case t.proc != nil:
+		return t.proc, nil
+	case t.started > t.restarts:
+		return nil, ErrProcessExited
+	}
+	p, err := t.start()
+	if err != nil {
+		return nil, err
+	}
+	t.proc = p
+	t.started++
+	return p, nil
+}
+
+// start runs the command with its standard streams connected to pipes
+// owned by the transport, so reading stdout never races with Wait.
+func (t *commandTransport) start() (*process, error) {
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return nil, err
+	}
+	errR, errW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		outR.Close()
+		outW.Close()
+		return nil, err
+	}
+	cmd := exec.Command(t.name, t.args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = inR, outW, errW
+	cmd.Dir = t.dir
+	if len(t.env) > 0 {
+		cmd.Env = append(os.Environ(), t.env...)
+	}
+	err = cmd.Start()
+	// the child holds its own copies of these
+	inR.Close()
+	outW.Close()
+	errW.Close()
+	if err != nil {
+		inW.Close()
+		outR.Close()
+		errR.Close()
+		return nil, fmt.Errorf("transport: starting %s: %w", t.name, err)
+	}
+
+	p := &process{
+		cmd:    cmd,
+		stdin:  inW,
+		stdout: outR,
+		out:    &lineWriter{w: inW},
+		lines:  make(chan json.RawMessage),
+		exited: make(chan struct{}),
+	}
+	go t.logStderr(errR)
+	go t.readStdout(p)
+	go func() {
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+func (t *commandTransport) logStderr(r io.ReadCloser) {
+	defer r.Close()
+	prefix := filepath.Base(t.name)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 4096), DefaultMaxLineSize)
+	for sc.Scan() {
+		t.logger.Printf("%s: %s", prefix, sc.Text())
+	}
+}
+
+// readStdout hands the messages p writes to Next, so that Next can give up
+// waiting when its context ends.
+func (t *commandTransport) readStdout(p *process) {
+	defer close(p.lines)
+	in := bufio.NewReader(p.stdout)
+	for {
+		line, err := readLine(in, t.maxLine)
+		if errors.Is(err, errLineTooLong) {
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			select {
+			case p.lines <- json.RawMessage(line):
+			case <-t.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *process) send(msg json.RawMessage) error {
+	select {
+	case <-p.exited:
+		return ErrConnClosed
+	default:
+	}
+	if err := p.out.writeLine(msg); err != nil {
+		return ErrConnClosed
+	}
+	return nil
+}
+
+type commandConn struct{ p *process }
+
+func (c commandConn) Send(ctx context.Context, msg json.RawMessage) error {
+	return c.p.send(msg)
+}
+
+func (t *commandTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	p, err := t.current()
+	if err != nil {
+		return nil, nil, err
+	}
+	select {
+	case line, ok := <-p.lines:
+		if !ok {
+			return nil, nil, t.exited(p)
+		}
+		return commandConn{p}, line, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
+
+// exited waits for p to exit after its stdout ended and forgets it, so
+// that the next call may restart the command.
+func (t *commandTransport) exited(p *process) error {
+	<-p.exited
+	p.stdin.Close()
+	p.stdout.Close()
+	t.mu.Lock()
+	closed := t.closed
+	if t.proc == p {
+		t.proc = nil
+	}
+	t.mu.Unlock()
+	if closed {
+		return io.EOF
+	}
+	if p.err != nil {
+		return fmt.Errorf("%w: %v", ErrProcessExited, p.err)
+	}
+	return ErrProcessExited
+}
+
+// Notify writes msg to the command's stdin.
+func (t *commandTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	p, err := t.current()
+	if err != nil {
+		return err
+	}
+	return p.send(msg)
+}
+
+// Shutdown closes the command's stdin and waits for it to exit, killing it
+// once ctx expires.
+func (t *commandTransport) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	p := t.proc
+	t.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	p.stdin.Close()
+	var err error
+	select {
+	case <-p.exited:
+	case <-ctx.Done():
+		_ = p.cmd.Process.Kill()
+		<-p.exited
+		err = ctx.Err()
+	}
+	// unblock Next if a child of the command still holds stdout
+	p.stdout.Close()
+	return err
+}
+
+func (t *commandTransport) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	err := t.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
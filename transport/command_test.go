@@ -0,0 +1,146 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyrusaf/mcp/client"
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// TestMain lets the test binary act as a stdio server for the command
+// transport tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_STDIO_SERVER") == "1" {
+		reg := registry.New()
+		registry.RegisterTool(reg, "Crash", func(ctx context.Context, in struct{}) (struct{}, error) {
+			fmt.Fprintln(os.Stderr, "crashing")
+			os.Exit(3)
+			return struct{}{}, nil
+		})
+		if err := rpc.NewServer(reg, transport.StdioTransport()).Run(context.Background()); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCommandTransportRestart(t *testing.T) {
+	var stderr syncBuffer
+	tr, err := transport.CommandTransport(os.Args[0], nil,
+		transport.WithCommandEnv("MCP_TEST_STDIO_SERVER=1"),
+		transport.WithStderrLogger(log.New(&stderr, "", 0)),
+		transport.WithRestart(1))
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer tr.Close()
+	ctx := context.Background()
+
+	for run := 0; run < 2; run++ {
+		c, err := client.Connect(ctx, tr)
+		if err != nil {
+			t.Fatalf("run %d: connect: %v", run, err)
+		}
+		if _, err := c.CallTool(ctx, "Crash", nil); !errors.Is(err, transport.ErrProcessExited) || !strings.Contains(err.Error(), "exit status 3") {
+			t.Fatalf("run %d: expected the exit to be reported, got %v", run, err)
+		}
+	}
+	if !strings.Contains(stderr.String(), filepath.Base(os.Args[0])+": crashing") {
+		t.Fatalf("stderr not logged: %q", stderr.String())
+	}
+	if _, err := client.Connect(ctx, tr); !errors.Is(err, transport.ErrProcessExited) {
+		t.Fatalf("expected no further restarts, got %v", err)
+	}
+}
+
+func TestCommandTransportMCPStdio(t *testing.T) {
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	bin := filepath.Join(t.TempDir(), "mcp-stdio")
+	if out, err := exec.Command(gobin, "build", "-o", bin, "../cmd/mcp-stdio").CombinedOutput(); err != nil {
+		t.Fatalf("build: %v\n%s", err, out)
+	}
+	tr, err := transport.CommandTransport(bin, nil)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	ctx := context.Background()
+	c, err := client.Connect(ctx, tr)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	tools, err := c.ListTools(ctx)
+	if err != nil || len(tools) != 1 || tools[0].Name != "CreateUser" {
+		t.Fatalf("ListTools: %+v, %v", tools, err)
+	}
+	res, err := c.CallTool(ctx, "CreateUser", map[string]any{"Handle": "gopher"})
+	if err != nil || res.IsError || !strings.Contains(string(res.StructuredContent), `"ID":1`) {
+		t.Fatalf("CallTool: %+v, %v", res, err)
+	}
+
+	// closing stdin makes the server exit on its own
+	sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := tr.(transport.Shutdowner).Shutdown(sctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	<-c.Done()
+	c.Close()
+}
+
+func TestCommandTransportNextCancel(t *testing.T) {
+	tr, err := transport.CommandTransport(os.Args[0], nil, transport.WithCommandEnv("MCP_TEST_STDIO_SERVER=1"))
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer tr.Close()
+
+	// the server only writes in reply, so Next must give up on its own
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := tr.Next(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	c, err := client.Connect(context.Background(), tr)
+	if err != nil {
+		t.Fatalf("connect after cancelled Next: %v", err)
+	}
+	defer c.Close()
+	if _, err := c.ListTools(context.Background()); err != nil {
+		t.Fatalf("ListTools: %v", err)
+	}
+}
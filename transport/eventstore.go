@@ -0,0 +1,233 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxEvents is the number of events kept per stream by the event
+// stores in this package, and per session by the HTTP transports, when no
+// limit is given.
+const DefaultMaxEvents = 1024
+
+// Event is a message sent on a server-sent event stream.
+type Event struct {
+	StreamID string          `json:"streamId"`
+	Seq      int64           `json:"seq"`
+	Data     json.RawMessage `json:"data"`
+}
+
+// ID returns the SSE event ID of e, from which its stream and position can
+// be recovered when a client reconnects with Last-Event-ID.
+func (e Event) ID() string {
+	return e.StreamID + "/" + strconv.FormatInt(e.Seq, 10)
+}
+
+func parseEventID(id string) (streamID string, seq int64, ok bool) {
+	i := strings.LastIndexByte(id, '/')
+	if i <= 0 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:i], seq, true
+}
+
+// EventStore keeps a bounded log of the events sent on each stream so they
+// can be replayed to clients that reconnect. Implementations must be safe
+// for concurrent use.
+type EventStore interface {
+	Append(ctx context.Context, ev Event) error
+	// After returns the stored events of streamID with a sequence number
+	// greater than seq, oldest first.
+	After(ctx context.Context, streamID string, seq int64) ([]Event, error)
+	// Delete discards the events of streamID.
+	Delete(ctx context.Context, streamID string) error
+}
+
+type memoryEventStore struct {
+	max int
+
+	mu      sync.Mutex
+	streams map[string][]Event
+}
+
+// NewMemoryEventStore returns an EventStore that keeps the last max events
+// of each stream in memory. A max of zero means DefaultMaxEvents.
+func NewMemoryEventStore(max int) EventStore {
+	if max <= 0 {
+		max = DefaultMaxEvents
+	}
+	return &memoryEventStore{max: max, streams: make(map[string][]Event)}
+}
+
+func (m *memoryEventStore) Append(ctx context.Context, ev Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := append(m.streams[ev.StreamID], ev)
+	if len(events) > m.max {
+		events = append([]Event(nil), events[len(events)-m.max:]...)
+	}
+	m.streams[ev.StreamID] = events
+	return nil
+}
+
+func (m *memoryEventStore) After(ctx context.Context, streamID string, seq int64) ([]Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return eventsAfter(m.streams[streamID], seq), nil
+}
+
+func (m *memoryEventStore) Delete(ctx context.Context, streamID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.streams, streamID)
+	return nil
+}
+
+func eventsAfter(events []Event, seq int64) []Event {
+	var out []Event
+	for _, ev := range events {
+		if ev.Seq > seq {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+type fileEventStore struct {
+	dir string
+	max int
+
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+// NewFileEventStore returns an EventStore that appends the events of each
+// stream to a JSON lines file in dir, keeping roughly the last max events.
+// A max of zero means DefaultMaxEvents.
+func NewFileEventStore(dir string, max int) (EventStore, error) {
+	if max <= 0 {
+		max = DefaultMaxEvents
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &fileEventStore{dir: dir, max: max, counts: make(map[string]int)}, nil
+}
+
+func (f *fileEventStore) path(streamID string) string {
+	return filepath.Join(f.dir, url.PathEscape(streamID)+".jsonl")
+}
+
+func (f *fileEventStore) Append(ctx context.Context, ev Event) error {
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := os.OpenFile(f.path(ev.StreamID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(line, '\n'))
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	f.counts[ev.StreamID]++
+	if f.counts[ev.StreamID] > 2*f.max {
+		// compact, amortising the rewrite over max appends
+		return f.compact(ev.StreamID)
+	}
+	return nil
+}
+
+func (f *fileEventStore) compact(streamID string) error {
+	events, err := f.read(streamID)
+	if err != nil {
+		return err
+	}
+	if len(events) > f.max {
+		events = events[len(events)-f.max:]
+	}
+	tmp := f.path(streamID) + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, ev := range events {
+		if err = enc.Encode(ev); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	f.counts[streamID] = len(events)
+	return os.Rename(tmp, f.path(streamID))
+}
+
+func (f *fileEventStore) read(streamID string) ([]Event, error) {
+	file, err := os.Open(f.path(streamID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var events []Event
+	dec := json.NewDecoder(file)
+	for dec.More() {
+		var ev Event
+		if err := dec.Decode(&ev); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func (f *fileEventStore) After(ctx context.Context, streamID string, seq int64) ([]Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	events, err := f.read(streamID)
+	if err != nil {
+		return nil, err
+	}
+	return eventsAfter(events, seq), nil
+}
+
+func (f *fileEventStore) Delete(ctx context.Context, streamID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.counts, streamID)
+	err := os.Remove(f.path(streamID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEventStores(t *testing.T) {
+	fileStore, err := NewFileEventStore(t.TempDir(), 3)
+	if err != nil {
+		t.Fatalf("file store: %v", err)
+	}
+	stores := map[string]EventStore{
+		"memory": NewMemoryEventStore(3),
+		"file":   fileStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 1; i <= 10; i++ {
+				ev := Event{StreamID: "s-1", Seq: int64(i), Data: json.RawMessage(fmt.Sprintf(`{"n":%d}`, i))}
+				if err := store.Append(ctx, ev); err != nil {
+					t.Fatalf("append: %v", err)
+				}
+			}
+			events, err := store.After(ctx, "s-1", 8)
+			if err != nil {
+				t.Fatalf("after: %v", err)
+			}
+			if len(events) != 2 || events[0].Seq != 9 || string(events[1].Data) != `{"n":10}` {
+				t.Fatalf("unexpected events: %+v", events)
+			}
+			// old events are discarded eventually
+			if events, _ := store.After(ctx, "s-1", 0); len(events) > 6 || events[len(events)-1].Seq != 10 {
+				t.Fatalf("store is not bounded: %+v", events)
+			}
+			if err := store.Delete(ctx, "s-1"); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if events, _ := store.After(ctx, "s-1", 0); len(events) != 0 {
+				t.Fatalf("unexpected events after delete: %+v", events)
+			}
+		})
+	}
+}
+
+func TestHTTPResumeAfterDisconnect(t *testing.T) {
+	tr := newHTTPTransport(WithEventStore(NewMemoryEventStore(0)))
+	srv := httptestServer(t, tr)
+	release := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			conn, raw, err := tr.Next(ctx)
+			if err != nil {
+				return
+			}
+			m, _ := parseMessage(raw)
+			if !m.isRequest() {
+				continue
+			}
+			go func() {
+				if m.Method == "gated" {
+					_ = conn.Send(ctx, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+					<-release
+				}
+				_ = conn.Send(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":`+string(m.ID)+`,"result":{}}`))
+			}()
+		}
+	}()
+
+	sess := initSession(t, srv.URL)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":7,"method":"gated"}`))
+	req.Header.Set(SessionHeader, sess)
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	ids, _ := readEventIDs(t, resp.Body, 1)
+	resp.Body.Close() // drop the connection mid-call
+	close(release)
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set(SessionHeader, sess)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", ids[0])
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	_, data := readEventIDs(t, resp.Body, 1)
+	if !strings.Contains(data[0], `"id":7`) {
+		t.Fatalf("unexpected replay: %q", data)
+	}
+}
+
+func TestHTTPSessionEventLimit(t *testing.T) {
+	store := NewMemoryEventStore(0)
+	_, srv := startStreamTest(t, WithEventStore(store), WithMaxSessionEvents(3))
+	sess := initSession(t, srv.URL)
+	var streams []string
+	for i := 0; i < 3; i++ {
+		resp := post(t, srv.URL, sess, fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"slow"}`, i))
+		ids, _ := readEventIDs(t, resp.Body, 2)
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		id, _, _ := parseEventID(ids[0])
+		streams = append(streams, id)
+	}
+	for i, want := range []int{0, 0, 2} {
+		events, err := store.After(context.Background(), streams[i], 0)
+		if err != nil {
+			t.Fatalf("after: %v", err)
+		}
+		if len(events) != want {
+			t.Fatalf("stream %d: %d events kept, want %d", i, len(events), want)
+		}
+	}
+}
+
+func TestHTTPResumeAfterRestart(t *testing.T) {
+	sessions := NewMemorySessionStore()
+	dir := t.TempDir()
+	open := func() EventStore {
+		store, err := NewFileEventStore(dir, 0)
+		if err != nil {
+			t.Fatalf("file store: %v", err)
+		}
+		return store
+	}
+	_, before := startStreamTest(t, WithSessionStore(sessions), WithEventStore(open()))
+	sess := initSession(t, before.URL)
+	slow := func(url, id string) []string {
+		resp := post(t, url, sess, `{"jsonrpc":"2.0","id":`+id+`,"method":"slow"}`)
+		ids, _ := readEventIDs(t, resp.Body, 2)
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return ids
+	}
+	old := slow(before.URL, "1")
+
+	// a new process picks the session up from the stores
+	_, after := startStreamTest(t, WithSessionStore(sessions), WithEventStore(open()))
+	ids := slow(after.URL, "2")
+	oldStream, _, _ := parseEventID(old[0])
+	newStream, _, _ := parseEventID(ids[0])
+	if oldStream == newStream {
+		t.Fatalf("stream ID %s reused after restart", newStream)
+	}
+
+	resume := func(lastEventID string) []string {
+		req, _ := http.NewRequest(http.MethodGet, after.URL, nil)
+		req.Header.Set(SessionHeader, sess)
+		req.Header.Set("Accept", "text/event-stream")
+		req.Header.Set("Last-Event-ID", lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var data []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if strings.HasPrefix(line, "data: ") {
+				data = append(data, line)
+			}
+		}
+		return data
+	}
+	if data := resume(ids[0]); len(data) != 1 || !strings.Contains(data[0], `"id":2`) {
+		t.Fatalf("unexpected replay of the new stream: %q", data)
+	}
+	if data := resume(old[0]); len(data) != 1 || !strings.Contains(data[0], `"id":1`) {
+		t.Fatalf("unexpected replay of the old stream: %q", data)
+	}
+}
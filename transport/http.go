@@ -1,85 +1,866 @@
 package transport
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"io"
+	"net"
 	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cyrusaf/mcp/auth"
 )
 
+// SessionHeader carries the session ID on Streamable HTTP requests.
+const SessionHeader = "Mcp-Session-Id"
+
+// DefaultSessionIdleTimeout is how long an HTTP session may go without
+// requests or an open event stream before it expires.
+const DefaultSessionIdleTimeout = 30 * time.Minute
+
 type httpMessage struct {
 	req  json.RawMessage
-	conn *httpConn
+	conn Conn
+}
+
+// httpConn carries the messages the server sends while handling the
+// requests of a single POST. They are written by the HTTP handler
+// goroutine, which owns the ResponseWriter.
+type httpConn struct {
+	ch      chan json.RawMessage
+	closed  chan struct{}
+	session *Session
+	claims  *auth.Claims
+}
+
+func newHTTPConn(session *Session) *httpConn {
+	return &httpConn{ch: make(chan json.RawMessage), closed: make(chan struct{}), session: session}
 }
 
-type httpConn struct{ ch chan json.RawMessage }
+func (c *httpConn) Session() *Session { return c.session }
+
+func (c *httpConn) Claims() *auth.Claims { return c.claims }
 
 func (c *httpConn) Send(ctx context.Context, resp json.RawMessage) error {
 	select {
 	case c.ch <- resp:
 		return nil
+	case <-c.closed:
+		return ErrConnClosed
 	case <-ctx.Done():
 		return ctx.Err()
 	}
 }
 
+// discardConn is used for messages that never produce a response, such as
+// notifications; anything sent on it is dropped.
+type discardConn struct {
+	session *Session
+	claims  *auth.Claims
+}
+
+func (discardConn) Send(context.Context, json.RawMessage) error { return nil }
+
+func (c discardConn) Session() *Session { return c.session }
+
+func (c discardConn) Claims() *auth.Claims { return c.claims }
+
 type httpTransport struct {
 	srv   *http.Server
 	reqCh chan httpMessage
+	done  chan struct{}
+
+	sessions    SessionStore
+	idleTimeout time.Duration
+	maxSessions int
+	smu         sync.Mutex
+	live        map[string]*Session // sessions with state in this process
+	events      EventStore
+	maxEvents   int
+	maxBody     int64
+	guard       originGuard
+	auth        *bearerAuth
+
+	// used by ListenHTTP only
+	listener          net.Listener
+	tlsConfig         *tls.Config
+	certFile, keyFile string
+	serveDone         chan struct{}
+	serveErr          error
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// HTTPOption configures the transports returned by HTTPHandler, ListenHTTP
+// and HTTPTransport.
+type HTTPOption func(*httpTransport)
+
+// DefaultMaxRequestSize is the largest request body, in bytes, accepted by
+// the HTTP transports when no limit is given.
+const DefaultMaxRequestSize = 4 << 20
+
+// WithMaxRequestSize sets the largest request body accepted. Larger
+// requests are rejected with 413 Request Entity Too Large.
+func WithMaxRequestSize(n int64) HTTPOption {
+	return func(h *httpTransport) { h.maxBody = n }
+}
+
+// WithListener makes ListenHTTP serve on l instead of listening on its
+// address.
+func WithListener(l net.Listener) HTTPOption {
+	return func(h *httpTransport) { h.listener = l }
+}
+
+// WithTLSConfig makes ListenHTTP serve HTTPS using cfg, which must contain
+// the server certificates unless WithTLSCertFiles is also given.
+func WithTLSConfig(cfg *tls.Config) HTTPOption {
+	return func(h *httpTransport) { h.tlsConfig = cfg }
+}
+
+// WithTLSCertFiles makes ListenHTTP serve HTTPS with the certificate and
+// key in the given PEM files.
+func WithTLSCertFiles(certFile, keyFile string) HTTPOption {
+	return func(h *httpTransport) { h.certFile, h.keyFile = certFile, keyFile }
+}
+
+// WithSessionStore sets where the records of HTTP sessions are kept. The
+// default is NewMemorySessionStore(). A shared store lets a session created
+// by one transport be used with another, which starts it afresh without the
+// event streams and values held by the first.
+func WithSessionStore(store SessionStore) HTTPOption {
+	return func(h *httpTransport) { h.sessions = store }
+}
+
+// WithSessionIdleTimeout sets how long a session may be idle before it
+// expires. Zero disables expiry. The default is DefaultSessionIdleTimeout.
+func WithSessionIdleTimeout(d time.Duration) HTTPOption {
+	return func(h *httpTransport) { h.idleTimeout = d }
+}
+
+// DefaultMaxSessions is the number of sessions an HTTP transport holds when
+// no limit is given.
+const DefaultMaxSessions = 10000
+
+// WithMaxSessions limits the number of sessions the transport holds.
+// Initialize requests beyond the limit are rejected with 503 Service
+// Unavailable until sessions end or expire. Zero removes the limit.
+func WithMaxSessions(n int) HTTPOption {
+	return func(h *httpTransport) { h.maxSessions = n }
+}
+
+// WithEventStore makes event streams resumable: events are assigned IDs
+// and logged in store, and a client reconnecting with a GET request carrying
+// Last-Event-ID is sent the events it missed. Without an event store,
+// messages sent while a client is disconnected are lost.
+func WithEventStore(store EventStore) HTTPOption {
+	return func(h *httpTransport) { h.events = store }
+}
+
+// WithMaxSessionEvents limits the number of events a session keeps in the
+// event store across all of its streams. Past the limit, the events of the
+// session's oldest finished streams are deleted and can no longer be
+// resumed. Zero removes the limit. The default is DefaultMaxEvents.
+func WithMaxSessionEvents(n int) HTTPOption {
+	return func(h *httpTransport) { h.maxEvents = n }
+}
+
+// HTTPHandler returns a Streamable HTTP transport served by mounting it on
+// an existing server, at any path:
+//
+//	tr := transport.HTTPHandler()
+//	mux.Handle("/mcp", tr)
+//	srv := rpc.NewServer(reg, tr)
+//
+// POST requests are answered with a JSON body, or with an event stream when
+// the server sends other messages before the response, and GET requests
+// open an event stream for server-initiated messages sent with Notify.
+//
+// A session is created for every successful "initialize" request and its
+// ID returned in the Mcp-Session-Id header. Later requests must carry that
+// header and are rejected with 404 once the session has been deleted with
+// DELETE or has expired.
+func HTTPHandler(opts ...HTTPOption) HandlerTransport {
+	return newHTTPTransport(opts...)
+}
+
+// ListenHTTP listens on addr, or the listener given with WithListener, and
+// serves an HTTPHandler transport at every path. Errors binding the address
+// are returned immediately; errors from the server afterwards are returned
+// by Next.
+func ListenHTTP(addr string, opts ...HTTPOption) (Transport, error) {
+	tr := newHTTPTransport(opts...)
+	tr.srv = &http.Server{Addr: addr, Handler: tr, TLSConfig: tr.tlsConfig}
+	l := tr.listener
+	if l == nil {
+		var err error
+		if l, err = net.Listen("tcp", addr); err != nil {
+			tr.Close()
+			return nil, err
+		}
+	}
+	tr.serveDone = make(chan struct{})
+	go func() {
+		defer close(tr.serveDone)
+		var err error
+		if tr.tlsConfig != nil || tr.certFile != "" {
+			err = tr.srv.ServeTLS(l, tr.certFile, tr.keyFile)
+		} else {
+			err = tr.srv.Serve(l)
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			tr.serveErr = err
+		}
+	}()
+	return tr, nil
 }
 
 // HTTPTransport returns a Transport that serves JSON-RPC requests over HTTP.
-// It listens on the provided address.
-func HTTPTransport(addr string) Transport {
-	tr := &httpTransport{
-		reqCh: make(chan httpMessage, 16),
-	}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", tr.handle)
-	tr.srv = &http.Server{Addr: addr, Handler: mux}
-	go tr.srv.ListenAndServe()
+// It listens on the provided address. If listening fails, the error is
+// returned by Next; use ListenHTTP to handle it up front.
+func HTTPTransport(addr string, opts ...HTTPOption) Transport {
+	tr, err := ListenHTTP(addr, opts...)
+	if err != nil {
+		return failedTransport{err}
+	}
 	return tr
 }
 
-func (h *httpTransport) handle(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet && r.Header.Get("Accept") == "text/event-stream" {
-		w.Header().Set("Allow", http.MethodPost)
-		http.Error(w, "streaming not allowed on this endpoint", http.StatusMethodNotAllowed)
+// failedTransport is a Transport that could not be started.
+type failedTransport struct{ err error }
+
+func (f failedTransport) Next(context.Context) (Conn, json.RawMessage, error) {
+	return nil, nil, f.err
+}
+
+func (f failedTransport) Close() error { return nil }
+
+func newHTTPTransport(opts ...HTTPOption) *httpTransport {
+	h := &httpTransport{
+		reqCh:       make(chan httpMessage, 16),
+		done:        make(chan struct{}),
+		sessions:    NewMemorySessionStore(),
+		live:        make(map[string]*Session),
+		maxSessions: DefaultMaxSessions,
+		maxEvents:   DefaultMaxEvents,
+		idleTimeout: DefaultSessionIdleTimeout,
+		maxBody:     DefaultMaxRequestSize,
+		guard:       originGuard{methods: "GET, POST, DELETE"},
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.idleTimeout > 0 {
+		go h.expireSessions()
+	}
+	return h
+}
+
+func (h *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.guard.check(w, r) {
 		return
 	}
+	if h.auth != nil {
+		if h.auth.isMetadataRequest(r) {
+			h.auth.metadata.ServeHTTP(w, r)
+			return
+		}
+		claims := h.auth.authenticate(w, r)
+		if claims == nil {
+			return
+		}
+		r = r.WithContext(auth.WithClaims(r.Context(), claims))
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodGet:
+		h.handleGet(w, r)
+	case http.MethodDelete:
+		h.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// session returns the live session named by the request's Mcp-Session-Id
+// header, writing an error response and returning nil if there is none.
+func (h *httpTransport) session(w http.ResponseWriter, r *http.Request) *Session {
+	id := r.Header.Get(SessionHeader)
+	if id == "" {
+		http.Error(w, "missing "+SessionHeader+" header", http.StatusBadRequest)
+		return nil
+	}
+	rec, err := h.sessions.Get(r.Context(), id)
+	if err == nil && h.expired(rec) {
+		h.endSession(r.Context(), id)
+		err = ErrSessionNotFound
+	}
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+	if !ownsSession(r, rec) {
+		http.Error(w, "session belongs to another user", http.StatusForbidden)
+		return nil
+	}
+	sess := h.liveSession(rec)
+	h.touch(r.Context(), sess)
+	return sess
+}
+
+// liveSession returns the session of this process for rec, starting one if
+// the session was created elsewhere or before a restart.
+func (h *httpTransport) liveSession(rec SessionRecord) *Session {
+	h.smu.Lock()
+	defer h.smu.Unlock()
+	if sess := h.live[rec.ID]; sess != nil {
+		return sess
+	}
+	sess := newSession(rec.ID, rec.CreatedAt)
+	sess.subject = rec.Subject
+	sess.events, sess.maxEvents = h.events, h.maxEvents
+	h.live[rec.ID] = sess
+	return sess
+}
+
+var errTooManySessions = errors.New("too many sessions")
+
+// full reports whether the transport holds as many sessions as it may.
+func (h *httpTransport) full() bool {
+	h.smu.Lock()
+	defer h.smu.Unlock()
+	return h.maxSessions > 0 && len(h.live) >= h.maxSessions
+}
+
+// addSession keeps the new session sess.
+func (h *httpTransport) addSession(ctx context.Context, sess *Session) error {
+	h.smu.Lock()
+	if h.maxSessions > 0 && len(h.live) >= h.maxSessions {
+		h.smu.Unlock()
+		return errTooManySessions
+	}
+	h.live[sess.ID] = sess
+	h.smu.Unlock()
+	if err := h.sessions.Put(ctx, sess.record()); err != nil {
+		h.smu.Lock()
+		delete(h.live, sess.ID)
+		h.smu.Unlock()
+		return err
+	}
+	return nil
+}
 
-	body, err := io.ReadAll(r.Body)
+// liveSessions returns the sessions held by this process.
+func (h *httpTransport) liveSessions() []*Session {
+	h.smu.Lock()
+	defer h.smu.Unlock()
+	out := make([]*Session, 0, len(h.live))
+	for _, sess := range h.live {
+		out = append(out, sess)
+	}
+	return out
+}
+
+// touch records activity on sess. A failure to update the store only
+// affects expiry.
+func (h *httpTransport) touch(ctx context.Context, sess *Session) {
+	sess.touch()
+	_ = h.sessions.Put(ctx, sess.record())
+}
+
+// expired reports whether the session rec has been idle for longer than
+// the idle timeout.
+func (h *httpTransport) expired(rec SessionRecord) bool {
+	if h.idleTimeout <= 0 || time.Since(rec.LastActive) <= h.idleTimeout {
+		return false
+	}
+	h.smu.Lock()
+	sess := h.live[rec.ID]
+	h.smu.Unlock()
+	return sess == nil || !sess.busy()
+}
+
+func (h *httpTransport) endSession(ctx context.Context, id string) {
+	h.smu.Lock()
+	sess := h.live[id]
+	delete(h.live, id)
+	h.smu.Unlock()
+	if sess != nil {
+		for _, streamID := range sess.close() {
+			if h.events != nil {
+				_ = h.events.Delete(ctx, streamID)
+			}
+		}
+	}
+	_ = h.sessions.Delete(ctx, id)
+}
+
+// expireSessions periodically removes idle sessions until the transport is
+// closed.
+func (h *httpTransport) expireSessions() {
+	interval := h.idleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx := context.Background()
+			live := h.liveSessions()
+			for _, sess := range live {
+				// keep the records of busy sessions fresh for other
+				// transports sharing the store
+				if sess.busy() {
+					h.touch(ctx, sess)
+				}
+			}
+			recs, err := h.sessions.List(ctx)
+			if err != nil {
+				continue
+			}
+			stored := make(map[string]bool, len(recs))
+			for _, rec := range recs {
+				if h.expired(rec) {
+					h.endSession(ctx, rec.ID)
+					continue
+				}
+				stored[rec.ID] = true
+			}
+			for _, sess := range live {
+				if !stored[sess.ID] {
+					// ended by another transport sharing the store
+					h.endSession(ctx, sess.ID)
+				}
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	h.endSession(r.Context(), sess.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r, h.maxBody)
+	if !ok {
+		return
+	}
+	msgs, batch, err := splitBatch(body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "invalid JSON-RPC message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pending := make(map[string]bool)
+	initialize := false
+	for _, raw := range msgs {
+		m, err := parseMessage(raw)
+		if err != nil {
+			http.Error(w, "invalid JSON-RPC message: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if m.isRequest() {
+			pending[idKey(m.ID)] = true
+		}
+		if m.Method == "initialize" {
+			initialize = true
+		}
+	}
+
+	var sess *Session
+	if initialize {
+		if batch {
+			http.Error(w, "initialize must not be part of a batch", http.StatusBadRequest)
+			return
+		}
+		if h.full() {
+			http.Error(w, errTooManySessions.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		sess = NewSession()
+		sess.events, sess.maxEvents = h.events, h.maxEvents
+		if claims, ok := auth.ClaimsFrom(r.Context()); ok {
+			sess.subject = claims.Subject
+		}
+	} else if sess = h.session(w, r); sess == nil {
+		return
+	}
+	// the session of an initialize request is only kept once it succeeds
+	unkept := initialize
+	keep := func() bool {
+		err := h.addSession(r.Context(), sess)
+		switch {
+		case errors.Is(err, errTooManySessions):
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return false
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return false
+		}
+		unkept = false
+		w.Header().Set(SessionHeader, sess.ID)
+		return true
+	}
+	sess.active.Add(1)
+	defer func() {
+		sess.active.Add(-1)
+		if unkept {
+			sess.close()
+			return
+		}
+		// a long request must not leave its session looking idle
+		h.touch(context.WithoutCancel(r.Context()), sess)
+	}()
+
+	claims, _ := auth.ClaimsFrom(r.Context())
+	conn := newHTTPConn(sess)
+	conn.claims = claims
+	detached := false
+	defer func() {
+		if !detached {
+			close(conn.closed)
+		}
+	}()
+	for _, raw := range msgs {
+		var c Conn = discardConn{session: sess, claims: claims}
+		if m, _ := parseMessage(raw); m.isRequest() {
+			c = conn
+		}
+		if !h.deliver(r.Context(), httpMessage{req: raw, conn: c}) {
+			if len(pending) > 0 {
+				http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+			}
+			return
+		}
+	}
+	if len(pending) == 0 {
+		// only notifications and responses
+		w.WriteHeader(http.StatusAccepted)
 		return
 	}
-	conn := &httpConn{ch: make(chan json.RawMessage, 1)}
-	msg := httpMessage{
-		req:  json.RawMessage(body),
-		conn: conn,
+
+	sse := acceptsEventStream(r)
+	var st *stream
+	var responses []json.RawMessage
+	for len(pending) > 0 {
+		var msg json.RawMessage
+		select {
+		case msg = <-conn.ch:
+		case <-r.Context().Done():
+			if st != nil && h.events != nil {
+				detached = true
+				go h.finishStream(sess, st, conn, pending)
+			}
+			return
+		case <-h.done:
+			return
+		}
+		msg = bytes.TrimSpace(msg)
+		isResp := takeResponse(pending, msg)
+		if st == nil && !isResp && sse {
+			// upgrade to an event stream, flushing anything held back
+			if st = sess.newStream(false); st == nil {
+				http.Error(w, "session not found", http.StatusNotFound)
+				return
+			}
+			if unkept && !keep() {
+				return
+			}
+			startEventStream(w)
+			for _, resp := range responses {
+				_ = h.writeStreamEvent(r.Context(), w, st, resp)
+			}
+			responses = nil
+		}
+		switch {
+		case st != nil:
+			if err := h.writeStreamEvent(r.Context(), w, st, msg); err != nil {
+				if h.events != nil {
+					detached = true
+					go h.finishStream(sess, st, conn, pending)
+				}
+				return
+			}
+		case isResp:
+			responses = append(responses, msg)
+		}
+		// intermediate messages for clients that cannot receive an
+		// event stream are dropped
+	}
+	if st != nil {
+		sess.removeStream(st)
+		return
+	}
+	if unkept && succeeded(responses[0]) && !keep() {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		_ = json.NewEncoder(w).Encode(responses)
+		return
+	}
+	_, _ = w.Write(responses[0])
+}
+
+// readBody decodes the JSON value in the body of r, reading at most max
+// bytes. On failure it writes an error response and reports false; bodies
+// over the limit get 413 with a JSON-RPC error.
+func readBody(w http.ResponseWriter, r *http.Request, max int64) (json.RawMessage, bool) {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, max))
+	var body json.RawMessage
+	err := dec.Decode(&body)
+	if err == nil {
+		if _, terr := dec.Token(); terr != io.EOF {
+			err = errors.New("unexpected data after JSON value")
+		}
+	}
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = w.Write(tooLargeResponse(max))
+		return nil, false
+	case err != nil:
+		http.Error(w, "invalid JSON-RPC message: "+err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return body, true
+}
+
+// takeResponse reports whether msg answers one of the pending requests,
+// removing it from pending if so.
+func takeResponse(pending map[string]bool, msg json.RawMessage) bool {
+	m, _ := parseMessage(msg)
+	if !m.isResponse() || !pending[idKey(m.ID)] {
+		return false
+	}
+	delete(pending, idKey(m.ID))
+	return true
+}
+
+// writeStreamEvent publishes msg on st and writes it to w.
+func (h *httpTransport) writeStreamEvent(ctx context.Context, w http.ResponseWriter, st *stream, msg json.RawMessage) error {
+	// a failure to record the event only affects resumption
+	ev, _ := st.publish(ctx, msg)
+	return writeEvent(w, "message", h.eventID(ev), ev.Data)
+}
+
+// eventID returns the SSE id for ev, which is only sent when streams can
+// be resumed.
+func (h *httpTransport) eventID(ev Event) string {
+	if h.events == nil {
+		return ""
+	}
+	return ev.ID()
+}
+
+// finishStream keeps publishing the messages of a POST whose client went
+// away so that they can be replayed when it reconnects.
+func (h *httpTransport) finishStream(sess *Session, st *stream, conn *httpConn, pending map[string]bool) {
+	defer close(conn.closed)
+	defer sess.removeStream(st)
+	ctx := context.Background()
+	for len(pending) > 0 {
+		select {
+		case msg := <-conn.ch:
+			msg = bytes.TrimSpace(msg)
+			takeResponse(pending, msg)
+			_, _ = st.publish(ctx, msg)
+		case <-st.done:
+			return
+		case <-h.done:
+			return
+		}
 	}
+}
+
+// deliver hands msg to Next. It reports false if the request or transport
+// ended first.
+func (h *httpTransport) deliver(ctx context.Context, msg httpMessage) bool {
 	select {
 	case h.reqCh <- msg:
-	case <-r.Context().Done():
+		return true
+	case <-ctx.Done():
+		return false
+	case <-h.done:
+		return false
+	}
+}
+
+func (h *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "GET requires Accept: text/event-stream", http.StatusMethodNotAllowed)
 		return
 	}
-	resp := <-conn.ch
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(resp)
+	sess := h.session(w, r)
+	if sess == nil {
+		return
+	}
+	defer sess.touch()
+	if last := r.Header.Get("Last-Event-ID"); last != "" && h.events != nil {
+		h.resume(w, r, sess, last)
+		return
+	}
+
+	sess.dropDetachedStandalone(r.Context())
+	st := sess.newStream(true)
+	if st == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if h.events == nil {
+		defer sess.removeStream(st)
+	}
+	sub := st.attach()
+	defer st.detach(sub)
+	startEventStream(w)
+	h.pump(w, r, sess, st, sub, 0)
+}
+
+// resume replays the events of the stream lastEventID belongs to that the
+// client missed and, if the stream is still live, continues it.
+func (h *httpTransport) resume(w http.ResponseWriter, r *http.Request, sess *Session, lastEventID string) {
+	streamID, seq, ok := parseEventID(lastEventID)
+	if !ok || !sess.ownsStream(streamID) {
+		http.Error(w, "unknown Last-Event-ID", http.StatusBadRequest)
+		return
+	}
+	// attach before reading the log so no event falls in between
+	st := sess.stream(streamID)
+	var sub *subscriber
+	if st != nil {
+		sub = st.attach()
+		defer st.detach(sub)
+	}
+	missed, err := h.events.After(r.Context(), streamID, seq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	startEventStream(w)
+	for _, ev := range missed {
+		if err := writeEvent(w, "message", ev.ID(), ev.Data); err != nil {
+			return
+		}
+		seq = ev.Seq
+	}
+	if st != nil {
+		h.pump(w, r, sess, st, sub, seq)
+	}
+}
+
+// pump writes the events sub receives from st after sequence number after
+// until the stream ends or the client goes away.
+func (h *httpTransport) pump(w http.ResponseWriter, r *http.Request, sess *Session, st *stream, sub *subscriber, after int64) {
+	write := func(ev Event) bool {
+		if ev.Seq <= after {
+			return true
+		}
+		return writeEvent(w, "message", h.eventID(ev), ev.Data) == nil
+	}
+	for {
+		select {
+		case ev := <-sub.ch:
+			if !write(ev) {
+				return
+			}
+		case <-st.done:
+			for {
+				select {
+				case ev := <-sub.ch:
+					if !write(ev) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case <-r.Context().Done():
+			return
+		case <-sess.done:
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// Notify sends a server-initiated message to every session's GET event
+// streams. Messages are dropped for sessions that are not listening.
+func (h *httpTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	msg = bytes.TrimSpace(msg)
+	for _, sess := range h.liveSessions() {
+		if err := sess.notify(ctx, msg); err != nil && !errors.Is(err, ErrConnClosed) {
+			return err
+		}
+	}
+	return nil
 }
 
 func (h *httpTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
 	select {
 	case <-ctx.Done():
 		return nil, nil, ctx.Err()
-	case msg, ok := <-h.reqCh:
-		if !ok {
-			return nil, nil, io.EOF
-		}
+	case msg := <-h.reqCh:
 		return msg.conn, msg.req, nil
+	case <-h.done:
+		return nil, nil, io.EOF
+	case <-h.serveDone:
+		if h.serveErr != nil {
+			return nil, nil, h.serveErr
+		}
+		return nil, nil, io.EOF
 	}
 }
 
+// DefaultShutdownTimeout bounds how long Close waits for in-flight HTTP
+// requests to complete.
+const DefaultShutdownTimeout = 5 * time.Second
+
+// Close shuts the transport down, waiting at most DefaultShutdownTimeout.
 func (h *httpTransport) Close() error {
-	return h.srv.Shutdown(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+	return h.Shutdown(ctx)
+}
+
+// Shutdown ends every event stream, and when the transport runs its own
+// server, waits for in-flight requests to write their responses until ctx
+// expires, after which the remaining connections are closed.
+func (h *httpTransport) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	if !h.closed {
+		h.closed = true
+		close(h.done)
+	}
+	h.mu.Unlock()
+	if h.srv == nil {
+		return nil
+	}
+	err := h.srv.Shutdown(ctx)
+	if err != nil {
+		h.srv.Close()
+	}
+	return err
 }
@@ -0,0 +1,101 @@
+package transport_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+func echoRegistry() *registry.Registry {
+	reg := registry.New()
+	registry.RegisterTool(reg, "Echo", func(ctx context.Context, in struct{ Msg string }) (struct{ Msg string }, error) {
+		return in, nil
+	})
+	return reg
+}
+
+func TestHTTPHandlerMounted(t *testing.T) {
+	tr := transport.HTTPHandler()
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", tr)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(echoRegistry(), tr).Run(ctx) }()
+	defer tr.Close()
+
+	resp, err := http.Post(srv.URL+"/mcp", "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if resp.Header.Get(transport.SessionHeader) == "" {
+		t.Fatalf("missing session header")
+	}
+
+	resp, err = http.Post(srv.URL+"/other", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 outside mount point, got %d", resp.StatusCode)
+	}
+}
+
+func TestListenHTTPWithListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	tr, err := transport.ListenHTTP("", transport.WithListener(l))
+	if err != nil {
+		t.Fatalf("ListenHTTP: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(echoRegistry(), tr).Run(ctx) }()
+	defer tr.Close()
+
+	resp, err := http.Post("http://"+l.Addr().String(), "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"result"`) {
+		t.Fatalf("unexpected response %s", body)
+	}
+}
+
+func TestListenHTTPAddressInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+
+	if _, err := transport.ListenHTTP(l.Addr().String()); err == nil {
+		t.Fatalf("expected listen error")
+	}
+	tr := transport.HTTPTransport(l.Addr().String())
+	defer tr.Close()
+	if _, _, err := tr.Next(context.Background()); err == nil || err == io.EOF {
+		t.Fatalf("expected startup error from Next, got %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveEcho answers every request received from tr with its own id,
+// preceded by a progress notification when the method is "slow". "sleep"
+// is answered after 100ms, and requests with "fail":true params with an
+// error.
+func serveEcho(ctx context.Context, tr Transport) {
+	for {
+		conn, raw, err := tr.Next(ctx)
+		if err != nil {
+			return
+		}
+		m, _ := parseMessage(raw)
+		if !m.isRequest() {
+			continue
+		}
+		go func() {
+			switch {
+			case m.Method == "slow":
+				_ = conn.Send(ctx, json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`))
+			case m.Method == "sleep":
+				time.Sleep(100 * time.Millisecond)
+			case strings.Contains(string(raw), `"fail":true`):
+				_ = conn.Send(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":`+string(m.ID)+`,"error":{"code":-32603,"message":"fail"}}`))
+				return
+			}
+			_ = conn.Send(ctx, json.RawMessage(`{"jsonrpc":"2.0","id":`+string(m.ID)+`,"result":{}}`))
+		}()
+	}
+}
+
+func startStreamTest(t *testing.T, opts ...HTTPOption) (*httpTransport, *httptest.Server) {
+	t.Helper()
+	tr := newHTTPTransport(opts...)
+	srv := httptestServer(t, tr)
+	ctx, cancel := context.WithCancel(context.Background())
+	go serveEcho(ctx, tr)
+	t.Cleanup(cancel)
+	return tr, srv
+}
+
+func httptestServer(t *testing.T, tr *httpTransport) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(tr)
+	t.Cleanup(func() {
+		_ = tr.Close()
+		srv.Close()
+	})
+	return srv
+}
+
+// initSession initializes a session and returns its ID.
+func initSession(t *testing.T, url string) string {
+	t.Helper()
+	resp := post(t, url, "", `{"jsonrpc":"2.0","id":0,"method":"initialize"}`)
+	id := resp.Header.Get(SessionHeader)
+	if resp.StatusCode != http.StatusOK || id == "" {
+		t.Fatalf("initialize: status %d, session %q", resp.StatusCode, id)
+	}
+	return id
+}
+
+func post(t *testing.T, url, session, body string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if session != "" {
+		req.Header.Set(SessionHeader, session)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHTTPPostJSONResponse(t *testing.T) {
+	_, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":1,"method":"fast"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Fatalf("unexpected body %s", body)
+	}
+}
+
+func TestHTTPPostBatchResponse(t *testing.T) {
+	_, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	resp := post(t, srv.URL, sess, `[{"jsonrpc":"2.0","id":1,"method":"fast"},{"jsonrpc":"2.0","method":"notifications/initialized"},{"jsonrpc":"2.0","id":2,"method":"fast"}]`)
+	var out []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("unexpected responses %s", out)
+	}
+}
+
+func TestHTTPPostUpgradesToEventStream(t *testing.T) {
+	_, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":"a","method":"slow"}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	events := readEvents(t, resp.Body, 2)
+	if !strings.Contains(events[0], "notifications/progress") || !strings.Contains(events[1], `"id":"a"`) {
+		t.Fatalf("unexpected events %q", events)
+	}
+}
+
+func TestHTTPPostNotificationAccepted(t *testing.T) {
+	_, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPPostRequestTooLarge(t *testing.T) {
+	_, srv := startStreamTest(t, WithMaxRequestSize(64))
+	sess := initSession(t, srv.URL)
+	resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":1,"method":"`+strings.Repeat("x", 100)+`"}`)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), `"code":-32600`) {
+		t.Fatalf("expected JSON-RPC error, got %s", body)
+	}
+
+	resp = post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":1,"method":"fast"} {}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for trailing data, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPGetEventStream(t *testing.T) {
+	tr, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(SessionHeader, sess)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	// headers are flushed once the stream is registered
+	if err := tr.Notify(context.Background(), json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	events := readEvents(t, resp.Body, 1)
+	if !strings.Contains(events[0], "list_changed") {
+		t.Fatalf("unexpected events %q", events)
+	}
+}
+
+func TestHTTPSessions(t *testing.T) {
+	_, srv := startStreamTest(t)
+	sess := initSession(t, srv.URL)
+	if other := initSession(t, srv.URL); other == sess {
+		t.Fatalf("session ids must be unique")
+	}
+	body := `{"jsonrpc":"2.0","id":1,"method":"fast"}`
+	if resp := post(t, srv.URL, "", body); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("missing session: unexpected status %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL, "unknown", body); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown session: unexpected status %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	req.Header.Set(SessionHeader, sess)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: unexpected status %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL, sess, body); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("deleted session: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSessionExpiry(t *testing.T) {
+	_, srv := startStreamTest(t, WithSessionIdleTimeout(time.Millisecond))
+	sess := initSession(t, srv.URL)
+	time.Sleep(5 * time.Millisecond)
+	if resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":1,"method":"fast"}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expired session: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSessionKeptAfterInitialize(t *testing.T) {
+	tr, srv := startStreamTest(t, WithMaxSessions(1))
+	resp := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"fail":true}}`)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(SessionHeader) != "" {
+		t.Fatalf("failed initialize: status %d, session %q", resp.StatusCode, resp.Header.Get(SessionHeader))
+	}
+	if recs, _ := tr.sessions.List(context.Background()); len(recs) != 0 || len(tr.liveSessions()) != 0 {
+		t.Fatalf("failed initialize kept a session: %+v", recs)
+	}
+
+	initSession(t, srv.URL)
+	if resp := post(t, srv.URL, "", `{"jsonrpc":"2.0","id":0,"method":"initialize"}`); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("initialize beyond the limit: unexpected status %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSessionTouchedOnResponse(t *testing.T) {
+	_, srv := startStreamTest(t, WithSessionIdleTimeout(50*time.Millisecond))
+	sess := initSession(t, srv.URL)
+	// the request outlasts the idle timeout
+	if resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":1,"method":"sleep"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("sleep: unexpected status %d", resp.StatusCode)
+	}
+	if resp := post(t, srv.URL, sess, `{"jsonrpc":"2.0","id":2,"method":"fast"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("session expired during a request: status %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPSharedSessionStore(t *testing.T) {
+	store := NewMemorySessionStore()
+	_, a := startStreamTest(t, WithSessionStore(store))
+	_, b := startStreamTest(t, WithSessionStore(store))
+	sess := initSession(t, a.URL)
+	body := `{"jsonrpc":"2.0","id":1,"method":"fast"}`
+	if resp := post(t, b.URL, sess, body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("session created by another transport: unexpected status %d", resp.StatusCode)
+	}
+	rec, err := store.Get(context.Background(), sess)
+	if err != nil || rec.ID != sess || rec.LastActive.Before(rec.CreatedAt) {
+		t.Fatalf("unexpected record %+v: %v", rec, err)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, b.URL, nil)
+	req.Header.Set(SessionHeader, sess)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp.Body.Close()
+	if resp := post(t, a.URL, sess, body); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("session deleted by another transport: unexpected status %d", resp.StatusCode)
+	}
+}
+
+// readEvents returns the data of the next n server-sent events in r.
+func readEvents(t *testing.T, r io.Reader, n int) []string {
+	t.Helper()
+	_, data := readEventIDs(t, r, n)
+	return data
+}
+
+// readEventIDs returns the ids and data of the next n server-sent events
+// in r.
+func readEventIDs(t *testing.T, r io.Reader, n int) (ids, events []string) {
+	t.Helper()
+	var id string
+	var data strings.Builder
+	sc := bufio.NewScanner(r)
+	for len(events) < n && sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data.WriteString(strings.TrimPrefix(line, "data: "))
+		case line == "" && data.Len() > 0:
+			ids = append(ids, id)
+			events = append(events, data.String())
+			id = ""
+			data.Reset()
+		}
+	}
+	if len(events) < n {
+		t.Fatalf("expected %d events, got %q (%v)", n, events, sc.Err())
+	}
+	return ids, events
+}
@@ -0,0 +1,211 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// HandlerTransport is a Transport that receives its messages through an
+// http.Handler the caller mounts on its own server.
+type HandlerTransport interface {
+	Transport
+	http.Handler
+}
+
+type sseTransport struct {
+	endpoint string
+	guard    originGuard
+	maxBody  int64
+	reqCh    chan httpMessage
+	done     chan struct{}
+
+	mu      sync.Mutex
+	clients map[string]*sseConn // by session ID
+	closed  bool
+}
+
+// sseConn sends messages on the event stream of a legacy SSE session.
+type sseConn struct {
+	session *Session
+	stream  *stream
+}
+
+func (c *sseConn) Session() *Session { return c.session }
+
+func (c *sseConn) Send(ctx context.Context, resp json.RawMessage) error {
+	select {
+	case <-c.stream.done:
+		return ErrConnClosed
+	default:
+	}
+	_, err := c.stream.publish(ctx, bytes.TrimSpace(resp))
+	return err
+}
+
+// SSEOption configures the transport returned by SSETransport.
+type SSEOption func(*sseTransport)
+
+// WithSSEMaxRequestSize sets the largest message body accepted. Larger
+// bodies are rejected with 413 Request Entity Too Large.
+func WithSSEMaxRequestSize(n int64) SSEOption {
+	return func(t *sseTransport) { t.maxBody = n }
+}
+
+// SSETransport returns a Transport implementing the HTTP+SSE transport of
+// protocol version 2024-11-05, for clients that predate Streamable HTTP.
+//
+// The returned handler must be mounted both where clients open their event
+// stream (typically "/sse") and at endpoint, the URL path clients POST their
+// messages to (typically "/message"). A GET request opens a session whose
+// first event, "endpoint", tells the client where to post; responses are
+// delivered as "message" events on the stream. Browser requests are only
+// accepted from loopback origins.
+func SSETransport(endpoint string, opts ...SSEOption) HandlerTransport {
+	t := &sseTransport{
+		endpoint: endpoint,
+		guard:    originGuard{methods: "GET, POST"},
+		maxBody:  DefaultMaxRequestSize,
+		reqCh:    make(chan httpMessage, 16),
+		done:     make(chan struct{}),
+		clients:  make(map[string]*sseConn),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *sseTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !t.guard.check(w, r) {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		t.handleStream(w, r)
+	case http.MethodPost:
+		t.handleMessage(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (t *sseTransport) handleStream(w http.ResponseWriter, r *http.Request) {
+	sess := NewSession()
+	st := sess.newStream(true)
+	sub := st.attach()
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	t.clients[sess.ID] = &sseConn{session: sess, stream: st}
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.clients, sess.ID)
+		t.mu.Unlock()
+		st.detach(sub)
+		sess.close()
+	}()
+
+	endpoint, err := url.Parse(t.endpoint)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := endpoint.Query()
+	q.Set("sessionId", sess.ID)
+	endpoint.RawQuery = q.Encode()
+
+	startEventStream(w)
+	if err := writeEvent(w, "endpoint", "", []byte(endpoint.String())); err != nil {
+		return
+	}
+	for {
+		select {
+		case ev := <-sub.ch:
+			if err := writeEvent(w, "message", "", ev.Data); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *sseTransport) handleMessage(w http.ResponseWriter, r *http.Request) {
+	t.mu.Lock()
+	conn := t.clients[r.URL.Query().Get("sessionId")]
+	t.mu.Unlock()
+	if conn == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	body, ok := readBody(w, r, t.maxBody)
+	if !ok {
+		return
+	}
+	msgs, _, err := splitBatch(body)
+	if err != nil {
+		http.Error(w, "invalid JSON-RPC message: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	conn.session.touch()
+	for _, raw := range msgs {
+		select {
+		case t.reqCh <- httpMessage{req: raw, conn: conn}:
+		case <-r.Context().Done():
+			return
+		case <-t.done:
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Notify sends a server-initiated message to every connected client.
+func (t *sseTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	clients := make([]*sseConn, 0, len(t.clients))
+	for _, c := range t.clients {
+		clients = append(clients, c)
+	}
+	t.mu.Unlock()
+	for _, c := range clients {
+		if err := c.Send(ctx, msg); err != nil && err != ErrConnClosed {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sseTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case msg := <-t.reqCh:
+		return msg.conn, msg.req, nil
+	case <-t.done:
+		return nil, nil, io.EOF
+	}
+}
+
+func (t *sseTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.done)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package transport_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// startSSE serves reg over the legacy SSE transport and opens its event
+// stream, returning the server URL, the message endpoint and a function
+// reading the next event.
+func startSSE(t *testing.T, reg *registry.Registry, opts ...transport.SSEOption) (url, endpoint string, next func() (event, data string)) {
+	t.Helper()
+	tr := transport.SSETransport("/message", opts...)
+	mux := http.NewServeMux()
+	mux.Handle("/sse", tr)
+	mux.Handle("/message", tr)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() { _ = rpc.NewServer(reg, transport.Multi(tr)).Run(ctx) }()
+	t.Cleanup(func() { tr.Close() })
+
+	resp, err := http.Get(srv.URL + "/sse")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	events := bufio.NewScanner(resp.Body)
+	next = func() (event, data string) {
+		t.Helper()
+		for events.Scan() {
+			line := events.Text()
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && data != "":
+				return event, data
+			}
+		}
+		t.Fatalf("stream ended: %v", events.Err())
+		return "", ""
+	}
+
+	event, endpoint := next()
+	if event != "endpoint" || !strings.HasPrefix(endpoint, "/message?sessionId=") {
+		t.Fatalf("unexpected endpoint event %q %q", event, endpoint)
+	}
+	return srv.URL, endpoint, next
+}
+
+// postSSE posts msg to the message endpoint of a legacy SSE stream.
+func postSSE(t *testing.T, url, msg string) {
+	t.Helper()
+	post, err := http.Post(url, "application/json", strings.NewReader(msg))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status %d", post.StatusCode)
+	}
+}
+
+func TestSSETransportEndToEnd(t *testing.T) {
+	reg := registry.New()
+	registry.RegisterTool(reg, "Echo", func(ctx context.Context, in struct{ Msg string }) (struct{ Msg string }, error) {
+		return in, nil
+	})
+	url, endpoint, next := startSSE(t, reg)
+
+	postSSE(t, url+endpoint, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Echo","arguments":{"Msg":"hi"}}}`)
+	event, data := next()
+	if event != "message" || !strings.Contains(data, `"id":1`) || !strings.Contains(data, `"Msg":"hi"`) {
+		t.Fatalf("unexpected message event %q %q", event, data)
+	}
+}
+
+func TestSSETransportInitialize20241105(t *testing.T) {
+	url, endpoint, next := startSSE(t, registry.New())
+
+	postSSE(t, url+endpoint, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05","capabilities":{},"clientInfo":{"name":"old","version":"1"}}}`)
+	_, data := next()
+	var resp struct {
+		Result rpc.InitializeResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if resp.Result.ProtocolVersion != "2024-11-05" {
+		t.Fatalf("negotiated %q, want 2024-11-05", resp.Result.ProtocolVersion)
+	}
+	postSSE(t, url+endpoint, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	postSSE(t, url+endpoint, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if _, data := next(); !strings.Contains(data, `"id":2`) {
+		t.Fatalf("unexpected ping response %s", data)
+	}
+}
+
+func TestSSETransportMaxRequestSize(t *testing.T) {
+	url, endpoint, next := startSSE(t, registry.New(), transport.WithSSEMaxRequestSize(64))
+
+	long := `{"jsonrpc":"2.0","id":1,"method":"` + strings.Repeat("x", 100) + `"}`
+	post, err := http.Post(url+endpoint, "application/json", strings.NewReader(long))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	post.Body.Close()
+	if post.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d", post.StatusCode)
+	}
+	postSSE(t, url+endpoint, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if _, data := next(); !strings.Contains(data, `"id":2`) {
+		t.Fatalf("unexpected message %q", data)
+	}
+}
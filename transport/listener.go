@@ -0,0 +1,196 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+type listenerTransport struct {
+	l       net.Listener
+	maxLine int
+	reqCh   chan httpMessage
+	done    chan struct{}
+
+	acceptDone chan struct{}
+	acceptErr  error
+	wg         sync.WaitGroup
+
+	mu     sync.Mutex
+	conns  map[*lineConn]struct{}
+	closed bool
+}
+
+// lineConn is a client connection carrying newline-delimited messages.
+type lineConn struct {
+	nc      net.Conn
+	session *Session
+
+	wmu sync.Mutex
+}
+
+func (c *lineConn) Session() *Session { return c.session }
+
+func (c *lineConn) Send(ctx context.Context, msg json.RawMessage) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.nc.Write(append(bytes.TrimSpace(msg), '\n'))
+	if errors.Is(err, net.ErrClosed) {
+		return ErrConnClosed
+	}
+	return err
+}
+
+// ListenerOption configures the transport returned by ListenerTransport.
+type ListenerOption func(*listenerTransport)
+
+// WithListenerMaxLineSize sets the largest message read from a client.
+// Longer lines are discarded without being buffered and answered with an
+// Invalid Request error.
+func WithListenerMaxLineSize(n int) ListenerOption {
+	return func(t *listenerTransport) { t.maxLine = n }
+}
+
+// ListenerTransport returns a Transport that accepts clients on l. Each
+// connection carries newline-delimited JSON-RPC messages, like
+// StdioTransport, and has its own Session. Close stops accepting, closes l
+// and disconnects every client.
+func ListenerTransport(l net.Listener, opts ...ListenerOption) Transport {
+	t := &listenerTransport{
+		l:          l,
+		maxLine:    DefaultMaxLineSize,
+		reqCh:      make(chan httpMessage, 16),
+		done:       make(chan struct{}),
+		acceptDone: make(chan struct{}),
+		conns:      make(map[*lineConn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	go t.accept()
+	return t
+}
+
+// ListenUnix listens on the Unix domain socket at path and returns a
+// ListenerTransport for it. The socket file is removed by Close.
+func ListenUnix(path string, opts ...ListenerOption) (Transport, error) {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return ListenerTransport(l, opts...), nil
+}
+
+func (t *listenerTransport) accept() {
+	defer close(t.acceptDone)
+	for {
+		nc, err := t.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				t.acceptErr = err
+			}
+			return
+		}
+		c := &lineConn{nc: nc, session: NewSession()}
+		t.mu.Lock()
+		if t.closed {
+			t.mu.Unlock()
+			nc.Close()
+			return
+		}
+		t.conns[c] = struct{}{}
+		t.wg.Add(1)
+		t.mu.Unlock()
+		go t.serve(c)
+	}
+}
+
+func (t *listenerTransport) serve(c *lineConn) {
+	defer t.wg.Done()
+	defer func() {
+		t.mu.Lock()
+		delete(t.conns, c)
+		t.mu.Unlock()
+		c.nc.Close()
+		c.session.close()
+	}()
+	r := bufio.NewReader(c.nc)
+	for {
+		line, err := readLine(r, t.maxLine)
+		if errors.Is(err, errLineTooLong) {
+			_ = c.Send(context.Background(), tooLargeResponse(int64(t.maxLine)))
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.session.touch()
+			select {
+			case t.reqCh <- httpMessage{req: line, conn: c}:
+			case <-t.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Notify sends a server-initiated message to every connected client.
+func (t *listenerTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	conns := make([]*lineConn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		if err := c.Send(ctx, msg); err != nil && err != ErrConnClosed {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *listenerTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case msg := <-t.reqCh:
+		return msg.conn, msg.req, nil
+	case <-t.done:
+		return nil, nil, io.EOF
+	case <-t.acceptDone:
+		if t.acceptErr != nil {
+			return nil, nil, t.acceptErr
+		}
+		return nil, nil, io.EOF
+	}
+}
+
+// Close stops accepting clients, disconnects the connected ones and waits
+// for their goroutines to finish.
+func (t *listenerTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	err := t.l.Close()
+	for c := range t.conns {
+		c.nc.Close()
+	}
+	t.mu.Unlock()
+	<-t.acceptDone
+	t.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return err
+}
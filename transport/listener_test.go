@@ -0,0 +1,112 @@
+package transport_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+func TestListenUnixConcurrentClients(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mcp.sock")
+	tr, err := transport.ListenUnix(path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = rpc.NewServer(echoRegistry(), tr).Run(ctx) }()
+	defer tr.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c, err := net.Dial("unix", path)
+			if err != nil {
+				t.Errorf("dial: %v", err)
+				return
+			}
+			defer c.Close()
+			msg := fmt.Sprintf("client-%d", i)
+			fmt.Fprintf(c, `{"jsonrpc":"2.0","id":%d,"method":"tools/call","params":{"name":"Echo","arguments":{"Msg":%q}}}`+"\n", i, msg)
+			line, err := bufio.NewReader(c).ReadString('\n')
+			if err != nil {
+				t.Errorf("read: %v", err)
+				return
+			}
+			if !strings.Contains(line, msg) {
+				t.Errorf("client %d got %s", i, line)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestListenerTransportClose(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	tr := transport.ListenerTransport(l)
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	fmt.Fprintln(c, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	conn, _, err := tr.Next(context.Background())
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if sc, ok := conn.(transport.SessionConn); !ok || sc.Session() == nil {
+		t.Fatalf("connection has no session")
+	}
+
+	if err := tr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := c.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expected client to be disconnected, got %v", err)
+	}
+	if _, _, err := tr.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected EOF after close, got %v", err)
+	}
+	if _, err := net.Dial("tcp", l.Addr().String()); err == nil {
+		t.Fatalf("listener still accepting")
+	}
+}
+
+func TestListenerTransportMaxLineSize(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	tr := transport.ListenerTransport(l, transport.WithListenerMaxLineSize(64))
+	defer tr.Close()
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	fmt.Fprintln(c, `{"jsonrpc":"2.0","id":1,"method":"`+strings.Repeat("x", 100)+`"}`)
+	fmt.Fprintln(c, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+
+	line, err := bufio.NewReader(c).ReadString('\n')
+	if err != nil || !strings.Contains(line, `"code":-32600`) {
+		t.Fatalf("expected invalid request error, got %q: %v", line, err)
+	}
+	_, req, err := tr.Next(context.Background())
+	if err != nil || !strings.Contains(string(req), `"id":2`) {
+		t.Fatalf("expected the oversized line to be skipped, got %s: %v", req, err)
+	}
+}
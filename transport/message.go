@@ -0,0 +1,86 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrConnClosed is returned by Conn.Send once the underlying stream has
+// ended, for example because the HTTP client went away.
+var ErrConnClosed = errors.New("transport: connection closed")
+
+// message is the subset of a JSON-RPC message inspected by transports.
+type message struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+}
+
+func parseMessage(raw json.RawMessage) (message, error) {
+	var m message
+	err := json.Unmarshal(raw, &m)
+	return m, err
+}
+
+// isRequest reports whether m expects a response.
+func (m message) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+// isResponse reports whether m answers an earlier request.
+func (m message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// idKey returns a canonical form of a JSON-RPC id suitable as a map key.
+func idKey(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
+
+// splitBatch splits a request body into its JSON-RPC messages. batch reports
+// whether body was a JSON array.
+func splitBatch(body []byte) (msgs []json.RawMessage, batch bool, err error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &msgs); err != nil {
+			return nil, true, err
+		}
+		if len(msgs) == 0 {
+			return nil, true, errors.New("empty batch")
+		}
+		return msgs, true, nil
+	}
+	if !json.Valid(trimmed) {
+		return nil, false, errors.New("invalid JSON")
+	}
+	return []json.RawMessage{json.RawMessage(trimmed)}, false, nil
+}
+
+// tooLargeResponse is the Invalid Request error sent for a message larger
+// than max bytes. Its ID cannot be known without reading the message.
+func tooLargeResponse(max int64) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      nil,
+		"error": map[string]any{
+			"code":    -32600,
+			"message": fmt.Sprintf("message exceeds %d bytes", max),
+		},
+	})
+	return data
+}
+
+// succeeded reports whether the response msg carries a result rather than
+// an error.
+func succeeded(msg json.RawMessage) bool {
+	var m struct {
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+	}
+	return json.Unmarshal(msg, &m) == nil && m.Result != nil && m.Error == nil
+}
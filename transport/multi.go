@@ -0,0 +1,114 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+)
+
+type multiTransport struct {
+	transports []Transport
+
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	msgs   chan multiMessage
+	errs   chan error
+	live   int
+}
+
+type multiMessage struct {
+	conn Conn
+	req  json.RawMessage
+}
+
+// Multi returns a Transport that receives messages from all of transports,
+// so that a single server can serve clients of several transports at once,
+// for example Streamable HTTP and legacy SSE clients on the same mux. Next
+// fails once every transport has failed, with the last error. Notify and
+// Close are forwarded to every transport.
+func Multi(transports ...Transport) Transport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &multiTransport{
+		transports: transports,
+		ctx:        ctx,
+		cancel:     cancel,
+		msgs:       make(chan multiMessage),
+		errs:       make(chan error, len(transports)),
+		live:       len(transports),
+	}
+}
+
+func (m *multiTransport) start() {
+	for _, tr := range m.transports {
+		go func(tr Transport) {
+			for {
+				conn, req, err := tr.Next(m.ctx)
+				if err != nil {
+					m.errs <- err
+					return
+				}
+				select {
+				case m.msgs <- multiMessage{conn: conn, req: req}:
+				case <-m.ctx.Done():
+					m.errs <- m.ctx.Err()
+					return
+				}
+			}
+		}(tr)
+	}
+}
+
+func (m *multiTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	m.once.Do(m.start)
+	for {
+		if m.live == 0 {
+			return nil, nil, errors.New("transport: no transports left")
+		}
+		select {
+		case msg := <-m.msgs:
+			return msg.conn, msg.req, nil
+		case err := <-m.errs:
+			m.live--
+			if m.live == 0 {
+				return nil, nil, err
+			}
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}
+}
+
+func (m *multiTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	var errs []error
+	for _, tr := range m.transports {
+		if n, ok := tr.(Notifier); ok {
+			errs = append(errs, n.Notify(ctx, msg))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *multiTransport) Close() error {
+	m.cancel()
+	var errs []error
+	for _, tr := range m.transports {
+		errs = append(errs, tr.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown shuts down every transport, gracefully where supported.
+func (m *multiTransport) Shutdown(ctx context.Context) error {
+	m.cancel()
+	var errs []error
+	for _, tr := range m.transports {
+		if sd, ok := tr.(Shutdowner); ok {
+			errs = append(errs, sd.Shutdown(ctx))
+		} else {
+			errs = append(errs, tr.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
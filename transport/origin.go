@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures cross-origin resource sharing for browser clients. CORS
+// headers are only sent to origins allowed by the transport.
+type CORS struct {
+	// AllowedHeaders are the request headers browsers may send, in addition
+	// to the ones used by the protocol.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers scripts may read, in addition
+	// to Mcp-Session-Id and WWW-Authenticate.
+	ExposedHeaders []string
+	// MaxAge is how long browsers may cache preflight results.
+	MaxAge time.Duration
+	// AllowCredentials lets browsers send cookies and HTTP authentication.
+	AllowCredentials bool
+}
+
+// corsHeaders are the request headers allowed for every CORS client.
+var corsHeaders = []string{"Content-Type", "Accept", "Authorization", SessionHeader, "Last-Event-ID", "Mcp-Protocol-Version"}
+
+// originGuard protects an HTTP transport against cross-site and DNS
+// rebinding attacks by validating the Origin and Host headers.
+//
+// Requests without an Origin header come from non-browser clients and are
+// allowed. Browser requests are allowed from loopback origins and from the
+// configured origins; "*" allows every origin. When hosts are configured,
+// the Host header must name one of them, which defeats DNS rebinding of a
+// server bound to localhost.
+type originGuard struct {
+	origins map[string]bool
+	hosts   []string
+	cors    *CORS
+	methods string
+}
+
+// check validates r and answers CORS preflight requests. It reports false if
+// it wrote a response.
+func (g *originGuard) check(w http.ResponseWriter, r *http.Request) bool {
+	if len(g.hosts) > 0 && !g.allowedHost(r.Host) {
+		http.Error(w, "forbidden: invalid Host header", http.StatusForbidden)
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if !g.allowedOrigin(origin) {
+		http.Error(w, "forbidden: origin not allowed", http.StatusForbidden)
+		return false
+	}
+	if g.cors == nil {
+		return true
+	}
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Allow-Origin", origin)
+	if g.cors.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		h.Set("Access-Control-Expose-Headers", strings.Join(append([]string{SessionHeader, "WWW-Authenticate"}, g.cors.ExposedHeaders...), ", "))
+		return true
+	}
+	// preflight
+	h.Set("Access-Control-Allow-Methods", g.methods)
+	headers := append(append([]string(nil), corsHeaders...), g.cors.AllowedHeaders...)
+	h.Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if g.cors.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(g.cors.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return false
+}
+
+func (g *originGuard) allowedOrigin(origin string) bool {
+	if g.origins["*"] || g.origins[strings.ToLower(origin)] {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return isLoopback(u.Hostname())
+}
+
+func (g *originGuard) allowedHost(host string) bool {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	for _, allowed := range g.hosts {
+		allowed = strings.Trim(allowed, "[]")
+		if strings.EqualFold(allowed, host) || strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *originGuard) allowOrigins(origins []string) {
+	if g.origins == nil {
+		g.origins = make(map[string]bool)
+	}
+	for _, o := range origins {
+		g.origins[strings.ToLower(strings.TrimSuffix(o, "/"))] = true
+	}
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// LocalhostHosts are the Host header values of a server bound to the
+// loopback interface, for use with WithAllowedHosts.
+var LocalhostHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// WithAllowedOrigins allows browser requests from the given origins, such
+// as "https://console.example.com", in addition to loopback origins. "*"
+// allows every origin.
+func WithAllowedOrigins(origins ...string) HTTPOption {
+	return func(h *httpTransport) { h.guard.allowOrigins(origins) }
+}
+
+// WithAllowedHosts rejects requests whose Host header does not name one of
+// hosts, with or without a port. Servers bound to localhost should use
+// LocalhostHosts to defeat DNS rebinding.
+func WithAllowedHosts(hosts ...string) HTTPOption {
+	return func(h *httpTransport) { h.guard.hosts = append(h.guard.hosts, hosts...) }
+}
+
+// WithCORS enables CORS responses, including preflight requests, for the
+// allowed origins.
+func WithCORS(c CORS) HTTPOption {
+	return func(h *httpTransport) { h.guard.cors = &c }
+}
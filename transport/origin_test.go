@@ -0,0 +1,118 @@
+package transport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func originRequest(t *testing.T, method, url, origin string, header map[string]string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, strings.NewReader(`{"jsonrpc":"2.0","id":0,"method":"initialize"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHTTPOriginValidation(t *testing.T) {
+	_, srv := startStreamTest(t, WithAllowedOrigins("https://console.example.com"))
+
+	for origin, want := range map[string]int{
+		"":                            http.StatusOK,
+		"http://localhost:3000":       http.StatusOK,
+		"http://127.0.0.1":            http.StatusOK,
+		"https://console.example.com": http.StatusOK,
+		"https://evil.example.com":    http.StatusForbidden,
+		"null":                        http.StatusForbidden,
+	} {
+		if resp := originRequest(t, http.MethodPost, srv.URL, origin, nil); resp.StatusCode != want {
+			t.Fatalf("origin %q: expected %d, got %d", origin, want, resp.StatusCode)
+		}
+	}
+}
+
+func TestHTTPAllowedHosts(t *testing.T) {
+	_, srv := startStreamTest(t, WithAllowedHosts(LocalhostHosts...))
+
+	if resp := originRequest(t, http.MethodPost, srv.URL, "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected loopback host to be allowed, got %d", resp.StatusCode)
+	}
+	// a rebound DNS name pointing at the loopback address
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{}`))
+	req.Host = "attacker.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 for foreign host, got %d", resp.StatusCode)
+	}
+}
+
+func TestHTTPCORS(t *testing.T) {
+	_, srv := startStreamTest(t,
+		WithAllowedOrigins("https://console.example.com"),
+		WithCORS(CORS{MaxAge: time.Hour}))
+
+	resp := originRequest(t, http.MethodOptions, srv.URL, "https://console.example.com", map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "content-type, mcp-session-id",
+	})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("preflight: unexpected status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://console.example.com" {
+		t.Fatalf("preflight: unexpected allow origin %q", got)
+	}
+	if !strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), SessionHeader) ||
+		!strings.Contains(resp.Header.Get("Access-Control-Allow-Methods"), "DELETE") ||
+		resp.Header.Get("Access-Control-Max-Age") != "3600" {
+		t.Fatalf("preflight: unexpected headers %v", resp.Header)
+	}
+
+	resp = originRequest(t, http.MethodPost, srv.URL, "https://console.example.com", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if !strings.Contains(resp.Header.Get("Access-Control-Expose-Headers"), SessionHeader) {
+		t.Fatalf("session header not exposed: %v", resp.Header)
+	}
+
+	resp = originRequest(t, http.MethodOptions, srv.URL, "https://evil.example.com", map[string]string{
+		"Access-Control-Request-Method": "POST",
+	})
+	if resp.StatusCode != http.StatusForbidden || resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight from foreign origin: status %d, headers %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestWebSocketOriginValidation(t *testing.T) {
+	tr := WebSocketTransport()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	defer tr.Close()
+
+	resp := originRequest(t, http.MethodGet, srv.URL, "https://evil.example.com", map[string]string{
+		"Connection":            "Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	})
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", resp.StatusCode)
+	}
+}
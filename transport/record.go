@@ -0,0 +1,136 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/cyrusaf/mcp/auth"
+)
+
+// Directions of recorded messages.
+const (
+	// DirectionIn marks messages received from clients.
+	DirectionIn = "in"
+	// DirectionOut marks messages sent to clients.
+	DirectionOut = "out"
+)
+
+// Record is a message captured by Recording, written as one line of JSON.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	// Session is the ID of the session the message belongs to, if the
+	// transport has sessions. Messages sent to every client with the
+	// transport's Notify have none.
+	Session string          `json:"session,omitempty"`
+	Message json.RawMessage `json:"message"`
+}
+
+type recordingTransport struct {
+	tr Transport
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// Recording returns a Transport that passes every message received from
+// and sent through tr to the caller unchanged, and writes each as a Record
+// to w. The records can be fed to a server again with the replay package.
+// An HTTP transport must still be mounted as the handler itself.
+func Recording(tr Transport, w io.Writer) Transport {
+	return &recordingTransport{tr: tr, enc: json.NewEncoder(w)}
+}
+
+// record writes msg to the log. The first write error is kept and returned
+// by Close.
+func (r *recordingTransport) record(dir string, sess *Session, msg json.RawMessage) {
+	rec := Record{Time: time.Now().UTC(), Direction: dir, Message: compact(msg)}
+	if sess != nil {
+		rec.Session = sess.ID
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = r.enc.Encode(rec)
+	}
+}
+
+// compact returns msg on a single line, as JSON Lines requires.
+func compact(msg json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, msg); err != nil {
+		// keep the invalid message visible as a string
+		b, _ := json.Marshal(string(msg))
+		return b
+	}
+	return buf.Bytes()
+}
+
+func (r *recordingTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	conn, msg, err := r.tr.Next(ctx)
+	if err != nil {
+		return conn, msg, err
+	}
+	rc := &recordingConn{Conn: conn, r: r}
+	if sc, ok := conn.(SessionConn); ok {
+		rc.session = sc.Session()
+	}
+	if sess := rc.session; sess != nil {
+		// messages sent with Session.Notify bypass the connection
+		sess.observe(r, func(msg json.RawMessage) { r.record(DirectionOut, sess, msg) })
+	}
+	r.record(DirectionIn, rc.session, msg)
+	return rc, msg, nil
+}
+
+func (r *recordingTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	n, ok := r.tr.(Notifier)
+	if !ok {
+		return nil
+	}
+	r.record(DirectionOut, nil, msg)
+	return n.Notify(ctx, msg)
+}
+
+func (r *recordingTransport) Close() error {
+	err := r.tr.Close()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return errors.Join(err, r.err)
+}
+
+// Shutdown shuts tr down, gracefully if it supports it.
+func (r *recordingTransport) Shutdown(ctx context.Context) error {
+	if sd, ok := r.tr.(Shutdowner); ok {
+		return sd.Shutdown(ctx)
+	}
+	return r.tr.Close()
+}
+
+// recordingConn records the messages sent on a connection. It keeps the
+// session and claims of the wrapped connection visible to the server.
+type recordingConn struct {
+	Conn
+	r       *recordingTransport
+	session *Session
+}
+
+func (c *recordingConn) Send(ctx context.Context, msg json.RawMessage) error {
+	c.r.record(DirectionOut, c.session, msg)
+	return c.Conn.Send(ctx, msg)
+}
+
+func (c *recordingConn) Session() *Session { return c.session }
+
+func (c *recordingConn) Claims() *auth.Claims {
+	if cc, ok := c.Conn.(ClaimsConn); ok {
+		return cc.Claims()
+	}
+	return nil
+}
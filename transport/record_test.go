@@ -0,0 +1,160 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cyrusaf/mcp/registry"
+	"github.com/cyrusaf/mcp/rpc"
+	"github.com/cyrusaf/mcp/transport"
+)
+
+func TestRecording(t *testing.T) {
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"Echo","arguments":{"Msg":"hi"}}}
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+`)
+	var out, log bytes.Buffer
+	tr := transport.Recording(transport.NewStdioTransport(in, &out), &log)
+	if err := rpc.NewServer(echoRegistry(), tr).Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := tr.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	recs, err := loadRecords(&log)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %d:\n%s", len(recs), log.String())
+	}
+	var dirs []string
+	for _, rec := range recs {
+		if rec.Time.IsZero() || rec.Session != "" {
+			t.Fatalf("unexpected record %+v", rec)
+		}
+		dirs = append(dirs, rec.Direction)
+	}
+	// the response may be written before or after the notification is read
+	if strings.Count(strings.Join(dirs, " "), transport.DirectionIn) != 2 || dirs[0] != transport.DirectionIn {
+		t.Fatalf("unexpected directions %v", dirs)
+	}
+	for _, rec := range recs {
+		if rec.Direction == transport.DirectionOut {
+			if strings.TrimSpace(out.String()) != string(rec.Message) {
+				t.Fatalf("recorded %s, sent %s", rec.Message, out.String())
+			}
+			if !strings.Contains(string(rec.Message), `\"Msg\":\"hi\"`) {
+				t.Fatalf("unexpected response %s", rec.Message)
+			}
+		}
+	}
+}
+
+func TestRecordingSessions(t *testing.T) {
+	tr := transport.HTTPHandler()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	var log bytes.Buffer
+	rec := transport.Recording(tr, &log)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = rpc.NewServer(echoRegistry(), rec).Run(ctx)
+		close(done)
+	}()
+
+	resp, err := http.Post(srv.URL, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	id := resp.Header.Get(transport.SessionHeader)
+	cancel()
+	<-done
+	rec.Close()
+
+	recs, err := loadRecords(&log)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(recs) != 2 {
+		t.Fatalf("expected 2 records, got %d:\n%s", len(recs), log.String())
+	}
+	for _, r := range recs {
+		if r.Session != id {
+			t.Fatalf("recorded session %q, want %q", r.Session, id)
+		}
+	}
+}
+
+func TestRecordingSessionNotify(t *testing.T) {
+	tr := transport.HTTPHandler()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	var log bytes.Buffer
+	rec := transport.Recording(tr, &log)
+	reg := registry.New()
+	registry.RegisterTool(reg, "Announce", func(ctx context.Context, in struct{}) (struct{}, error) {
+		msg := json.RawMessage(`{"jsonrpc":"2.0","method":"notifications/message","params":{"data":"hello"}}`)
+		return struct{}{}, rpc.SessionFrom(ctx).Notify(ctx, msg)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = rpc.NewServer(reg, rec).Run(ctx)
+		close(done)
+	}()
+
+	resp, err := http.Post(srv.URL, "application/json",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	id := resp.Header.Get(transport.SessionHeader)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL,
+		strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"Announce"}}`))
+	req.Header.Set(transport.SessionHeader, id)
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	cancel()
+	<-done
+	rec.Close()
+
+	recs, err := loadRecords(&log)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for _, r := range recs {
+		if r.Direction == transport.DirectionOut && strings.Contains(string(r.Message), "notifications/message") {
+			if r.Session != id {
+				t.Fatalf("recorded session %q, want %q", r.Session, id)
+			}
+			return
+		}
+	}
+	t.Fatalf("session notification not recorded:\n%s", log.String())
+}
+
+func loadRecords(b *bytes.Buffer) ([]transport.Record, error) {
+	var recs []transport.Record
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var rec transport.Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,352 @@
+package transport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSessionNotFound is returned by a SessionStore for unknown or expired
+// session IDs.
+var ErrSessionNotFound = errors.New("transport: session not found")
+
+// Session is the state shared by all requests of one client on a
+// session-aware transport. Values can be used by servers to keep negotiated
+// capabilities, subscriptions and similar per-client data.
+type Session struct {
+	ID        string
+	CreatedAt time.Time
+
+	subject    string       // bearer token subject allowed to use the session
+	lastActive atomic.Int64 // unix nanoseconds
+	active     atomic.Int32 // requests being handled
+	values     sync.Map
+	// streamPrefix starts the IDs of the streams created by this Session
+	// value. It differs from that of a Session started again from the
+	// same record, so their stored events never mix.
+	streamPrefix string
+
+	mu         sync.Mutex
+	events     EventStore
+	maxEvents  int // stored events kept across streams, 0 for no limit
+	nextStream int
+	streams    map[string]*stream // streams that may still publish
+	streamIDs  []string           // streams with stored events, oldest first
+	stored     map[string]int     // events stored per stream
+	total      int
+	observers  map[any]func(json.RawMessage) // see observe
+	done       chan struct{}
+	closed     bool
+}
+
+// NewSession returns a session with a random ID.
+func NewSession() *Session {
+	return newSession(randomHex(16), time.Now())
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("transport: reading random id: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+func newSession(id string, created time.Time) *Session {
+	s := &Session{
+		ID:           id,
+		CreatedAt:    created,
+		streamPrefix: id + "-" + randomHex(4) + "-",
+		streams:      make(map[string]*stream),
+		stored:       make(map[string]int),
+		done:         make(chan struct{}),
+	}
+	s.lastActive.Store(time.Now().UnixNano())
+	return s
+}
+
+// Load returns the value stored under key.
+func (s *Session) Load(key any) (any, bool) { return s.values.Load(key) }
+
+// Store sets the value for key.
+func (s *Session) Store(key, value any) { s.values.Store(key, value) }
+
+// LastActive returns the time of the session's most recent activity.
+func (s *Session) LastActive() time.Time { return time.Unix(0, s.lastActive.Load()) }
+
+// Done returns a channel that is closed when the session ends, for example
+// because the client deleted it or it expired.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+func (s *Session) touch() { s.lastActive.Store(time.Now().UnixNano()) }
+
+// busy reports whether s is handling a request or has a connected event
+// stream, in which case it must not expire.
+func (s *Session) busy() bool {
+	if s.active.Load() > 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, st := range s.streams {
+		if st.attached() {
+			return true
+		}
+	}
+	return false
+}
+
+// record returns the state of s kept in a SessionStore.
+func (s *Session) record() SessionRecord {
+	return SessionRecord{ID: s.ID, Subject: s.subject, CreatedAt: s.CreatedAt, LastActive: s.LastActive()}
+}
+
+// newStream creates a stream owned by s. It returns nil once s is closed.
+func (s *Session) newStream(standalone bool) *stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.nextStream++
+	id := s.streamPrefix + strconv.Itoa(s.nextStream)
+	st := newStream(id, standalone, s.events)
+	s.streams[id] = st
+	if s.events != nil {
+		s.streamIDs = append(s.streamIDs, id)
+		st.onStore = func(ctx context.Context) { s.eventStored(ctx, id) }
+	}
+	return st
+}
+
+// ownsStream reports whether the stream ID was issued for the session, by
+// s or by an earlier process holding it.
+func (s *Session) ownsStream(id string) bool {
+	return strings.HasPrefix(id, s.ID+"-")
+}
+
+func (s *Session) stream(id string) *stream {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streams[id]
+}
+
+// removeStream ends st and forgets it. Its stored events remain available
+// for replay until they are evicted or the session is closed.
+func (s *Session) removeStream(st *stream) {
+	st.end()
+	s.mu.Lock()
+	delete(s.streams, st.id)
+	s.mu.Unlock()
+}
+
+// eventStored counts an event stored for stream id. Once the session holds
+// more than maxEvents, the events of its oldest finished streams are
+// discarded, so that a long-lived session does not keep the events of
+// every stream it opened.
+func (s *Session) eventStored(ctx context.Context, id string) {
+	s.mu.Lock()
+	s.stored[id]++
+	s.total++
+	var drop []string
+	if s.maxEvents > 0 {
+		total := s.total
+		for _, sid := range s.streamIDs {
+			if total <= s.maxEvents {
+				break
+			}
+			if _, live := s.streams[sid]; !live {
+				total -= s.stored[sid]
+				drop = append(drop, sid)
+			}
+		}
+	}
+	s.mu.Unlock()
+	for _, sid := range drop {
+		s.discardStream(ctx, sid)
+	}
+}
+
+// discardStream deletes the stored events of a stream that will not be
+// resumed.
+func (s *Session) discardStream(ctx context.Context, id string) {
+	s.mu.Lock()
+	for i, sid := range s.streamIDs {
+		if sid == id {
+			s.streamIDs = append(s.streamIDs[:i], s.streamIDs[i+1:]...)
+			break
+		}
+	}
+	s.total -= s.stored[id]
+	delete(s.stored, id)
+	events := s.events
+	s.mu.Unlock()
+	if events != nil {
+		_ = events.Delete(ctx, id)
+	}
+}
+
+// dropDetachedStandalone removes GET streams nobody is reading; a client
+// opening a fresh GET stream has given up on resuming them.
+func (s *Session) dropDetachedStandalone(ctx context.Context) {
+	s.mu.Lock()
+	var drop []*stream
+	for _, st := range s.streams {
+		if st.standalone && !st.attached() {
+			drop = append(drop, st)
+		}
+	}
+	s.mu.Unlock()
+	for _, st := range drop {
+		s.removeStream(st)
+		s.discardStream(ctx, st.id)
+	}
+}
+
+// Notify sends a server-initiated message on the session's GET event
+// streams. When the client is not listening the message is dropped, unless
+// an EventStore keeps it for a later resumption.
+func (s *Session) Notify(ctx context.Context, msg json.RawMessage) error {
+	s.mu.Lock()
+	observers := make([]func(json.RawMessage), 0, len(s.observers))
+	for _, fn := range s.observers {
+		observers = append(observers, fn)
+	}
+	s.mu.Unlock()
+	for _, fn := range observers {
+		fn(msg)
+	}
+	return s.notify(ctx, msg)
+}
+
+// observe makes fn see every message sent with Notify, such as by a
+// Recording wrapped around the transport. Only the first fn given for a
+// key is kept.
+func (s *Session) observe(key any, fn func(json.RawMessage)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.observers == nil {
+		s.observers = make(map[any]func(json.RawMessage))
+	}
+	if _, ok := s.observers[key]; !ok {
+		s.observers[key] = fn
+	}
+}
+
+// notify sends msg on the session's GET event streams without showing it
+// to observers, for messages the transport sends to every session.
+func (s *Session) notify(ctx context.Context, msg json.RawMessage) error {
+	s.mu.Lock()
+	var targets []*stream
+	for _, st := range s.streams {
+		if st.standalone && (st.attached() || s.events != nil) {
+			targets = append(targets, st)
+		}
+	}
+	s.mu.Unlock()
+	for _, st := range targets {
+		if _, err := st.publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close ends the session's streams and returns the IDs of every stream it
+// created.
+func (s *Session) close() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for _, st := range s.streams {
+		st.end()
+	}
+	s.streams = nil
+	return s.streamIDs
+}
+
+// SessionRecord is the state of an HTTP session kept in a SessionStore. It
+// is plain data, so a store may keep it outside the process, for example to
+// let several server instances accept the same sessions. Event streams and
+// values set with Session.Store stay in the process holding the session.
+type SessionRecord struct {
+	ID string `json:"id"`
+	// Subject is the bearer token subject that created the session, if
+	// any. Only that subject may use the session.
+	Subject    string    `json:"subject,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastActive time.Time `json:"lastActive"`
+}
+
+// SessionStore keeps the records of a transport's sessions.
+// Implementations must be safe for concurrent use.
+type SessionStore interface {
+	// Put creates or replaces the record with rec's ID.
+	Put(ctx context.Context, rec SessionRecord) error
+	// Get returns ErrSessionNotFound for unknown IDs.
+	Get(ctx context.Context, id string) (SessionRecord, error)
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]SessionRecord, error)
+}
+
+type memorySessionStore struct {
+	mu       sync.RWMutex
+	sessions map[string]SessionRecord
+}
+
+// NewMemorySessionStore returns a SessionStore that keeps records in memory.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]SessionRecord)}
+}
+
+func (m *memorySessionStore) Put(ctx context.Context, rec SessionRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[rec.ID] = rec
+	return nil
+}
+
+func (m *memorySessionStore) Get(ctx context.Context, id string) (SessionRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rec, ok := m.sessions[id]
+	if !ok {
+		return SessionRecord{}, ErrSessionNotFound
+	}
+	return rec, nil
+}
+
+func (m *memorySessionStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+func (m *memorySessionStore) List(ctx context.Context) ([]SessionRecord, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]SessionRecord, 0, len(m.sessions))
+	for _, rec := range m.sessions {
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+// SessionConn is implemented by connections whose messages belong to a
+// session.
+type SessionConn interface {
+	Conn
+	Session() *Session
+}
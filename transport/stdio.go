@@ -2,10 +2,13 @@ package transport
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
+	"sync"
 )
 
 type Conn interface {
@@ -17,28 +20,163 @@ type Transport interface {
 	Close() error
 }
 
+// Notifier is implemented by transports that can deliver server-initiated
+// messages outside the scope of a request.
+type Notifier interface {
+	Notify(ctx context.Context, msg json.RawMessage) error
+}
+
+// Shutdowner is implemented by transports that can close gracefully,
+// finishing the delivery of pending responses until ctx expires.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// DefaultMaxLineSize is the largest message, in bytes, read by the
+// line-delimited transports when no limit is given.
+const DefaultMaxLineSize = 4 << 20
+
 type stdioTransport struct {
-	in  *bufio.Reader
-	out io.Writer
+	in      *bufio.Reader
+	out     *lineWriter
+	maxLine int
+
+	start  sync.Once
+	lines  chan json.RawMessage // closed once the input ends
+	err    error                // why the input ended, set before lines closes
+	done   chan struct{}
+	closed sync.Once
 }
 
-type stdioConn struct{ out io.Writer }
+// StdioOption configures the transport returned by NewStdioTransport.
+type StdioOption func(*stdioTransport)
 
-func (c *stdioConn) Send(ctx context.Context, resp json.RawMessage) error {
-	_, err := c.out.Write(append(resp, '\n'))
+// WithMaxLineSize sets the largest message read from the input. Longer
+// lines are discarded without being buffered and answered with an Invalid
+// Request error.
+func WithMaxLineSize(n int) StdioOption {
+	return func(s *stdioTransport) { s.maxLine = n }
+}
+
+// lineWriter writes whole newline-terminated messages, one at a time.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lineWriter) writeLine(msg []byte) error {
+	line := append(bytes.TrimSpace(msg), '\n')
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	_, err := lw.w.Write(line)
 	return err
 }
 
-func StdioTransport() Transport {
-	return &stdioTransport{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+type stdioConn struct{ out *lineWriter }
+
+func (c *stdioConn) Send(ctx context.Context, resp json.RawMessage) error {
+	return c.out.writeLine(resp)
+}
+
+// StdioTransport returns a Transport reading newline-delimited messages
+// from os.Stdin and writing them to os.Stdout.
+func StdioTransport(opts ...StdioOption) Transport {
+	return NewStdioTransport(os.Stdin, os.Stdout, opts...)
+}
+
+// NewStdioTransport returns a Transport reading newline-delimited messages
+// from r and writing them to w, for example the pipes of a subprocess.
+// Messages are written whole, so concurrent handlers never interleave
+// their output.
+func NewStdioTransport(r io.Reader, w io.Writer, opts ...StdioOption) Transport {
+	s := &stdioTransport{
+		in:      bufio.NewReader(r),
+		out:     &lineWriter{w: w},
+		maxLine: DefaultMaxLineSize,
+		lines:   make(chan json.RawMessage),
+		done:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// read hands the lines of the input to Next, so that Next can return when
+// its context ends or the transport is closed while a read is blocked.
+func (s *stdioTransport) read() {
+	defer close(s.lines)
+	for {
+		line, err := readLine(s.in, s.maxLine)
+		if errors.Is(err, errLineTooLong) {
+			_ = s.out.writeLine(tooLargeResponse(int64(s.maxLine)))
+			continue
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			select {
+			case s.lines <- json.RawMessage(line):
+			case <-s.done:
+				s.err = io.EOF
+				return
+			}
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
 }
 
 func (s *stdioTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
-	line, err := s.in.ReadBytes('\n')
-	if err != nil {
-		return nil, nil, err
+	s.start.Do(func() { go s.read() })
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			return nil, nil, s.err
+		}
+		return &stdioConn{out: s.out}, line, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-s.done:
+		return nil, nil, io.EOF
 	}
-	return &stdioConn{out: s.out}, json.RawMessage(line), nil
 }
 
-func (s *stdioTransport) Close() error { return nil }
+// Notify writes a server-initiated message to the output stream.
+func (s *stdioTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	return s.out.writeLine(msg)
+}
+
+// Close makes Next return io.EOF. A read already blocked on the input is
+// left to finish on its own.
+func (s *stdioTransport) Close() error {
+	s.closed.Do(func() { close(s.done) })
+	return nil
+}
+
+var errLineTooLong = errors.New("transport: line too long")
+
+// readLine reads up to and including the next newline. A line longer than
+// max bytes is consumed and reported as errLineTooLong. A final line without
+// a newline is returned together with io.EOF.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if max > 0 && len(line)+len(chunk) > max+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if tooLong {
+			return nil, errLineTooLong
+		}
+		return line, err
+	}
+}
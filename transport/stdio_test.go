@@ -0,0 +1,87 @@
+package transport_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cyrusaf/mcp/transport"
+)
+
+// chunkWriter records each Write call separately, as a pipe might deliver
+// them, so interleaving is visible.
+type chunkWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func TestStdioTransportConcurrentSends(t *testing.T) {
+	var out chunkWriter
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n")
+	tr := transport.NewStdioTransport(in, &out)
+	conn, req, err := tr.Next(context.Background())
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if !strings.Contains(string(req), `"ping"`) {
+		t.Fatalf("unexpected request %s", req)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			msg := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"result":{}}`+"\n", i)
+			if err := conn.Send(context.Background(), json.RawMessage(msg)); err != nil {
+				t.Errorf("send: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(out.writes) != 50 {
+		t.Fatalf("expected 50 writes, got %d", len(out.writes))
+	}
+	for _, w := range out.writes {
+		if strings.Count(w, "\n") != 1 || !strings.HasSuffix(w, "}\n") || !json.Valid([]byte(w)) {
+			t.Fatalf("write is not a single message line: %q", w)
+		}
+	}
+
+	if _, _, err := tr.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestStdioTransportMaxLineSize(t *testing.T) {
+	var out bytes.Buffer
+	long := `{"jsonrpc":"2.0","id":1,"method":"` + strings.Repeat("x", 100) + `"}`
+	in := strings.NewReader(long + "\n\n" + `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	tr := transport.NewStdioTransport(in, &out, transport.WithMaxLineSize(64))
+
+	_, req, err := tr.Next(context.Background())
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if !strings.Contains(string(req), `"id":2`) {
+		t.Fatalf("expected oversized and blank lines to be skipped, got %s", req)
+	}
+	if !strings.Contains(out.String(), `"code":-32600`) || !strings.Contains(out.String(), `"id":null`) {
+		t.Fatalf("expected invalid request error, got %q", out.String())
+	}
+	if _, _, err := tr.Next(context.Background()); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
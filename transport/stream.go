@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// stream is a logical server-sent event stream. It is written to by the
+// server and read by at most one HTTP response at a time; with an
+// EventStore configured it outlives the response so that a client can
+// reconnect and resume it.
+type stream struct {
+	id         string
+	standalone bool // a GET stream for server-initiated messages
+	events     EventStore
+	onStore    func(context.Context) // called after an event is stored
+
+	pubMu sync.Mutex // serialises publish so events are stored in order
+
+	mu    sync.Mutex
+	seq   int64
+	sub   *subscriber
+	done  chan struct{}
+	ended bool
+}
+
+// subscriber is an HTTP response reading a stream.
+type subscriber struct {
+	ch   chan Event
+	gone chan struct{}
+}
+
+func newStream(id string, standalone bool, events EventStore) *stream {
+	return &stream{id: id, standalone: standalone, events: events, done: make(chan struct{})}
+}
+
+// publish assigns data the next sequence number, records it in the event
+// store and hands it to the attached subscriber, if any. The event is
+// delivered even if it could not be recorded.
+func (s *stream) publish(ctx context.Context, data json.RawMessage) (Event, error) {
+	s.pubMu.Lock()
+	defer s.pubMu.Unlock()
+	s.mu.Lock()
+	s.seq++
+	ev := Event{StreamID: s.id, Seq: s.seq, Data: data}
+	sub := s.sub
+	s.mu.Unlock()
+	var err error
+	if s.events != nil {
+		if err = s.events.Append(ctx, ev); err == nil && s.onStore != nil {
+			s.onStore(ctx)
+		}
+	}
+	if sub != nil {
+		select {
+		case sub.ch <- ev:
+		case <-sub.gone:
+		case <-ctx.Done():
+			return ev, ctx.Err()
+		}
+	}
+	return ev, err
+}
+
+// attach makes a new subscriber the reader of s, replacing any previous one.
+func (s *stream) attach() *subscriber {
+	sub := &subscriber{ch: make(chan Event, 16), gone: make(chan struct{})}
+	s.mu.Lock()
+	s.sub = sub
+	s.mu.Unlock()
+	return sub
+}
+
+func (s *stream) detach(sub *subscriber) {
+	close(sub.gone)
+	s.mu.Lock()
+	if s.sub == sub {
+		s.sub = nil
+	}
+	s.mu.Unlock()
+}
+
+func (s *stream) attached() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sub != nil
+}
+
+// end marks that no more events will be published on s.
+func (s *stream) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.ended = true
+		close(s.done)
+	}
+}
@@ -0,0 +1,548 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// WebSocket close codes, see RFC 6455 section 7.4.1.
+const (
+	wsCloseNormal          = 1000
+	wsCloseGoingAway       = 1001
+	wsCloseProtocolError   = 1002
+	wsCloseUnsupportedData = 1003
+	wsCloseNoStatus        = 1005
+	wsCloseInvalidPayload  = 1007
+	wsCloseTooBig          = 1009
+)
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketSubprotocol is the subprotocol negotiated with clients that
+// offer it in Sec-WebSocket-Protocol.
+const WebSocketSubprotocol = "mcp"
+
+// DefaultWebSocketPingInterval is the keepalive interval used when no
+// WithPingInterval option is given.
+const DefaultWebSocketPingInterval = 30 * time.Second
+
+// DefaultWebSocketReadLimit is the largest message, in bytes, accepted from
+// a client when no WithReadLimit option is given.
+const DefaultWebSocketReadLimit = 4 << 20
+
+// wsCloseTimeout is how long to wait for the peer to answer a close frame.
+var wsCloseTimeout = 5 * time.Second
+
+// wsCloseError is a close frame, sent or received.
+type wsCloseError struct {
+	code   int
+	reason string
+}
+
+func (e *wsCloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with code %d %s", e.code, e.reason)
+}
+
+type wsTransport struct {
+	reqCh        chan httpMessage
+	done         chan struct{}
+	pingInterval time.Duration
+	readLimit    int64
+	guard        originGuard
+
+	mu     sync.Mutex
+	conns  map[*wsConn]struct{}
+	closed bool
+}
+
+// WebSocketOption configures the transport returned by WebSocketTransport.
+type WebSocketOption func(*wsTransport)
+
+// WithPingInterval sets how often clients are pinged. A client that sends
+// nothing, not even a pong, for two intervals is disconnected. A zero
+// interval disables keepalive.
+func WithPingInterval(d time.Duration) WebSocketOption {
+	return func(t *wsTransport) { t.pingInterval = d }
+}
+
+// WithReadLimit sets the largest message accepted from a client. Clients
+// sending larger messages are disconnected with close code 1009.
+func WithReadLimit(n int64) WebSocketOption {
+	return func(t *wsTransport) { t.readLimit = n }
+}
+
+// WithWebSocketAllowedOrigins allows browser connections from the given
+// origins in addition to loopback origins; "*" allows every origin. See
+// WithAllowedOrigins.
+func WithWebSocketAllowedOrigins(origins ...string) WebSocketOption {
+	return func(t *wsTransport) { t.guard.allowOrigins(origins) }
+}
+
+// WithWebSocketAllowedHosts rejects connections whose Host header does not
+// name one of hosts. See WithAllowedHosts.
+func WithWebSocketAllowedHosts(hosts ...string) WebSocketOption {
+	return func(t *wsTransport) { t.guard.hosts = append(t.guard.hosts, hosts...) }
+}
+
+// WebSocketTransport returns a Transport that carries JSON-RPC messages as
+// WebSocket text messages, one connection per client. Mount it where
+// clients connect:
+//
+//	tr := transport.WebSocketTransport()
+//	mux.Handle("/ws", tr)
+//	srv := rpc.NewServer(reg, tr)
+//
+// Each connection has its own Session, and server-initiated messages sent
+// with Notify go to every connected client. Browsers may only connect from
+// loopback origins unless WithWebSocketAllowedOrigins says otherwise. Close
+// disconnects clients with close code 1001.
+func WebSocketTransport(opts ...WebSocketOption) HandlerTransport {
+	t := &wsTransport{
+		reqCh:        make(chan httpMessage, 16),
+		done:         make(chan struct{}),
+		pingInterval: DefaultWebSocketPingInterval,
+		readLimit:    DefaultWebSocketReadLimit,
+		guard:        originGuard{methods: "GET"},
+		conns:        make(map[*wsConn]struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *wsTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !t.guard.check(w, r) {
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported by server", http.StatusInternalServerError)
+		return
+	}
+
+	t.mu.Lock()
+	closed := t.closed
+	t.mu.Unlock()
+	if closed {
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	nc, brw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	var resp bytes.Buffer
+	resp.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resp.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")
+	resp.WriteString("Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n")
+	if headerContains(r.Header, "Sec-WebSocket-Protocol", WebSocketSubprotocol) {
+		resp.WriteString("Sec-WebSocket-Protocol: " + WebSocketSubprotocol + "\r\n")
+	}
+	resp.WriteString("\r\n")
+	if _, err := nc.Write(resp.Bytes()); err != nil {
+		nc.Close()
+		return
+	}
+
+	c := newWSConn(nc, brw.Reader, false)
+	c.session = NewSession()
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		c.closeWith(wsCloseGoingAway, "server shutting down")
+		nc.Close()
+		return
+	}
+	t.conns[c] = struct{}{}
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.conns, c)
+		t.mu.Unlock()
+		c.session.close()
+	}()
+	t.serve(c)
+}
+
+// serve reads messages from c until it is closed.
+func (t *wsTransport) serve(c *wsConn) {
+	defer c.nc.Close()
+	if t.pingInterval > 0 {
+		go c.keepalive(t.pingInterval)
+	}
+	for {
+		c.extendReadDeadline(2 * t.pingInterval)
+		data, err := c.readMessage(t.readLimit)
+		if err != nil {
+			var ce *wsCloseError
+			if errors.As(err, &ce) {
+				c.closeWith(ce.code, ce.reason)
+			}
+			return
+		}
+		select {
+		case <-c.done:
+			// drop messages sent before the peer saw our close frame
+			continue
+		default:
+		}
+		c.session.touch()
+		msgs, _, err := splitBatch(data)
+		if err != nil {
+			// let the server answer with a parse error
+			msgs = []json.RawMessage{data}
+		}
+	dispatch:
+		for _, raw := range msgs {
+			select {
+			case t.reqCh <- httpMessage{req: raw, conn: c}:
+			case <-c.done:
+				break dispatch
+			}
+		}
+	}
+}
+
+// Notify sends a server-initiated message to every connected client.
+func (t *wsTransport) Notify(ctx context.Context, msg json.RawMessage) error {
+	t.mu.Lock()
+	conns := make([]*wsConn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		if err := c.Send(ctx, msg); err != nil && err != ErrConnClosed {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *wsTransport) Next(ctx context.Context) (Conn, json.RawMessage, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case msg := <-t.reqCh:
+		return msg.conn, msg.req, nil
+	case <-t.done:
+		return nil, nil, io.EOF
+	}
+}
+
+// Close stops accepting messages and disconnects every client.
+func (t *wsTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.done)
+	conns := make([]*wsConn, 0, len(t.conns))
+	for c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.mu.Unlock()
+	for _, c := range conns {
+		c.closeWith(wsCloseGoingAway, "server shutting down")
+	}
+	return nil
+}
+
+// wsConn is one end of a WebSocket connection.
+type wsConn struct {
+	nc      net.Conn
+	br      *bufio.Reader
+	client  bool // mask outgoing frames
+	session *Session
+
+	wmu       sync.Mutex
+	closeSent bool
+	done      chan struct{}
+}
+
+func newWSConn(nc net.Conn, br *bufio.Reader, client bool) *wsConn {
+	return &wsConn{nc: nc, br: br, client: client, done: make(chan struct{})}
+}
+
+func (c *wsConn) Session() *Session { return c.session }
+
+// Send writes msg as a text message.
+func (c *wsConn) Send(ctx context.Context, msg json.RawMessage) error {
+	return c.write(ctx, wsOpText, bytes.TrimSpace(msg))
+}
+
+func (c *wsConn) write(ctx context.Context, op byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return ErrConnClosed
+	}
+	if d, ok := ctx.Deadline(); ok {
+		c.nc.SetWriteDeadline(d)
+		defer c.nc.SetWriteDeadline(time.Time{})
+	}
+	return writeWSFrame(c.nc, op, payload, c.client)
+}
+
+// closeWith starts the closing handshake, sending a close frame with code
+// and waiting at most wsCloseTimeout for the peer to answer. It does nothing
+// if a close frame was already sent.
+func (c *wsConn) closeWith(code int, reason string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closeSent {
+		return
+	}
+	c.closeSent = true
+	close(c.done)
+	var payload []byte
+	if code != wsCloseNoStatus {
+		payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+		payload = append(payload, reason...)
+	}
+	c.nc.SetWriteDeadline(time.Now().Add(wsCloseTimeout))
+	writeWSFrame(c.nc, wsOpClose, payload, c.client)
+	c.nc.SetReadDeadline(time.Now().Add(wsCloseTimeout))
+}
+
+// extendReadDeadline moves the read deadline d into the future, unless d is
+// zero or a close frame was sent, in which case the deadline set by closeWith
+// is kept.
+func (c *wsConn) extendReadDeadline(d time.Duration) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if !c.closeSent && d > 0 {
+		c.nc.SetReadDeadline(time.Now().Add(d))
+	}
+}
+
+func (c *wsConn) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.write(context.Background(), wsOpPing, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readMessage returns the next text message, answering pings on the way. A
+// close frame from the peer, or a protocol violation, is returned as a
+// *wsCloseError holding the code to answer with.
+func (c *wsConn) readMessage(limit int64) ([]byte, error) {
+	var msg []byte
+	var op byte
+	for {
+		fin, fop, payload, err := c.readFrame(limit - int64(len(msg)))
+		if err != nil {
+			return nil, err
+		}
+		switch fop {
+		case wsOpPing:
+			if err := c.write(context.Background(), wsOpPong, payload); err != nil && err != ErrConnClosed {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			return nil, parseWSClose(payload)
+		case wsOpText, wsOpBinary:
+			if op != 0 {
+				return nil, &wsCloseError{wsCloseProtocolError, "expected continuation frame"}
+			}
+			op = fop
+		case wsOpContinuation:
+			if op == 0 {
+				return nil, &wsCloseError{wsCloseProtocolError, "unexpected continuation frame"}
+			}
+		default:
+			return nil, &wsCloseError{wsCloseProtocolError, "unknown opcode"}
+		}
+		msg = append(msg, payload...)
+		if !fin {
+			continue
+		}
+		if op == wsOpBinary {
+			return nil, &wsCloseError{wsCloseUnsupportedData, "binary messages are not supported"}
+		}
+		if !utf8.Valid(msg) {
+			return nil, &wsCloseError{wsCloseInvalidPayload, "invalid UTF-8"}
+		}
+		return msg, nil
+	}
+}
+
+// readFrame reads a single frame of at most limit payload bytes.
+func (c *wsConn) readFrame(limit int64) (fin bool, op byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(c.br, hdr[:]); err != nil {
+		return
+	}
+	fin = hdr[0]&0x80 != 0
+	op = hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	if hdr[0]&0x70 != 0 {
+		return fin, op, nil, &wsCloseError{wsCloseProtocolError, "reserved bits set"}
+	}
+	if masked == c.client {
+		return fin, op, nil, &wsCloseError{wsCloseProtocolError, "bad frame masking"}
+	}
+	n := int64(hdr[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if op >= wsOpClose {
+		if !fin || n > 125 {
+			return fin, op, nil, &wsCloseError{wsCloseProtocolError, "invalid control frame"}
+		}
+	} else if n < 0 || n > limit {
+		return fin, op, nil, &wsCloseError{wsCloseTooBig, "message too big"}
+	}
+	var key [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, key[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= key[i%4]
+		}
+	}
+	return fin, op, payload, nil
+}
+
+func writeWSFrame(w io.Writer, op byte, payload []byte, mask bool) error {
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|op)
+	var maskBit byte
+	if mask {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	if !mask {
+		buf = append(buf, payload...)
+	} else {
+		var key [4]byte
+		if _, err := rand.Read(key[:]); err != nil {
+			return err
+		}
+		buf = append(buf, key[:]...)
+		for i, b := range payload {
+			buf = append(buf, b^key[i%4])
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// parseWSClose returns the close error to answer a close frame with.
+func parseWSClose(payload []byte) *wsCloseError {
+	switch {
+	case len(payload) == 0:
+		return &wsCloseError{code: wsCloseNormal}
+	case len(payload) == 1 || !utf8.Valid(payload[2:]):
+		return &wsCloseError{wsCloseProtocolError, "invalid close frame"}
+	}
+	code := int(binary.BigEndian.Uint16(payload))
+	if code < 1000 || code == wsCloseNoStatus || code == 1006 || code == 1015 || code >= 5000 {
+		return &wsCloseError{wsCloseProtocolError, "invalid close code"}
+	}
+	return &wsCloseError{code: code}
+}
+
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// headerContains reports whether the comma-separated values of header name
+// include token, case-insensitively.
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
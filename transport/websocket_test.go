@@ -0,0 +1,218 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dialWS(t *testing.T, url string) (*wsConn, *http.Response) {
+	t.Helper()
+	nc, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Protocol", "mcp")
+	if err := req.Write(nc); err != nil {
+		t.Fatalf("write handshake: %v", err)
+	}
+	br := bufio.NewReader(nc)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected accept key %q", got)
+	}
+	t.Cleanup(func() { nc.Close() })
+	return newWSConn(nc, br, true), resp
+}
+
+func TestWebSocketRoundTrip(t *testing.T) {
+	tr := WebSocketTransport()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	defer tr.Close()
+
+	client, resp := dialWS(t, srv.URL)
+	if resp.Header.Get("Sec-WebSocket-Protocol") != "mcp" {
+		t.Fatalf("subprotocol not negotiated")
+	}
+	ctx := context.Background()
+	if err := client.Send(ctx, []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	conn, req, err := tr.Next(ctx)
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if string(req) != `{"jsonrpc":"2.0","id":1,"method":"ping"}` {
+		t.Fatalf("unexpected request %s", req)
+	}
+	if sc, ok := conn.(SessionConn); !ok || sc.Session() == nil {
+		t.Fatalf("connection has no session")
+	}
+	if err := conn.Send(ctx, []byte(`{"jsonrpc":"2.0","id":1,"result":{}}`+"\n")); err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	msg, err := client.readMessage(DefaultWebSocketReadLimit)
+	if err != nil || string(msg) != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Fatalf("unexpected reply %s: %v", msg, err)
+	}
+
+	if err := tr.(Notifier).Notify(ctx, []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	msg, err = client.readMessage(DefaultWebSocketReadLimit)
+	if err != nil || !strings.Contains(string(msg), "list_changed") {
+		t.Fatalf("unexpected notification %s: %v", msg, err)
+	}
+}
+
+func TestWebSocketFragmentedMessage(t *testing.T) {
+	tr := WebSocketTransport()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	defer tr.Close()
+
+	client, _ := dialWS(t, srv.URL)
+	// a text frame without FIN followed by a continuation frame
+	if _, err := client.nc.Write([]byte{wsOpText, 0x80 | 3, 0, 0, 0, 0, '{', '"', 'a'}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := client.nc.Write([]byte{0x80 | wsOpContinuation, 0x80 | 5, 0, 0, 0, 0, '"', ':', '1', '}', ' '}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_, req, err := tr.Next(context.Background())
+	if err != nil || string(req) != `{"a":1}` {
+		t.Fatalf("unexpected message %q: %v", req, err)
+	}
+}
+
+func TestWebSocketPingPong(t *testing.T) {
+	tr := WebSocketTransport(WithPingInterval(20 * time.Millisecond))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	defer tr.Close()
+
+	client, _ := dialWS(t, srv.URL)
+	client.nc.SetReadDeadline(time.Now().Add(time.Second))
+	_, op, _, err := client.readFrame(DefaultWebSocketReadLimit)
+	if err != nil || op != wsOpPing {
+		t.Fatalf("expected ping, got op %d: %v", op, err)
+	}
+	if err := client.write(context.Background(), wsOpPing, []byte("hi")); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	for {
+		_, op, payload, err := client.readFrame(DefaultWebSocketReadLimit)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if op == wsOpPong {
+			if string(payload) != "hi" {
+				t.Fatalf("unexpected pong payload %q", payload)
+			}
+			return
+		}
+	}
+}
+
+func TestWebSocketCloseCodes(t *testing.T) {
+	tr := WebSocketTransport()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	expectClose := func(c *wsConn, code int) {
+		t.Helper()
+		c.nc.SetReadDeadline(time.Now().Add(time.Second))
+		_, err := c.readMessage(DefaultWebSocketReadLimit)
+		var ce *wsCloseError
+		if !errors.As(err, &ce) || ce.code != code {
+			t.Fatalf("expected close %d, got %v", code, err)
+		}
+	}
+
+	client, _ := dialWS(t, srv.URL)
+	if err := client.write(context.Background(), wsOpBinary, []byte("{}")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expectClose(client, wsCloseUnsupportedData)
+
+	client, _ = dialWS(t, srv.URL)
+	client.closeWith(wsCloseNormal, "bye")
+	expectClose(client, wsCloseNormal)
+
+	client, _ = dialWS(t, srv.URL)
+	// make sure the connection is registered before closing the transport
+	client.Send(context.Background(), []byte(`{}`))
+	if _, _, err := tr.Next(context.Background()); err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	tr.Close()
+	expectClose(client, wsCloseGoingAway)
+}
+
+func TestWebSocketRequiresUpgrade(t *testing.T) {
+	tr := WebSocketTransport()
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+	defer tr.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("expected 426, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebSocketCloseTimeout(t *testing.T) {
+	defer func(d time.Duration) { wsCloseTimeout = d }(wsCloseTimeout)
+	wsCloseTimeout = 100 * time.Millisecond
+	tr := WebSocketTransport(WithPingInterval(time.Minute))
+	srv := httptest.NewServer(tr)
+	defer srv.Close()
+
+	client, _ := dialWS(t, srv.URL)
+	client.Send(context.Background(), []byte(`{}`))
+	if _, _, err := tr.Next(context.Background()); err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	tr.Close()
+	client.nc.SetReadDeadline(time.Now().Add(time.Second))
+	if _, _, _, err := client.readFrame(DefaultWebSocketReadLimit); err != nil {
+		t.Fatalf("read close: %v", err)
+	}
+	// keep sending messages without answering the close frame
+	start := time.Now()
+	for time.Since(start) < time.Second {
+		if err := client.Send(context.Background(), []byte(`{}`)); err != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, _, _, err := client.readFrame(DefaultWebSocketReadLimit); err == nil {
+		t.Fatalf("expected the connection to be closed")
+	}
+	if d := time.Since(start); d < 50*time.Millisecond || d > 900*time.Millisecond {
+		t.Fatalf("connection closed after %v, want about %v", d, wsCloseTimeout)
+	}
+}